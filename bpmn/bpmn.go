@@ -18,61 +18,245 @@ package bpmn
 
 import (
 	"encoding/xml"
+	"strings"
 )
 
 // BpmnDefinitions is the root element of a BPMN 2.0 XML document.
 // See http://www.omg.org/spec/BPMN/2.0/
 type BpmnDefinitions struct {
-	XMLName         xml.Name      `xml:"http://www.omg.org/spec/BPMN/20100524/MODEL definitions"`
-	Bpmn            string        `xml:"xmlns:bpmn,attr"`
-	Bpmndi          string        `xml:"xmlns:bpmndi,attr"`
-	Dc              string        `xml:"xmlns:dc,attr"`
-	Di              string        `xml:"xmlns:di,attr"`
-	Bioc            string        `xml:"xmlns:bioc,attr"`
-	Camunda         string        `xml:"xmlns:camunda,attr"`
-	Id              string        `xml:"id,attr"`
-	TargetNamespace string        `xml:"targetNamespace,attr"`
-	Exporter        string        `xml:"exporter,attr"`
-	ExporterVersion string        `xml:"exporterVersion,attr"`
-	Processes       []BpmnProcess `xml:"process"`
+	XMLName         xml.Name             `xml:"http://www.omg.org/spec/BPMN/20100524/MODEL definitions"`
+	Bpmn            string               `xml:"xmlns:bpmn,attr"`
+	Bpmndi          string               `xml:"xmlns:bpmndi,attr"`
+	Dc              string               `xml:"xmlns:dc,attr"`
+	Di              string               `xml:"xmlns:di,attr"`
+	Bioc            string               `xml:"xmlns:bioc,attr"`
+	Camunda         string               `xml:"xmlns:camunda,attr"`
+	Id              string               `xml:"id,attr"`
+	TargetNamespace string               `xml:"targetNamespace,attr"`
+	Exporter        string               `xml:"exporter,attr"`
+	ExporterVersion string               `xml:"exporterVersion,attr"`
+	ItemDefinitions []BpmnItemDefinition `xml:"itemDefinition"`
+	DataStores      []BpmnDataElement    `xml:"dataStore"`
+	Processes       []BpmnProcess        `xml:"process"`
+	// Unknown holds the root elements that are not supported, including
+	// the diagram interchange, see BPMN_DI_NAMESPACE
+	Unknown []BpmnElement `xml:",any"`
+}
+
+// BpmnItemDefinition is a BPMN 2.0 item definition, giving the type of data
+// elements that refer to it, eg. structureRef="xsd:string".
+type BpmnItemDefinition struct {
+	Id           string `xml:"id,attr"`
+	StructureRef string `xml:"structureRef,attr"`
+	IsCollection bool   `xml:"isCollection,attr"`
 }
 
 // BpmnProcess is a BPMN 2.0 process.
 type BpmnProcess struct {
-	Id                     string             `xml:"id,attr"`
-	Name                   string             `xml:"name,attr"`
-	IsExecutable           bool               `xml:"isExecutable,attr"`
-	CamundaVersionTag      string             `xml:"versionTag,http://camunda.org/schema/1.0/bpmn"`
-	StartEvent             *BpmnStartEvent    `xml:"startEvent"`
-	ServiceTask            []BpmnTask         `xml:"serviceTask"`
-	UserTask               []BpmnTask         `xml:"userTask"`
-	ManualTask             []BpmnTask         `xml:"manualTask"`
-	ScriptTask             []BpmnTask         `xml:"scriptTask"`
-	SendTask               []BpmnTask         `xml:"sendTask"`
-	Task                   []BpmnTask         `xml:"task"`
-	IntermediateThrowEvent []BpmnTask         `xml:"intermediateThrowEvent"`
-	IntermediateCatchEvent []BpmnTask         `xml:"intermediateCatchEvent"`
-	ExclusiveGateway       []BpmnGateway      `xml:"exclusiveGateway"`
-	InclusiveGateway       []BpmnGateway      `xml:"inclusiveGateway"`
-	ParallelGateway        []BpmnGateway      `xml:"parallelGateway"`
-	EndEvent               []BpmnEndEvent     `xml:"endEvent"`
-	SequenceFlow           []BpmnSequenceFlow `xml:"sequenceFlow"`
+	Id                     string                 `xml:"id,attr"`
+	Name                   string                 `xml:"name,attr"`
+	IsExecutable           bool                   `xml:"isExecutable,attr"`
+	CamundaVersionTag      string                 `xml:"versionTag,http://camunda.org/schema/1.0/bpmn"`
+	ExtensionElements      *BpmnExtensionElements `xml:"extensionElements"`
+	LaneSets               []BpmnLaneSet          `xml:"laneSet"`
+	Properties             []BpmnDataElement      `xml:"property"`
+	DataObjects            []BpmnDataElement      `xml:"dataObject"`
+	DataObjectReferences   []BpmnDataReference    `xml:"dataObjectReference"`
+	DataStoreReferences    []BpmnDataReference    `xml:"dataStoreReference"`
+	StartEvent             *BpmnStartEvent        `xml:"startEvent"`
+	ServiceTask            []BpmnTask             `xml:"serviceTask"`
+	UserTask               []BpmnTask             `xml:"userTask"`
+	ManualTask             []BpmnTask             `xml:"manualTask"`
+	ScriptTask             []BpmnTask             `xml:"scriptTask"`
+	SendTask               []BpmnTask             `xml:"sendTask"`
+	Task                   []BpmnTask             `xml:"task"`
+	IntermediateThrowEvent []BpmnTask             `xml:"intermediateThrowEvent"`
+	IntermediateCatchEvent []BpmnTask             `xml:"intermediateCatchEvent"`
+	ExclusiveGateway       []BpmnGateway          `xml:"exclusiveGateway"`
+	InclusiveGateway       []BpmnGateway          `xml:"inclusiveGateway"`
+	ParallelGateway        []BpmnGateway          `xml:"parallelGateway"`
+	EndEvent               []BpmnEndEvent         `xml:"endEvent"`
+	SequenceFlow           []BpmnSequenceFlow     `xml:"sequenceFlow"`
+	// Unknown holds the elements of the process that are not supported,
+	// eg. sub-processes, boundary events and text annotations
+	Unknown []BpmnElement `xml:",any"`
+}
+
+// BpmnElement is a BPMN 2.0 element of a type that is not supported, of
+// which only the type, ID and name are kept.
+type BpmnElement struct {
+	XMLName xml.Name
+	Id      string `xml:"id,attr"`
+	Name    string `xml:"name,attr"`
+}
+
+// BpmnExtensionElements holds the vendor extensions of a BPMN 2.0 element.
+type BpmnExtensionElements struct {
+	Properties  []BpmnProperty   `xml:"properties>property"`
+	InputOutput *BpmnInputOutput `xml:"inputOutput"`
+	Connector   *BpmnConnector   `xml:"connector"`
+	// Camunda 8 (Zeebe) extensions
+	TaskDefinition *BpmnTaskDefinition `xml:"taskDefinition"`
+	IoMapping      *BpmnIoMapping      `xml:"ioMapping"`
+	TaskHeaders    []BpmnTaskHeader    `xml:"taskHeaders>header"`
+}
+
+// BpmnProperty is a Camunda extension property.
+type BpmnProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// BpmnInputOutput holds the Camunda input and output parameters of a task
+// or connector.
+type BpmnInputOutput struct {
+	InputParameters  []BpmnParameter `xml:"inputParameter"`
+	OutputParameters []BpmnParameter `xml:"outputParameter"`
+}
+
+// BpmnParameter is a Camunda input or output parameter. Its value is either
+// text, which may be an expression such as ${ip}, a map, a list or a script.
+type BpmnParameter struct {
+	Name   string      `xml:"name,attr"`
+	Text   string      `xml:",chardata"`
+	Map    *BpmnMap    `xml:"map"`
+	List   *BpmnList   `xml:"list"`
+	Script *BpmnScript `xml:"script"`
+}
+
+// BpmnMap is a Camunda map parameter value.
+type BpmnMap struct {
+	Entries []BpmnEntry `xml:"entry"`
+}
+
+// BpmnEntry is an entry of a Camunda map.
+type BpmnEntry struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// BpmnList is a Camunda list parameter value.
+type BpmnList struct {
+	Values []string `xml:"value"`
 }
 
+// BpmnScript is a script, either inline or loaded from a resource.
+type BpmnScript struct {
+	ScriptFormat string `xml:"scriptFormat,attr"`
+	Resource     string `xml:"resource,attr"`
+	Body         string `xml:",chardata"`
+}
+
+// BpmnConnector is a Camunda connector, eg. the http-connector.
+type BpmnConnector struct {
+	ConnectorId string           `xml:"connectorId"`
+	InputOutput *BpmnInputOutput `xml:"inputOutput"`
+}
+
+// BpmnTaskDefinition is the Zeebe job type and retry count of a task.
+type BpmnTaskDefinition struct {
+	Type    string `xml:"type,attr"`
+	Retries string `xml:"retries,attr"`
+}
+
+// BpmnIoMapping holds the Zeebe input and output mappings of a task. The
+// sources are FEEL expressions, eg. "=alert.ip".
+type BpmnIoMapping struct {
+	Inputs  []BpmnMapping `xml:"input"`
+	Outputs []BpmnMapping `xml:"output"`
+}
+
+// BpmnMapping is a Zeebe input or output mapping.
+type BpmnMapping struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// BpmnTaskHeader is a Zeebe task header, a static value passed to the job worker.
+type BpmnTaskHeader struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// Camunda connector IDs
+const CAMUNDA_CONNECTOR_HTTP string = "http-connector"
+
 // BpmnStartEvent is a BPMN 2.0 start event.
 type BpmnStartEvent struct {
-	Id       string `xml:"id,attr"`
-	Name     string `xml:"name,attr"`
-	Outgoing string `xml:"outgoing"`
+	Id       string   `xml:"id,attr"`
+	Name     string   `xml:"name,attr"`
+	Outgoing []string `xml:"outgoing"`
 }
 
 // BpmnTask is a BPMN 2.0 task.
 type BpmnTask struct {
+	Id                string                 `xml:"id,attr"`
+	Name              string                 `xml:"name,attr"`
+	Documentation     string                 `xml:"documentation"`
+	Incoming          []string               `xml:"incoming"`
+	Outgoing          []string               `xml:"outgoing"`
+	ExtensionElements *BpmnExtensionElements `xml:"extensionElements"`
+	// CamundaType and CamundaTopic are set on external service tasks
+	CamundaType  string `xml:"http://camunda.org/schema/1.0/bpmn type,attr"`
+	CamundaTopic string `xml:"http://camunda.org/schema/1.0/bpmn topic,attr"`
+	// ScriptFormat and Script are the language and body of a script task,
+	// CamundaResource names a script kept outside the diagram
+	ScriptFormat    string `xml:"scriptFormat,attr"`
+	Script          string `xml:"script"`
+	CamundaResource string `xml:"http://camunda.org/schema/1.0/bpmn resource,attr"`
+	// the data the task reads and writes
+	Properties             []BpmnDataElement     `xml:"property"`
+	IoSpecification        *BpmnIoSpecification  `xml:"ioSpecification"`
+	DataInputAssociations  []BpmnDataAssociation `xml:"dataInputAssociation"`
+	DataOutputAssociations []BpmnDataAssociation `xml:"dataOutputAssociation"`
+}
+
+// BpmnDataElement is a BPMN 2.0 item-aware element: a property, data
+// object, data store, data input or data output.
+type BpmnDataElement struct {
+	Id             string `xml:"id,attr"`
+	Name           string `xml:"name,attr"`
+	ItemSubjectRef string `xml:"itemSubjectRef,attr"`
+	IsCollection   bool   `xml:"isCollection,attr"`
+}
+
+// BpmnDataReference is a BPMN 2.0 data object or data store reference.
+// Only one of DataObjectRef and DataStoreRef is set.
+type BpmnDataReference struct {
 	Id            string `xml:"id,attr"`
 	Name          string `xml:"name,attr"`
-	Documentation string `xml:"documentation"`
-	Incoming      string `xml:"incoming"`
-	Outgoing      string `xml:"outgoing"`
+	DataObjectRef string `xml:"dataObjectRef,attr"`
+	DataStoreRef  string `xml:"dataStoreRef,attr"`
+}
+
+// BpmnIoSpecification is the BPMN 2.0 input/output specification of a task.
+type BpmnIoSpecification struct {
+	DataInputs  []BpmnDataElement `xml:"dataInput"`
+	DataOutputs []BpmnDataElement `xml:"dataOutput"`
+}
+
+// BpmnDataAssociation is a BPMN 2.0 data input or output association. Input
+// associations read their sources into the target, eg. a data object
+// reference into a data input of the task; output associations write their
+// source, or the task itself if there is none, to the target.
+type BpmnDataAssociation struct {
+	Id         string   `xml:"id,attr"`
+	SourceRefs []string `xml:"sourceRef"`
+	TargetRef  string   `xml:"targetRef"`
+}
+
+// BpmnLaneSet is a BPMN 2.0 lane set.
+type BpmnLaneSet struct {
+	Id    string     `xml:"id,attr"`
+	Lanes []BpmnLane `xml:"lane"`
+}
+
+// BpmnLane is a BPMN 2.0 lane, which may be divided into child lanes.
+type BpmnLane struct {
+	Id           string       `xml:"id,attr"`
+	Name         string       `xml:"name,attr"`
+	FlowNodeRefs []string     `xml:"flowNodeRef"`
+	ChildLaneSet *BpmnLaneSet `xml:"childLaneSet"`
 }
 
 // BpmnGateway is a BPMN 2.0 gateway.
@@ -81,6 +265,8 @@ type BpmnGateway struct {
 	Name     string   `xml:"name,attr"`
 	Incoming string   `xml:"incoming"`
 	Outgoing []string `xml:"outgoing"`
+	// Default is the ID of the flow taken when no other condition holds
+	Default string `xml:"default,attr"`
 }
 
 // BpmnEndEvent is a BPMN 2.0 end event.
@@ -98,10 +284,113 @@ type BpmnSignalEventDefinition struct {
 
 // BpmnSequenceFlow is a BPMN 2.0 sequence flow.
 type BpmnSequenceFlow struct {
-	Id        string `xml:"id,attr"`
-	SourceRef string `xml:"sourceRef,attr"`
-	TargetRef string `xml:"targetRef,attr"`
-	Name      string `xml:"name,attr"`
+	Id                  string          `xml:"id,attr"`
+	SourceRef           string          `xml:"sourceRef,attr"`
+	TargetRef           string          `xml:"targetRef,attr"`
+	Name                string          `xml:"name,attr"`
+	ConditionExpression *BpmnExpression `xml:"conditionExpression"`
+}
+
+// BpmnExpression is a BPMN 2.0 formal expression, eg. the condition of a
+// sequence flow. Zeebe conditions are FEEL expressions starting with "=".
+type BpmnExpression struct {
+	Language string `xml:"language,attr"`
+	Body     string `xml:",chardata"`
+}
+
+// the namespace of the BPMN 2.0 diagram interchange, which lays out the
+// diagram but does not affect the process
+const BPMN_DI_NAMESPACE string = "http://www.omg.org/spec/BPMN/20100524/DI"
+
+// BPMN 2.0 element types
+const BPMN_ELEMENT_START_EVENT string = "startEvent"
+const BPMN_ELEMENT_END_EVENT string = "endEvent"
+const BPMN_ELEMENT_EXCLUSIVE_GATEWAY string = "exclusiveGateway"
+const BPMN_ELEMENT_INCLUSIVE_GATEWAY string = "inclusiveGateway"
+const BPMN_ELEMENT_PARALLEL_GATEWAY string = "parallelGateway"
+const BPMN_ELEMENT_SEQUENCE_FLOW string = "sequenceFlow"
+const BPMN_ELEMENT_PROPERTY string = "property"
+const BPMN_ELEMENT_DATA_OBJECT string = "dataObject"
+const BPMN_ELEMENT_DATA_OBJECT_REFERENCE string = "dataObjectReference"
+const BPMN_ELEMENT_DATA_STORE_REFERENCE string = "dataStoreReference"
+const BPMN_ELEMENT_SERVICE_TASK string = "serviceTask"
+const BPMN_ELEMENT_USER_TASK string = "userTask"
+const BPMN_ELEMENT_MANUAL_TASK string = "manualTask"
+const BPMN_ELEMENT_SCRIPT_TASK string = "scriptTask"
+const BPMN_ELEMENT_SEND_TASK string = "sendTask"
+const BPMN_ELEMENT_TASK string = "task"
+const BPMN_ELEMENT_INTERMEDIATE_THROW_EVENT string = "intermediateThrowEvent"
+const BPMN_ELEMENT_INTERMEDIATE_CATCH_EVENT string = "intermediateCatchEvent"
+
+// LaneNames maps the ID of each flow node in a lane to the name of the
+// innermost lane containing it.
+func (p BpmnProcess) LaneNames() map[string]string {
+	laneNames := make(map[string]string)
+	var addLanes func(lanes []BpmnLane)
+	addLanes = func(lanes []BpmnLane) {
+		for _, lane := range lanes {
+			for _, flowNodeRef := range lane.FlowNodeRefs {
+				laneNames[flowNodeRef] = lane.Name
+			}
+			// child lanes are visited after their parent, so they take precedence
+			if lane.ChildLaneSet != nil {
+				addLanes(lane.ChildLaneSet.Lanes)
+			}
+		}
+	}
+	for _, laneSet := range p.LaneSets {
+		addLanes(laneSet.Lanes)
+	}
+	return laneNames
+}
+
+// Property returns the value of the first extension property with the
+// given name, and whether it was found.
+func (e *BpmnExtensionElements) Property(name string) (string, bool) {
+	if e == nil {
+		return "", false
+	}
+	for _, property := range e.Properties {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+	return "", false
+}
+
+// Input returns the input parameter with the given name, or nil if there is none.
+func (io *BpmnInputOutput) Input(name string) *BpmnParameter {
+	if io == nil {
+		return nil
+	}
+	for i := range io.InputParameters {
+		if io.InputParameters[i].Name == name {
+			return &io.InputParameters[i]
+		}
+	}
+	return nil
+}
+
+// Value returns the text of a parameter, or the body of its script.
+func (p *BpmnParameter) Value() string {
+	if p == nil {
+		return ""
+	}
+	if p.Script != nil {
+		return strings.TrimSpace(p.Script.Body)
+	}
+	return strings.TrimSpace(p.Text)
+}
+
+// HttpConnector returns the Camunda http-connector of a task, or nil if it has none.
+func (t BpmnTask) HttpConnector() *BpmnConnector {
+	if t.ExtensionElements == nil || t.ExtensionElements.Connector == nil {
+		return nil
+	}
+	if strings.TrimSpace(t.ExtensionElements.Connector.ConnectorId) != CAMUNDA_CONNECTOR_HTTP {
+		return nil
+	}
+	return t.ExtensionElements.Connector
 }
 
 // ReadBpmn reads a BPMN 2.0 XML document.
@@ -184,3 +184,242 @@ func TestReadBpmn(t *testing.T) {
 	assert.Equal(t, 2, len(bpmnDefinitions.Processes[0].ExclusiveGateway))
 	assert.Equal(t, 2, len(bpmnDefinitions.Processes[0].EndEvent))
 }
+
+func TestReadBpmnExtensionProperties(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Marked" isExecutable="true">
+    <bpmn:extensionElements>
+      <camunda:properties>
+        <camunda:property name="cacao:tlp" value="amber" />
+        <camunda:property name="cacao:statement" value="Copyright 2023 Example" />
+      </camunda:properties>
+    </bpmn:extensionElements>
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	extensionElements := bpmnDefinitions.Processes[0].ExtensionElements
+	if assert.NotNil(t, extensionElements) {
+		assert.Equal(t, []bpmn.BpmnProperty{
+			{Name: "cacao:tlp", Value: "amber"},
+			{Name: "cacao:statement", Value: "Copyright 2023 Example"},
+		}, extensionElements.Properties)
+	}
+}
+
+func TestLaneNames(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Lanes" isExecutable="true">
+    <bpmn:laneSet id="LaneSet_1">
+      <bpmn:lane id="Lane_1" name="SOC">
+        <bpmn:flowNodeRef>Activity_1</bpmn:flowNodeRef>
+        <bpmn:flowNodeRef>Activity_2</bpmn:flowNodeRef>
+        <bpmn:childLaneSet id="LaneSet_2">
+          <bpmn:lane id="Lane_2" name="Tier 2">
+            <bpmn:flowNodeRef>Activity_2</bpmn:flowNodeRef>
+          </bpmn:lane>
+        </bpmn:childLaneSet>
+      </bpmn:lane>
+      <bpmn:lane id="Lane_3" name="IT">
+        <bpmn:flowNodeRef>Activity_3</bpmn:flowNodeRef>
+      </bpmn:lane>
+    </bpmn:laneSet>
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	assert.Equal(t, map[string]string{
+		"Activity_1": "SOC",
+		"Activity_2": "Tier 2",
+		"Activity_3": "IT",
+	}, bpmnDefinitions.Processes[0].LaneNames())
+}
+
+func TestReadBpmnCamundaExtensions(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Camunda" isExecutable="true">
+    <bpmn:serviceTask id="Activity_1" name="Query the SIEM">
+      <bpmn:extensionElements>
+        <camunda:connector>
+          <camunda:inputOutput>
+            <camunda:inputParameter name="url">https://siem.example.com/api/search</camunda:inputParameter>
+            <camunda:inputParameter name="headers">
+              <camunda:map>
+                <camunda:entry key="Accept">application/json</camunda:entry>
+              </camunda:map>
+            </camunda:inputParameter>
+          </camunda:inputOutput>
+          <camunda:connectorId>http-connector</camunda:connectorId>
+        </camunda:connector>
+        <camunda:inputOutput>
+          <camunda:inputParameter name="query">
+            <camunda:script scriptFormat="javascript">"host:" + host</camunda:script>
+          </camunda:inputParameter>
+          <camunda:outputParameter name="hits">${response}</camunda:outputParameter>
+        </camunda:inputOutput>
+      </bpmn:extensionElements>
+    </bpmn:serviceTask>
+    <bpmn:serviceTask id="Activity_2" name="Enrich" camunda:type="external" camunda:topic="enrichment" />
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	tasks := bpmnDefinitions.Processes[0].ServiceTask
+	connector := tasks[0].HttpConnector()
+	if assert.NotNil(t, connector) {
+		assert.Equal(t, "https://siem.example.com/api/search", connector.InputOutput.Input("url").Value())
+		assert.Equal(t, []bpmn.BpmnEntry{{Key: "Accept", Value: "application/json"}}, connector.InputOutput.Input("headers").Map.Entries)
+		assert.Nil(t, connector.InputOutput.Input("method"))
+	}
+	inputOutput := tasks[0].ExtensionElements.InputOutput
+	assert.Equal(t, `"host:" + host`, inputOutput.Input("query").Value())
+	assert.Equal(t, "javascript", inputOutput.Input("query").Script.ScriptFormat)
+	assert.Equal(t, "${response}", inputOutput.OutputParameters[0].Value())
+	assert.Nil(t, tasks[1].HttpConnector())
+	assert.Equal(t, "external", tasks[1].CamundaType)
+	assert.Equal(t, "enrichment", tasks[1].CamundaTopic)
+}
+
+func TestReadBpmnZeebeExtensions(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:zeebe="http://camunda.org/schema/zeebe/1.0" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Zeebe" isExecutable="true">
+    <bpmn:serviceTask id="Activity_1" name="Score the alert">
+      <bpmn:extensionElements>
+        <zeebe:taskDefinition type="alert-scoring" retries="3" />
+        <zeebe:ioMapping>
+          <zeebe:input source="=alert.ip" target="address" />
+          <zeebe:output source="=result.score" target="score" />
+        </zeebe:ioMapping>
+        <zeebe:taskHeaders>
+          <zeebe:header key="model" value="v2" />
+        </zeebe:taskHeaders>
+      </bpmn:extensionElements>
+    </bpmn:serviceTask>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="Gateway_1" targetRef="Activity_1">
+      <bpmn:conditionExpression>= score &gt;= 5</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	process := bpmnDefinitions.Processes[0]
+	extensionElements := process.ServiceTask[0].ExtensionElements
+	assert.Equal(t, &bpmn.BpmnTaskDefinition{Type: "alert-scoring", Retries: "3"}, extensionElements.TaskDefinition)
+	assert.Equal(t, []bpmn.BpmnMapping{{Source: "=alert.ip", Target: "address"}}, extensionElements.IoMapping.Inputs)
+	assert.Equal(t, []bpmn.BpmnMapping{{Source: "=result.score", Target: "score"}}, extensionElements.IoMapping.Outputs)
+	assert.Equal(t, []bpmn.BpmnTaskHeader{{Key: "model", Value: "v2"}}, extensionElements.TaskHeaders)
+	assert.Equal(t, "= score >= 5", process.SequenceFlow[0].ConditionExpression.Body)
+}
+
+func TestReadBpmnScriptTask(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Scripts" isExecutable="true">
+    <bpmn:scriptTask id="Activity_1" name="Collect logs" scriptFormat="bash">
+      <bpmn:script>tar czf logs.tgz /var/log</bpmn:script>
+    </bpmn:scriptTask>
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	task := bpmnDefinitions.Processes[0].ScriptTask[0]
+	assert.Equal(t, "bash", task.ScriptFormat)
+	assert.Equal(t, "tar czf logs.tgz /var/log", task.Script)
+}
+
+func TestReadBpmnData(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:itemDefinition id="ItemDefinition_1" structureRef="xsd:string" isCollection="true" />
+  <bpmn:dataStore id="DataStore_1" name="CMDB" />
+  <bpmn:process id="Process_1" name="Data" isExecutable="true">
+    <bpmn:property id="Property_1" name="severity" itemSubjectRef="ItemDefinition_1" />
+    <bpmn:dataObject id="DataObject_1" />
+    <bpmn:dataObjectReference id="DataObjectReference_1" name="Alert" dataObjectRef="DataObject_1" />
+    <bpmn:dataStoreReference id="DataStoreReference_1" dataStoreRef="DataStore_1" />
+    <bpmn:task id="Activity_1" name="Triage">
+      <bpmn:ioSpecification>
+        <bpmn:dataInput id="DataInput_1" name="alert" />
+      </bpmn:ioSpecification>
+      <bpmn:dataInputAssociation id="DataInputAssociation_1">
+        <bpmn:sourceRef>DataObjectReference_1</bpmn:sourceRef>
+        <bpmn:targetRef>DataInput_1</bpmn:targetRef>
+      </bpmn:dataInputAssociation>
+      <bpmn:dataOutputAssociation id="DataOutputAssociation_1">
+        <bpmn:targetRef>DataStoreReference_1</bpmn:targetRef>
+      </bpmn:dataOutputAssociation>
+    </bpmn:task>
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	assert.Equal(t, []bpmn.BpmnItemDefinition{{Id: "ItemDefinition_1", StructureRef: "xsd:string", IsCollection: true}}, bpmnDefinitions.ItemDefinitions)
+	assert.Equal(t, []bpmn.BpmnDataElement{{Id: "DataStore_1", Name: "CMDB"}}, bpmnDefinitions.DataStores)
+	process := bpmnDefinitions.Processes[0]
+	assert.Equal(t, []bpmn.BpmnDataElement{{Id: "Property_1", Name: "severity", ItemSubjectRef: "ItemDefinition_1"}}, process.Properties)
+	assert.Equal(t, "DataObject_1", process.DataObjectReferences[0].DataObjectRef)
+	assert.Equal(t, "DataStore_1", process.DataStoreReferences[0].DataStoreRef)
+	task := process.Task[0]
+	assert.Equal(t, "alert", task.IoSpecification.DataInputs[0].Name)
+	assert.Equal(t, []bpmn.BpmnDataAssociation{{Id: "DataInputAssociation_1", SourceRefs: []string{"DataObjectReference_1"}, TargetRef: "DataInput_1"}}, task.DataInputAssociations)
+	assert.Equal(t, "DataStoreReference_1", task.DataOutputAssociations[0].TargetRef)
+}
+
+func TestReadBpmnMultipleFlows(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:task id="Activity_1">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+    </bpmn:task>
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	bpmnProcess := bpmnDefinitions.Processes[0]
+	assert.Equal(t, []string{"Flow_1", "Flow_2"}, bpmnProcess.StartEvent.Outgoing)
+	assert.Equal(t, []string{"Flow_1", "Flow_2"}, bpmnProcess.Task[0].Incoming)
+	assert.Equal(t, []string{"Flow_3"}, bpmnProcess.Task[0].Outgoing)
+}
+
+func TestReadBpmnUnknownElements(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:bpmndi="http://www.omg.org/spec/BPMN/20100524/DI" id="Definitions_1">
+  <bpmn:message id="Message_1" name="Alert" />
+  <bpmn:process id="Process_1" isExecutable="true">
+    <bpmn:task id="Activity_1" name="Known" />
+    <bpmn:callActivity id="Activity_2" name="Unknown" />
+  </bpmn:process>
+  <bpmndi:BPMNDiagram id="BPMNDiagram_1" />
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	unknown := bpmnDefinitions.Processes[0].Unknown
+	if assert.Len(t, unknown, 1) {
+		assert.Equal(t, "callActivity", unknown[0].XMLName.Local)
+		assert.Equal(t, "Activity_2", unknown[0].Id)
+		assert.Equal(t, "Unknown", unknown[0].Name)
+	}
+	if assert.Len(t, bpmnDefinitions.Unknown, 2) {
+		assert.Equal(t, "message", bpmnDefinitions.Unknown[0].XMLName.Local)
+		assert.Equal(t, bpmn.BPMN_DI_NAMESPACE, bpmnDefinitions.Unknown[1].XMLName.Space)
+	}
+}
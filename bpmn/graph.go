@@ -0,0 +1,116 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bpmn
+
+// FlowGraph indexes the sequence flows of a process by their source and
+// target, so that the flows leaving or entering a flow node can be found
+// without scanning every flow. Flows are kept in document order.
+type FlowGraph struct {
+	outgoing map[string][]BpmnSequenceFlow
+	incoming map[string][]BpmnSequenceFlow
+	flows    map[string]BpmnSequenceFlow
+	names    map[string]string
+	types    map[string]string
+}
+
+// NewFlowGraph indexes the sequence flows, and the names and element types
+// of the flow nodes, of a process.
+func NewFlowGraph(p BpmnProcess) *FlowGraph {
+	graph := &FlowGraph{
+		outgoing: make(map[string][]BpmnSequenceFlow),
+		incoming: make(map[string][]BpmnSequenceFlow),
+		flows:    make(map[string]BpmnSequenceFlow),
+		names:    make(map[string]string),
+		types:    make(map[string]string),
+	}
+	add := func(elementType, id, name string) {
+		graph.names[id] = name
+		graph.types[id] = elementType
+	}
+	for _, sequenceFlow := range p.SequenceFlow {
+		graph.outgoing[sequenceFlow.SourceRef] = append(graph.outgoing[sequenceFlow.SourceRef], sequenceFlow)
+		graph.incoming[sequenceFlow.TargetRef] = append(graph.incoming[sequenceFlow.TargetRef], sequenceFlow)
+		graph.flows[sequenceFlow.Id] = sequenceFlow
+		graph.types[sequenceFlow.Id] = BPMN_ELEMENT_SEQUENCE_FLOW
+	}
+	if p.StartEvent != nil {
+		add(BPMN_ELEMENT_START_EVENT, p.StartEvent.Id, p.StartEvent.Name)
+	}
+	for elementType, tasks := range map[string][]BpmnTask{
+		BPMN_ELEMENT_SERVICE_TASK:             p.ServiceTask,
+		BPMN_ELEMENT_USER_TASK:                p.UserTask,
+		BPMN_ELEMENT_MANUAL_TASK:              p.ManualTask,
+		BPMN_ELEMENT_SCRIPT_TASK:              p.ScriptTask,
+		BPMN_ELEMENT_SEND_TASK:                p.SendTask,
+		BPMN_ELEMENT_TASK:                     p.Task,
+		BPMN_ELEMENT_INTERMEDIATE_THROW_EVENT: p.IntermediateThrowEvent,
+		BPMN_ELEMENT_INTERMEDIATE_CATCH_EVENT: p.IntermediateCatchEvent,
+	} {
+		for _, task := range tasks {
+			add(elementType, task.Id, task.Name)
+		}
+	}
+	for elementType, gateways := range map[string][]BpmnGateway{
+		BPMN_ELEMENT_EXCLUSIVE_GATEWAY: p.ExclusiveGateway,
+		BPMN_ELEMENT_INCLUSIVE_GATEWAY: p.InclusiveGateway,
+		BPMN_ELEMENT_PARALLEL_GATEWAY:  p.ParallelGateway,
+	} {
+		for _, gateway := range gateways {
+			add(elementType, gateway.Id, gateway.Name)
+		}
+	}
+	for _, endEvent := range p.EndEvent {
+		add(BPMN_ELEMENT_END_EVENT, endEvent.Id, endEvent.Name)
+	}
+	return graph
+}
+
+// Outgoing returns the flows leaving a flow node.
+func (g *FlowGraph) Outgoing(id string) []BpmnSequenceFlow {
+	return g.outgoing[id]
+}
+
+// Incoming returns the flows entering a flow node.
+func (g *FlowGraph) Incoming(id string) []BpmnSequenceFlow {
+	return g.incoming[id]
+}
+
+// Next returns the target of the first flow leaving a flow node, or "" if
+// no flow leaves it.
+func (g *FlowGraph) Next(id string) string {
+	if outgoing := g.outgoing[id]; len(outgoing) > 0 {
+		return outgoing[0].TargetRef
+	}
+	return ""
+}
+
+// Flow returns the sequence flow with the given ID, and whether it exists.
+func (g *FlowGraph) Flow(id string) (BpmnSequenceFlow, bool) {
+	flow, found := g.flows[id]
+	return flow, found
+}
+
+// Name returns the name of a flow node.
+func (g *FlowGraph) Name(id string) string {
+	return g.names[id]
+}
+
+// Type returns the element type of a flow node or sequence flow, eg.
+// BPMN_ELEMENT_USER_TASK, or "" if it is not known.
+func (g *FlowGraph) Type(id string) string {
+	return g.types[id]
+}
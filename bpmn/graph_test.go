@@ -0,0 +1,66 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bpmn_test
+
+import (
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowGraph(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	graph := bpmn.NewFlowGraph(bpmnDefinitions.Processes[0])
+
+	assert.Equal(t, "Activity_18ru9dm", graph.Next("StartEvent_1"))
+	assert.Equal(t, "SOAR Processes AV/EDR Alert", graph.Name("Activity_18ru9dm"))
+	assert.Equal(t, "Does alert meet policy threshold for COA review?", graph.Name("Gateway_1hblfsj"))
+	outgoing := graph.Outgoing("Gateway_1hblfsj")
+	if assert.Len(t, outgoing, 2) {
+		// flows are kept in document order
+		assert.Equal(t, "Flow_1jkwvw5", outgoing[0].Id)
+		assert.Equal(t, "Flow_1g10y9a", outgoing[1].Id)
+	}
+	incoming := graph.Incoming("Activity_18ru9dm")
+	if assert.Len(t, incoming, 1) {
+		assert.Equal(t, "StartEvent_1", incoming[0].SourceRef)
+	}
+	flow, found := graph.Flow("Flow_1bgfopa")
+	assert.True(t, found)
+	assert.Equal(t, "Activity_18ru9dm", flow.TargetRef)
+	_, found = graph.Flow("Flow_missing")
+	assert.False(t, found)
+	assert.Empty(t, graph.Outgoing("Event_missing"))
+	assert.Equal(t, "", graph.Next("Event_missing"))
+}
+
+func TestFlowGraphTypes(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	graph := bpmn.NewFlowGraph(bpmnDefinitions.Processes[0])
+	assert.Equal(t, bpmn.BPMN_ELEMENT_START_EVENT, graph.Type("StartEvent_1"))
+	assert.Equal(t, bpmn.BPMN_ELEMENT_SERVICE_TASK, graph.Type("Activity_18ru9dm"))
+	assert.Equal(t, bpmn.BPMN_ELEMENT_EXCLUSIVE_GATEWAY, graph.Type("Gateway_1hblfsj"))
+	assert.Equal(t, bpmn.BPMN_ELEMENT_SEQUENCE_FLOW, graph.Type("Flow_1bgfopa"))
+	assert.Equal(t, "", graph.Type("Event_missing"))
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"strings"
+	"unicode"
+)
+
+// BranchClassifier decides which flow leaving a two-way gateway is the
+// positive branch, by classifying the labels of the flows as positive or
+// negative answers. ok is false if the label is not an answer, guessed is
+// true if the answer was inferred from part of the label only.
+type BranchClassifier interface {
+	Classify(label string) (positive bool, ok bool, guessed bool)
+}
+
+// BranchLabels lists words and phrases that label positive and negative
+// branches, and the words that negate them, eg. "not" in "not confirmed".
+type BranchLabels struct {
+	Positive  []string `json:"positive,omitempty"`
+	Negative  []string `json:"negative,omitempty"`
+	Negations []string `json:"negations,omitempty"`
+}
+
+// builtinBranchLabels are the labels known to every LabelClassifier, in
+// English, German, French, Spanish, Italian, Dutch and Portuguese
+var builtinBranchLabels = BranchLabels{
+	Positive: []string{
+		"yes", "y", "true", "ok", "confirmed", "valid", "approved", "accepted", "pass", "passed",
+		"success", "successful", "found", "match", "matched", "positive", "true positive", "detected",
+		"present", "malicious", "suspicious", "infected", "compromised", "vulnerable",
+		"ja", "wahr", "bestätigt", "gefunden", "bösartig",
+		"oui", "vrai", "confirmé", "trouvé", "malveillant",
+		"sí", "si", "verdadero", "confirmado", "encontrado", "malicioso",
+		"sì", "vero", "confermato", "trovato", "malevolo",
+		"waar", "bevestigd", "gevonden", "kwaadaardig",
+		"sim", "verdadeiro",
+	},
+	Negative: []string{
+		"no", "n", "false", "none", "denied", "rejected", "fail", "failed", "failure", "invalid",
+		"negative", "false positive", "not found", "absent", "missing", "benign", "clean", "safe",
+		"nein", "falsch", "harmlos", "sauber",
+		"non", "faux", "bénin", "sain",
+		"falso", "benigno", "limpio",
+		"nee", "onwaar", "onschuldig",
+		"não", "nao", "limpo",
+	},
+	Negations: []string{
+		"not", "no", "non", "never",
+		"nicht", "kein", "keine", "nie",
+		"pas", "ne", "jamais",
+		"nunca",
+		"niet", "geen",
+		"não", "nao",
+	},
+}
+
+// LabelClassifier classifies branch labels using lists of positive and
+// negative words. A label that is not listed as a whole is classified by
+// its remaining words after removing negations, each negation flipping the
+// answer, so "not confirmed" and "non-malicious" are negative. Failing
+// that, the answer is guessed from the listed words of the label, so
+// "Found nothing" is a guessed positive.
+type LabelClassifier struct {
+	answers   map[string]bool
+	negations map[string]bool
+}
+
+// NewLabelClassifier creates a classifier knowing the built-in labels and
+// any extra labels given
+func NewLabelClassifier(extra ...BranchLabels) *LabelClassifier {
+	classifier := &LabelClassifier{
+		answers:   make(map[string]bool),
+		negations: make(map[string]bool),
+	}
+	for _, labels := range append([]BranchLabels{builtinBranchLabels}, extra...) {
+		for _, label := range labels.Positive {
+			classifier.answers[normalizeLabel(label)] = true
+		}
+		for _, label := range labels.Negative {
+			classifier.answers[normalizeLabel(label)] = false
+		}
+		for _, negation := range labels.Negations {
+			classifier.negations[normalizeLabel(negation)] = true
+		}
+	}
+	return classifier
+}
+
+// normalizeLabel lower-cases a label, expands "n't" and separates its words by single spaces
+func normalizeLabel(label string) string {
+	label = strings.ReplaceAll(strings.ToLower(label), "n't", " not")
+	return strings.Join(strings.FieldsFunc(label, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// Classify implements BranchClassifier
+func (c *LabelClassifier) Classify(label string) (bool, bool, bool) {
+	normalized := normalizeLabel(label)
+	if normalized == "" {
+		return false, false, false
+	}
+	if answer, found := c.answers[normalized]; found {
+		return answer, true, false
+	}
+	negated := false
+	var rest []string
+	for _, word := range strings.Fields(normalized) {
+		if c.negations[word] {
+			negated = !negated
+		} else {
+			rest = append(rest, word)
+		}
+	}
+	if len(rest) == 0 {
+		// only negations, eg. "no no"
+		return !negated, negated, false
+	}
+	if answer, found := c.answers[strings.Join(rest, " ")]; found {
+		return answer != negated, true, false
+	}
+	// guess from the words of the label, if they agree
+	answer, classified := false, false
+	for _, word := range rest {
+		wordAnswer, wordFound := c.answers[word]
+		if !wordFound {
+			continue
+		}
+		if classified && wordAnswer != answer {
+			return false, false, false
+		}
+		answer, classified = wordAnswer, true
+	}
+	if !classified {
+		if !negated {
+			return false, false, false
+		}
+		// a negated statement, eg. "not escalated", is the negative branch
+		return false, true, true
+	}
+	return answer != negated, true, true
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelClassifier(t *testing.T) {
+	classifier := cacao.NewLabelClassifier()
+	testCases := map[string]bool{
+		"Yes":             true,
+		"N":               false,
+		"True":            true,
+		"Confirmed":       true,
+		"Not confirmed":   false,
+		"Non-malicious":   false,
+		"Malicious":       true,
+		"Benign":          false,
+		"no match":        false,
+		"Ja":              true,
+		"Nein":            false,
+		"Oui":             true,
+		"pas confirmé":    false,
+		"Sí":              true,
+		"nicht bestätigt": false,
+		"False positive":  false,
+	}
+	for label, expected := range testCases {
+		positive, ok, guessed := classifier.Classify(label)
+		if assert.True(t, ok, label) {
+			assert.Equal(t, expected, positive, label)
+			assert.False(t, guessed, label)
+		}
+	}
+	guesses := map[string]bool{
+		"not escalated":   false,
+		"isn't malicious": false,
+		"Found nothing":   true,
+		"Nothing found":   true,
+		"Clean up host":   false,
+	}
+	for label, expected := range guesses {
+		positive, ok, guessed := classifier.Classify(label)
+		if assert.True(t, ok, label) {
+			assert.Equal(t, expected, positive, label)
+			assert.True(t, guessed, label)
+		}
+	}
+	for _, label := range []string{"", "FILEHASH", "Escalate", "malicious but clean"} {
+		_, ok, _ := classifier.Classify(label)
+		assert.False(t, ok, label)
+	}
+	extended := cacao.NewLabelClassifier(cacao.BranchLabels{Positive: []string{"escalate"}, Negative: []string{"close"}})
+	positive, ok, _ := extended.Classify("Escalate")
+	assert.True(t, ok && positive)
+	positive, ok, _ = extended.Classify("Close")
+	assert.True(t, ok && !positive)
+}
+
+// onTrueName returns the name of the step the if condition step of a playbook branches to when its condition holds
+func onTrueName(t *testing.T, cacaoPlaybook *cacao.CacaoPlaybook) string {
+	for _, step := range cacaoPlaybook.Workflow {
+		if ifStep, ok := step.(*cacao.IfConditionStep); ok {
+			return cacaoPlaybook.Workflow[ifStep.OnTrue].Common().Name
+		}
+	}
+	t.Fatalf("no if condition step")
+	return ""
+}
+
+func TestConvertBranchLabels(t *testing.T) {
+	testCases := []struct {
+		labels  []string
+		onTrue  string
+		options []cacao.ConvertOption
+	}{
+		{[]string{"Not confirmed", "Confirmed"}, "Handle Confirmed", nil},
+		{[]string{"Benign", "Malicious"}, "Handle Malicious", nil},
+		{[]string{"Nein", "Ja"}, "Handle Ja", nil},
+		{[]string{"No", "Escalate"}, "Handle Escalate", nil},
+		{[]string{"Close", "Escalate"}, "Handle Escalate", []cacao.ConvertOption{cacao.WithMappingRules(&cacao.MappingRules{
+			BranchLabels: &cacao.BranchLabels{Positive: []string{"escalate"}},
+		})}},
+		{[]string{"Close", "Escalate"}, "Handle Close", []cacao.ConvertOption{cacao.WithBranchClassifier(cacao.NewLabelClassifier(cacao.BranchLabels{
+			Positive: []string{"close"},
+		}))}},
+	}
+	for _, testCase := range testCases {
+		bpmnDefinitions, err := bpmn.ReadBpmn([]byte(gatewayProcess(testCase.labels, -1)))
+		if err != nil {
+			t.Fatalf("could not read input: %s", err)
+		}
+		cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, testCase.options...)
+		if err != nil {
+			t.Fatalf("could not convert BPMN to Cacao: %s", err)
+		}
+		assert.Equal(t, testCase.onTrue, onTrueName(t, cacaoPlaybook), testCase.labels)
+		assert.Equal(t, "bool", cacaoPlaybook.PlaybookVariables["__kind_of_indicator__"].Type, testCase.labels)
+	}
+}
@@ -19,14 +19,12 @@ package cacao
 import (
 	"crypto"
 	_ "crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
-	"unicode"
 
 	"github.com/cydarm/bpmn-to-cacao/bpmn"
-	"github.com/golang/glog"
 	"github.com/google/uuid"
 )
 
@@ -34,6 +32,15 @@ const CACAO_NAMESPACE_UUID_STRING string = "aa7caf3a-d55a-4e9a-b34e-056215fba56a
 const CACAO_SPEC_VERSION_11 string = "1.1"
 const CACAO_SPEC_VERSION_20 string = "2.0"
 
+// the value of the spec_version property of CACAO 2.0 playbooks
+const CACAO_SPEC_VERSION_20_VALUE string = "cacao-2.0"
+
+// the value of the type property of a playbook
+const CACAO_TYPE_PLAYBOOK string = "playbook"
+
+// the value of the type property of an extension definition
+const CACAO_TYPE_EXTENSION_DEFINITION string = "extension-definition"
+
 // CACAO step types
 const CACAO_STEP_TYPE_START string = "start"
 const CACAO_STEP_TYPE_END string = "end"
@@ -41,11 +48,15 @@ const CACAO_STEP_TYPE_11_STEP string = "step"
 const CACAO_STEP_TYPE_ACTION string = "action"
 const CACAO_STEP_TYPE_11_SINGLE string = "single"
 const CACAO_STEP_TYPE_PLAYBOOK_ACTION string = "playbook-action"
+const CACAO_STEP_TYPE_11_PLAYBOOK string = "playbook"
 const CACAO_STEP_TYPE_PARALLEL string = "parallel"
 const CACAO_STEP_TYPE_IF_COND string = "if-condition"
 const CACAO_STEP_TYPE_SWITCH_COND string = "switch-condition"
 const CACAO_STEP_TYPE_WHILE_COND string = "while-condition"
 
+// the case of a switch condition step taken when no other case matches
+const SWITCH_CASE_DEFAULT string = "default"
+
 // CACAO command types
 const CACAO_COMMAND_TYPE_MANUAL string = "manual"
 const CACAO_COMMAND_TYPE_BASH string = "bash"
@@ -53,100 +64,235 @@ const CACAO_COMMAND_TYPE_HTTP string = "http-api"
 const CACAO_COMMAND_TYPE_SSH string = "ssh"
 const CACAO_COMMAND_TYPE_CALDERA string = "caldera-cmd"
 const CACAO_COMMAND_TYPE_ELASTIC string = "elastic"
-const CACAO_COMMAND_TYPE_JUPYTER string = "juptyer"
+const CACAO_COMMAND_TYPE_JUPYTER string = "jupyter"
 const CACAO_COMMAND_TYPE_KESTREL string = "kestrel"
 const CACAO_COMMAND_TYPE_OPENC2 string = "openc2-json"
+const CACAO_COMMAND_TYPE_OPENC2_HTTP string = "openc2-http"
+const CACAO_COMMAND_TYPE_POWERSHELL string = "powershell"
 const CACAO_COMMAND_TYPE_SIGMA string = "sigma"
 const CACAO_COMMAND_TYPE_YARA string = "yara"
 
 // CacaoPlaybook represents a CACAO playbook
 type CacaoPlaybook struct {
-	Type               string                      `json:"type"`
-	SpecVersion        string                      `json:"spec_version"`
-	ID                 string                      `json:"id"`
-	Name               string                      `json:"name"`
-	Description        string                      `json:"description,omitempty"`
-	PlaybookTypes      []string                    `json:"playbook_types,omitempty"`
-	CreatedBy          string                      `json:"created_by,omitempty"`
-	Created            *time.Time                  `json:"created"`
-	Modified           *time.Time                  `json:"modified"`
-	Revoked            bool                        `json:"revoked"`
-	ValidFrom          *time.Time                  `json:"valid_from,omitempty"`
-	ValidUntil         *time.Time                  `json:"valid_until,omitempty"`
-	DerivedFrom        string                      `json:"derived-from,omitempty"`
-	Priority           int                         `json:"priority"`
-	Severity           int                         `json:"severity"`
-	Impact             int                         `json:"impact"`
-	Labels             []string                    `json:"labels,omitempty"`
-	ExternalReferences []ExternalReference         `json:"external_references,omitempty"`
-	Markings           []string                    `json:"markings,omitempty"`
-	PlaybookVariables  map[string]PlaybookVariable `json:"playbook_variables,omitempty"`
-	WorkflowStart      string                      `json:"workflow_start"`
-	WorkflowException  string                      `json:"workflow_exception,omitempty"`
-	Workflow           map[string]Step             `json:"workflow"`
+	Type                   string                         `json:"type"`
+	SpecVersion            string                         `json:"spec_version"`
+	ID                     string                         `json:"id"`
+	Name                   string                         `json:"name"`
+	Description            string                         `json:"description,omitempty"`
+	PlaybookTypes          []string                       `json:"playbook_types,omitempty"`
+	CreatedBy              string                         `json:"created_by,omitempty"`
+	Created                *time.Time                     `json:"created,omitempty"`
+	Modified               *time.Time                     `json:"modified,omitempty"`
+	Revoked                bool                           `json:"revoked,omitempty"`
+	ValidFrom              *time.Time                     `json:"valid_from,omitempty"`
+	ValidUntil             *time.Time                     `json:"valid_until,omitempty"`
+	DerivedFrom            []string                       `json:"derived_from,omitempty"`
+	Priority               int                            `json:"priority,omitempty"`
+	Severity               int                            `json:"severity,omitempty"`
+	Impact                 int                            `json:"impact,omitempty"`
+	Labels                 []string                       `json:"labels,omitempty"`
+	ExternalReferences     []ExternalReference            `json:"external_references,omitempty"`
+	Markings               []string                       `json:"markings,omitempty"`
+	PlaybookVariables      map[string]PlaybookVariable    `json:"playbook_variables,omitempty"`
+	WorkflowStart          string                         `json:"workflow_start"`
+	WorkflowException      string                         `json:"workflow_exception,omitempty"`
+	Workflow               Workflow                       `json:"workflow"`
+	PlaybookExtensions     map[string]json.RawMessage     `json:"playbook_extensions,omitempty"`
+	AgentDefinitions       map[string]AgentTarget         `json:"agent_definitions,omitempty"`
+	TargetDefinitions      map[string]AgentTarget         `json:"target_definitions,omitempty"`
+	DataMarkingDefinitions map[string]DataMarking         `json:"data_marking_definitions,omitempty"`
+	ExtensionDefinitions   map[string]ExtensionDefinition `json:"extension_definitions,omitempty"`
+	Signatures             []Signature                    `json:"signatures,omitempty"`
+	// Extra holds properties that are not defined above, so that they
+	// survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (p *CacaoPlaybook) MarshalJSON() ([]byte, error) {
+	type plain CacaoPlaybook
+	return marshalWithExtra((*plain)(p), p.Extra)
+}
+
+func (p *CacaoPlaybook) UnmarshalJSON(data []byte) (err error) {
+	type plain CacaoPlaybook
+	p.Extra, err = unmarshalWithExtra(data, (*plain)(p))
+	return err
 }
 
 // ExternalReference represents an external reference embedded in a playbook
 type ExternalReference struct {
 	Name        string `json:"name"`
-	Description string `json:"description"`
-	Source      string `json:"source"`
-	URL         string `json:"url"`
-	Hash        string `json:"hash"`
-	ExternalID  string `json:"external_id"`
+	Description string `json:"description,omitempty"`
+	Source      string `json:"source,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	ExternalID  string `json:"external_id,omitempty"`
+	// Extra holds properties that are not defined above, eg. reference_id
+	// in CACAO 2.0, so that they survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (r ExternalReference) MarshalJSON() ([]byte, error) {
+	type plain ExternalReference
+	return marshalWithExtra((*plain)(&r), r.Extra)
+}
+
+func (r *ExternalReference) UnmarshalJSON(data []byte) (err error) {
+	type plain ExternalReference
+	r.Extra, err = unmarshalWithExtra(data, (*plain)(r))
+	return err
+}
+
+// AgentTarget represents an agent or target definition, eg. an individual
+// or an HTTP API. Only the common properties are modelled, the properties
+// specific to each type are kept in Extra.
+type AgentTarget struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Extra holds properties that are not defined above, so that they
+	// survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (a AgentTarget) MarshalJSON() ([]byte, error) {
+	type plain AgentTarget
+	return marshalWithExtra((*plain)(&a), a.Extra)
+}
+
+func (a *AgentTarget) UnmarshalJSON(data []byte) (err error) {
+	type plain AgentTarget
+	a.Extra, err = unmarshalWithExtra(data, (*plain)(a))
+	return err
+}
+
+// ExtensionDefinition represents the definition of the extensions that can
+// be added to steps and other objects of a CACAO 2.0 playbook
+type ExtensionDefinition struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	CreatedBy   string `json:"created_by"`
+	// Schema is the JSON schema of the extension, or a URL where it can be found
+	Schema             string              `json:"schema"`
+	Version            string              `json:"version"`
+	ExternalReferences []ExternalReference `json:"external_references,omitempty"`
+	// Extra holds properties that are not defined above, eg. created and
+	// modified, so that they survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (d ExtensionDefinition) MarshalJSON() ([]byte, error) {
+	type plain ExtensionDefinition
+	return marshalWithExtra((*plain)(&d), d.Extra)
+}
+
+func (d *ExtensionDefinition) UnmarshalJSON(data []byte) (err error) {
+	type plain ExtensionDefinition
+	d.Extra, err = unmarshalWithExtra(data, (*plain)(d))
+	return err
+}
+
+// addExtensionDefinition adds the definition of an extension to a playbook
+func addExtensionDefinition(cacaoPlaybook *CacaoPlaybook, extensionId string, definition ExtensionDefinition) {
+	if cacaoPlaybook.ExtensionDefinitions == nil {
+		cacaoPlaybook.ExtensionDefinitions = make(map[string]ExtensionDefinition)
+	}
+	cacaoPlaybook.ExtensionDefinitions[extensionId] = definition
+}
+
+// setExtension sets an extension of a playbook or step, creating the map of
+// extensions if needed
+func setExtension(extensions *map[string]json.RawMessage, extensionId string, value interface{}) {
+	data, _ := json.Marshal(value)
+	if *extensions == nil {
+		*extensions = make(map[string]json.RawMessage)
+	}
+	(*extensions)[extensionId] = data
 }
 
 // PlaybookVariable represents a variable that can be used in the playbook
 type PlaybookVariable struct {
 	Type        string `json:"type"`
-	Description string `json:"description"`
-	Value       string `json:"value"`
-	Constant    bool   `json:"constant"`
+	Description string `json:"description,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Constant    bool   `json:"constant,omitempty"`
+	// Extra holds properties that are not defined above, eg. external in
+	// CACAO 2.0, so that they survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-// Step represents a step in the workflow
-type Step struct {
-	Type         string              `json:"type"`
-	Name         string              `json:"name,omitempty"`
-	OnCompletion string              `json:"on_completion,omitempty"`
-	Condition    string              `json:"condition,omitempty"`
-	OnTrue       string              `json:"on_true,omitempty"`
-	OnFalse      string              `json:"on_false,omitempty"`
-	Switch       string              `json:"switch,omitempty"`
-	Cases        map[string][]string `json:"cases,omitempty"`
-	NextSteps    []string            `json:"next_steps,omitempty"`
-	Commands     []Command           `json:"commands,omitempty"`
-	InArgs       []string            `json:"in_args,omitempty"`
+func (v PlaybookVariable) MarshalJSON() ([]byte, error) {
+	type plain PlaybookVariable
+	return marshalWithExtra((*plain)(&v), v.Extra)
 }
 
-// Command represents a command that can be executed
-type Command struct {
-	Type        string `json:"type"`
-	Command     string `json:"command"`
-	Description string `json:"description"`
+func (v *PlaybookVariable) UnmarshalJSON(data []byte) (err error) {
+	type plain PlaybookVariable
+	v.Extra, err = unmarshalWithExtra(data, (*plain)(v))
+	return err
+}
+
+// the identity recorded as the creator of generated playbooks
+var defaultCreatedBy = fmt.Sprintf("identity--%s", deterministicUuid("bpmn-to-cacao"))
+
+// deterministicUuid derives a UUID from a name in the CACAO namespace, so
+// that converting the same BPMN always yields the same IDs
+func deterministicUuid(name string) uuid.UUID {
+	return uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(name), 5)
+}
+
+// synthesizedStepId returns the ID of a step that has no BPMN counterpart,
+// derived from the BPMN element that required it and its role
+func synthesizedStepId(stepType, sourceId, role string) string {
+	return fmt.Sprintf("%s--%s", stepType, deterministicUuid(fmt.Sprintf("%s:%s", sourceId, role)))
+}
+
+// NormalizeSpecVersion maps the spec_version property of a playbook to the
+// corresponding CACAO_SPEC_VERSION constant
+func NormalizeSpecVersion(specVersion string) string {
+	if specVersion == CACAO_SPEC_VERSION_20_VALUE {
+		return CACAO_SPEC_VERSION_20
+	}
+	return specVersion
+}
+
+// specVersionValue returns the spec_version property for a CACAO_SPEC_VERSION constant
+func specVersionValue(specVersion string) string {
+	if specVersion == CACAO_SPEC_VERSION_20 {
+		return CACAO_SPEC_VERSION_20_VALUE
+	}
+	return specVersion
+}
+
+// newEndStep creates an end step
+func newEndStep() *EndStep {
+	return &EndStep{
+		StepCommon: StepCommon{
+			Type: CACAO_STEP_TYPE_END,
+			Name: "End",
+		},
+	}
 }
 
 // ProcessTasks processes the tasks in the BPMN and creates the appropriate steps
-func ProcessTask(task bpmn.BpmnTask, commandType string, specVersion string, stepMap, nextStepMap map[string]string, cacaoPlaybook *CacaoPlaybook) {
-	taskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(task.Id), 5)
+func ProcessTask(task bpmn.BpmnTask, specVersion string, stepMap map[string]string, graph *bpmn.FlowGraph, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	taskUuid := deterministicUuid(task.Id)
 	stepType := CACAO_STEP_TYPE_ACTION // default to action - TODO: add support for other types
 	if specVersion == CACAO_SPEC_VERSION_11 {
 		stepType = CACAO_STEP_TYPE_11_STEP
 	}
 	stepId := fmt.Sprintf("%s--%s", stepType, taskUuid)
-	onCompletion := stepMap[nextStepMap[fmt.Sprintf("%s:0", task.Id)]]
+	onCompletion := nextStep(task.Id, specVersion, stepMap, graph, report, cacaoPlaybook)
 	if onCompletion == "" {
 		// create another end task and link it
 		endStepType := CACAO_STEP_TYPE_END
 		if specVersion == CACAO_SPEC_VERSION_11 {
 			endStepType = CACAO_STEP_TYPE_11_STEP
 		}
-		endEventUuid := uuid.New()
-		stepId := fmt.Sprintf("%s--%s", endStepType, endEventUuid)
-		cacaoPlaybook.Workflow[stepId] = Step{
-			Type: CACAO_STEP_TYPE_END,
-			Name: "End",
-		}
+		stepId := synthesizedStepId(endStepType, task.Id, "end")
+		cacaoPlaybook.Workflow[stepId] = newEndStep()
+		report.synthesize(stepId, task.Id, "the element has no outgoing flow leading to a step")
 		onCompletion = stepId
 	}
 	internalStepType := CACAO_STEP_TYPE_ACTION
@@ -154,25 +300,52 @@ func ProcessTask(task bpmn.BpmnTask, commandType string, specVersion string, ste
 		internalStepType = CACAO_STEP_TYPE_11_SINGLE
 	}
 	if cacaoPlaybook.WorkflowStart == stepId {
-		internalStepType = CACAO_STEP_TYPE_START
-	}
-	cacaoPlaybook.Workflow[stepId] = Step{
-		Type:         internalStepType,
-		Name:         task.Name,
-		OnCompletion: onCompletion,
-		Commands: []Command{
-			{
-				Type:        commandType,
-				Command:     task.Name,
-				Description: task.Documentation,
+		cacaoPlaybook.Workflow[stepId] = &StartStep{
+			StepCommon: StepCommon{
+				Type:         CACAO_STEP_TYPE_START,
+				Name:         task.Name,
+				OnCompletion: onCompletion,
 			},
+		}
+		return
+	}
+	command, err := newTaskCommand(specVersion, task, report)
+	if err != nil {
+		report.errorf(DIAGNOSTIC_CODE_COMMAND_FALLBACK, task.Id, stepId, "edit the command of the step", "%s, falling back to a manual command", err)
+		command = Command{
+			Type:        CACAO_COMMAND_TYPE_MANUAL,
+			Command:     task.Name,
+			Description: task.Documentation,
+		}
+	}
+	step := &ActionStep{
+		StepCommon: StepCommon{
+			Type:         internalStepType,
+			Name:         task.Name,
+			OnCompletion: onCompletion,
 		},
+		Commands: []Command{command},
 	}
+	cacaoPlaybook.Workflow[stepId] = step
+}
+
+// flowTarget returns the step a flow leaving a gateway leads to, creating an
+// end step with the given role for flows leading to elements without a step
+func flowTarget(gatewayId string, flow bpmn.BpmnSequenceFlow, role, endStepType string, stepMap map[string]string, report *Report, cacaoPlaybook *CacaoPlaybook) string {
+	if stepId := stepMap[flow.TargetRef]; stepId != "" {
+		return stepId
+	}
+	stepId := synthesizedStepId(endStepType, gatewayId, role)
+	if _, found := cacaoPlaybook.Workflow[stepId]; !found {
+		cacaoPlaybook.Workflow[stepId] = newEndStep()
+		report.synthesize(stepId, gatewayId, missingTargetReason(flow))
+	}
+	return stepId
 }
 
 // ProcessGateway processes a gateway and creates the appropriate steps
-func ProcessGateway(gateway bpmn.BpmnGateway, specVersion string, parallel bool, stepMap, nextStepMap map[string]string, cacaoPlaybook *CacaoPlaybook) {
-	gatewayUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(gateway.Id), 5)
+func ProcessGateway(gateway bpmn.BpmnGateway, specVersion string, parallel bool, stepMap map[string]string, graph *bpmn.FlowGraph, classifier BranchClassifier, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	gatewayUuid := deterministicUuid(gateway.Id)
 	parallelStepType := CACAO_STEP_TYPE_PARALLEL
 	ifStepType := CACAO_STEP_TYPE_IF_COND
 	switchStepType := CACAO_STEP_TYPE_SWITCH_COND
@@ -183,105 +356,93 @@ func ProcessGateway(gateway bpmn.BpmnGateway, specVersion string, parallel bool,
 		switchStepType = CACAO_STEP_TYPE_11_STEP
 		endStepType = CACAO_STEP_TYPE_11_STEP
 	}
+	outgoing := graph.Outgoing(gateway.Id)
+	targetStep := func(flow bpmn.BpmnSequenceFlow, role string) string {
+		return flowTarget(gateway.Id, flow, role, endStepType, stepMap, report, cacaoPlaybook)
+	}
 	if parallel {
 		stepId := fmt.Sprintf("%s--%s", parallelStepType, gatewayUuid)
-		step := Step{
-			Type: CACAO_STEP_TYPE_PARALLEL,
+		step := &ParallelStep{
+			StepCommon: StepCommon{
+				Type: CACAO_STEP_TYPE_PARALLEL,
+			},
 		}
-		for i := 0; i < len(gateway.Outgoing); i++ {
-			step.NextSteps = append(step.NextSteps, stepMap[nextStepMap[fmt.Sprintf("%s:%d", gateway.Id, i)]])
+		for _, flow := range outgoing {
+			step.NextSteps = append(step.NextSteps, stepMap[flow.TargetRef])
 		}
 		cacaoPlaybook.Workflow[stepId] = step
 		return
 	}
-	// mangle the name to make it a valid variable name
-	condition := strings.ReplaceAll(gateway.Name, " ", "_")
-	condition = strings.ToLower(condition)
-	condition = strings.Map(func(r rune) rune {
-		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' {
-			return r
-		}
-		return -1
-	}, condition)
+	condition := namer.gateway(gateway.Id, gateway.Name)
 	if cacaoPlaybook.PlaybookVariables == nil {
 		cacaoPlaybook.PlaybookVariables = make(map[string]PlaybookVariable)
 	}
-	if condition == "" {
-		condition = gateway.Id
-	}
 	gatewayName := gateway.Name
 	if gatewayName == "" {
 		gatewayName = gateway.Id
 	}
-	cacaoPlaybook.PlaybookVariables[condition] = PlaybookVariable{
-		Type:        "integer",
-		Description: gatewayName,
-		Value:       "0",
-		Constant:    false,
-	}
-	if len(gateway.Outgoing) == 2 {
+	decision := newGatewayDecision(gateway, graph, classifier, stepMap[gateway.Id], report)
+	cacaoPlaybook.PlaybookVariables[condition] = decision.variable(gatewayName)
+	if len(outgoing) == 2 {
 		stepId := fmt.Sprintf("%s--%s", ifStepType, gatewayUuid)
-		onTrue := stepMap[nextStepMap[fmt.Sprintf("%s:%s", gateway.Id, "YES")]]
-		if onTrue == "" {
-			// create another end task and link it
-			endEventUuid := uuid.New()
-			stepId := fmt.Sprintf("%s--%s", endStepType, endEventUuid)
-			cacaoPlaybook.Workflow[stepId] = Step{
-				Type: CACAO_STEP_TYPE_END,
-				Name: "End",
-			}
-			onTrue = stepId
-		}
-		onFalse := stepMap[nextStepMap[fmt.Sprintf("%s:%s", gateway.Id, "NO")]]
-		if onFalse == "" {
-			// create another end task and link it
-			endEventUuid := uuid.New()
-			stepId := fmt.Sprintf("%s--%s", endStepType, endEventUuid)
-			cacaoPlaybook.Workflow[stepId] = Step{
-				Type: CACAO_STEP_TYPE_END,
-				Name: "End",
-			}
-			onFalse = stepId
-		}
-		cacaoPlaybook.Workflow[stepId] = Step{
-			Type:      CACAO_STEP_TYPE_IF_COND,
-			Condition: fmt.Sprintf("%s == 1", condition),
-			InArgs:    []string{condition},
-			Name:      gatewayName,
-			OnTrue:    onTrue,
-			OnFalse:   onFalse,
+		cacaoPlaybook.Workflow[stepId] = &IfConditionStep{
+			StepCommon: StepCommon{
+				Type:   CACAO_STEP_TYPE_IF_COND,
+				Name:   gatewayName,
+				InArgs: []string{condition},
+			},
+			Condition: decision.condition(condition),
+			OnTrue:    targetStep(decision.onTrue, "on_true"),
+			OnFalse:   targetStep(decision.onFalse, "on_false"),
 		}
-	} else if len(gateway.Outgoing) > 2 {
+	} else if len(outgoing) > 2 {
 		stepId := fmt.Sprintf("%s--%s", switchStepType, gatewayUuid)
-		step := Step{
-			Type:   CACAO_STEP_TYPE_SWITCH_COND,
-			InArgs: []string{condition},
-			Name:   gatewayName,
-			Cases:  make(map[string][]string),
+		step := &SwitchConditionStep{
+			StepCommon: StepCommon{
+				Type:   CACAO_STEP_TYPE_SWITCH_COND,
+				Name:   gatewayName,
+				InArgs: []string{condition},
+			},
 			Switch: condition,
+			Cases:  make(map[string][]string),
 		}
-		for i := 0; i < len(gateway.Outgoing); i++ {
-			// find map key
-			for key, val := range nextStepMap {
-				if strings.HasPrefix(key, gateway.Id) {
-					nameString := strings.TrimPrefix(key, gateway.Id+":")
-					step.Cases[nameString] = []string{stepMap[val]}
-				}
+		for i, flow := range decision.flows {
+			if key := decision.keys[i]; key != "" {
+				step.Cases[key] = []string{targetStep(flow, flow.Id)}
 			}
 		}
+		if decision.defaultFlow != nil {
+			step.Cases[SWITCH_CASE_DEFAULT] = []string{targetStep(*decision.defaultFlow, decision.defaultFlow.Id)}
+		}
 		cacaoPlaybook.Workflow[stepId] = step
 	} else {
-		glog.Errorf("exclusive gateway %s has unexpected number of outgoing flows: %d", gateway.Id, len(gateway.Outgoing))
+		report.errorf(DIAGNOSTIC_CODE_FLOW_COUNT, gateway.Id, "", "remove the gateway or add outgoing flows", "gateway has %d outgoing flows, no step was created for it", len(outgoing))
 	}
 }
 
-// ConvertToCacao converts a BPMN definition to a CACAO playbook
-func ConvertToCacao(bpmnDefinition *bpmn.BpmnDefinitions, specVersion string) (*CacaoPlaybook, error) {
+// ConvertToCacao converts a BPMN definition to a CACAO playbook, logging the
+// problems found, see Convert
+func ConvertToCacao(bpmnDefinition *bpmn.BpmnDefinitions, specVersion string, options ...ConvertOption) (*CacaoPlaybook, error) {
+	cacaoPlaybook, report, err := Convert(bpmnDefinition, specVersion, options...)
+	if report != nil {
+		report.Log()
+	}
+	return cacaoPlaybook, err
+}
+
+// Convert converts a BPMN definition to a CACAO playbook. The report lists
+// the problems found in the BPMN, such as elements that could not be
+// converted and branches that had to be guessed; the playbook is created
+// regardless. An error is returned if no playbook can be created.
+func Convert(bpmnDefinition *bpmn.BpmnDefinitions, specVersion string, options ...ConvertOption) (*CacaoPlaybook, *Report, error) {
+	settings := newConvertOptions(options)
 	if len(bpmnDefinition.Processes) != 1 {
-		return nil, errors.New(fmt.Sprintf("unexpected number of process definitions: %d", len(bpmnDefinition.Processes)))
+		return nil, nil, errors.New(fmt.Sprintf("unexpected number of process definitions: %d", len(bpmnDefinition.Processes)))
 	}
+	report := &Report{Diagnostics: []Diagnostic{}}
 	bpmnProcess := bpmnDefinition.Processes[0]
-	playbookUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(bpmnProcess.Id), 5)
+	playbookUuid := deterministicUuid(bpmnProcess.Id)
+	graph := bpmn.NewFlowGraph(bpmnProcess)
 	// map the BPMN ID of each step to the CACAO ID
 	stepMap := make(map[string]string)
 	startStepType := CACAO_STEP_TYPE_START
@@ -305,12 +466,12 @@ func ConvertToCacao(bpmnDefinition *bpmn.BpmnDefinitions, specVersion string) (*
 	// process possible start events
 	startStepId := ""
 	if bpmnProcess.StartEvent != nil {
-		startEventUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(bpmnProcess.StartEvent.Id), 5)
+		startEventUuid := deterministicUuid(bpmnProcess.StartEvent.Id)
 		startStepId = fmt.Sprintf("%s--%s", startStepType, startEventUuid)
 		stepMap[bpmnProcess.StartEvent.Id] = startStepId
 	}
 	for _, task := range bpmnProcess.IntermediateCatchEvent {
-		taskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(task.Id), 5)
+		taskUuid := deterministicUuid(task.Id)
 		if startStepId != "" {
 			// if start step is set, treat this as an action
 			stepMap[task.Id] = fmt.Sprintf("%s--%s", actionStepType, taskUuid)
@@ -321,147 +482,158 @@ func ConvertToCacao(bpmnDefinition *bpmn.BpmnDefinitions, specVersion string) (*
 	}
 	// process end event
 	for _, endEvent := range bpmnProcess.EndEvent {
-		endEventUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(endEvent.Id), 5)
+		endEventUuid := deterministicUuid(endEvent.Id)
 		stepMap[endEvent.Id] = fmt.Sprintf("%s--%s", endStepType, endEventUuid)
 	}
 	// process tasks
 	for _, serviceTask := range bpmnProcess.ServiceTask {
-		serviceTaskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(serviceTask.Id), 5)
+		serviceTaskUuid := deterministicUuid(serviceTask.Id)
 		stepMap[serviceTask.Id] = fmt.Sprintf("%s--%s", actionStepType, serviceTaskUuid)
 	}
 	for _, userTask := range bpmnProcess.UserTask {
-		userTaskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(userTask.Id), 5)
+		userTaskUuid := deterministicUuid(userTask.Id)
 		stepMap[userTask.Id] = fmt.Sprintf("%s--%s", actionStepType, userTaskUuid)
 	}
 	for _, manualTask := range bpmnProcess.ManualTask {
-		manualTaskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(manualTask.Id), 5)
+		manualTaskUuid := deterministicUuid(manualTask.Id)
 		stepMap[manualTask.Id] = fmt.Sprintf("%s--%s", actionStepType, manualTaskUuid)
 	}
 	for _, userTask := range bpmnProcess.ScriptTask {
-		userTaskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(userTask.Id), 5)
+		userTaskUuid := deterministicUuid(userTask.Id)
 		stepMap[userTask.Id] = fmt.Sprintf("%s--%s", actionStepType, userTaskUuid)
 	}
 	for _, userTask := range bpmnProcess.SendTask {
-		userTaskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(userTask.Id), 5)
+		userTaskUuid := deterministicUuid(userTask.Id)
 		stepMap[userTask.Id] = fmt.Sprintf("%s--%s", actionStepType, userTaskUuid)
 	}
 	for _, task := range bpmnProcess.Task {
-		taskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(task.Id), 5)
+		taskUuid := deterministicUuid(task.Id)
 		stepMap[task.Id] = fmt.Sprintf("%s--%s", actionStepType, taskUuid)
 	}
 	for _, task := range bpmnProcess.IntermediateThrowEvent {
-		taskUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(task.Id), 5)
+		taskUuid := deterministicUuid(task.Id)
 		stepMap[task.Id] = fmt.Sprintf("%s--%s", actionStepType, taskUuid)
 	}
 	for _, endEvent := range bpmnProcess.EndEvent {
-		endEventUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(endEvent.Id), 5)
+		endEventUuid := deterministicUuid(endEvent.Id)
 		stepMap[endEvent.Id] = fmt.Sprintf("%s--%s", endStepType, endEventUuid)
 	}
 	for _, exclusiveGateway := range bpmnProcess.ExclusiveGateway {
-		exclusiveGatewayUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(exclusiveGateway.Id), 5)
-		if len(exclusiveGateway.Outgoing) == 2 {
+		exclusiveGatewayUuid := deterministicUuid(exclusiveGateway.Id)
+		outgoing := graph.Outgoing(exclusiveGateway.Id)
+		if len(outgoing) == 2 {
 			stepMap[exclusiveGateway.Id] = fmt.Sprintf("%s--%s", ifStepType, exclusiveGatewayUuid)
-		} else if len(exclusiveGateway.Outgoing) > 2 {
+		} else if len(outgoing) > 2 {
 			stepMap[exclusiveGateway.Id] = fmt.Sprintf("%s--%s", switchStepType, exclusiveGatewayUuid)
-		} else {
-			glog.Errorf("exclusive gateway %s has unexpected number of outgoing flows: %d", exclusiveGateway.Id, len(exclusiveGateway.Outgoing))
 		}
 	}
 	for _, parallelGateway := range bpmnProcess.ParallelGateway {
-		parallelGatewayUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(parallelGateway.Id), 5)
+		parallelGatewayUuid := deterministicUuid(parallelGateway.Id)
 		stepMap[parallelGateway.Id] = fmt.Sprintf("%s--%s", parallelStepType, parallelGatewayUuid)
 	}
 	for _, inclusiveGateway := range bpmnProcess.InclusiveGateway {
 		// TODO: add an if step for each outgoing flow
-		parallelGatewayUuid := uuid.NewHash(crypto.SHA256.New(), uuid.MustParse(CACAO_NAMESPACE_UUID_STRING), []byte(inclusiveGateway.Id), 5)
+		parallelGatewayUuid := deterministicUuid(inclusiveGateway.Id)
 		stepMap[inclusiveGateway.Id] = fmt.Sprintf("%s--%s", parallelStepType, parallelGatewayUuid)
 	}
-	// map the transitions, using BMPN ID and name (if present), to BPMN target,
-	// eg.
-	//     Activity_1g87yhd: -> Activity_0wagh2h
-	//     Gateway_1hblfsj:Yes -> Activity_0vuc752
-	//     Gateway_1g3qmkj:FILEHASH -> Event_0d4dl33
-	nextStepMap := make(map[string]string)
-	for _, sequenceFlow := range bpmnProcess.SequenceFlow {
-		var nextStepMapKey string
-		if sequenceFlow.Name != "" {
-			nextStepMapKey = fmt.Sprintf("%s:%s", sequenceFlow.SourceRef, strings.ToUpper(sequenceFlow.Name))
-		} else {
-			for i := 0; true; i++ {
-				// probe until we find an unused index
-				nextStepMapKey = fmt.Sprintf("%s:%d", sequenceFlow.SourceRef, i)
-				if _, found := nextStepMap[nextStepMapKey]; !found {
-					break
-				}
-			}
-		}
-		nextStepMap[nextStepMapKey] = sequenceFlow.TargetRef
-	}
-
 	// create the playbook
 	now := time.Now()
+	if settings.timestamp != nil {
+		now = *settings.timestamp
+	}
 	cacaoPlaybook := &CacaoPlaybook{
-		Type:          "playbook",
-		SpecVersion:   specVersion,
+		Type:          CACAO_TYPE_PLAYBOOK,
+		SpecVersion:   specVersionValue(specVersion),
 		ID:            fmt.Sprintf("playbook--%s", playbookUuid),
 		Name:          bpmnProcess.Name,
+		CreatedBy:     defaultCreatedBy,
 		Created:       &now,
 		Modified:      &now,
 		WorkflowStart: startStepId,
-		Workflow:      make(map[string]Step),
+		Workflow:      make(Workflow),
 	}
+	applyMarkings(cacaoPlaybook, append(append([]DataMarking{}, settings.markings...), processMarkings(bpmnProcess, report)...), now)
 
 	// create start steps
 	if bpmnProcess.StartEvent != nil {
 		startId := stepMap[bpmnProcess.StartEvent.Id]
-		cacaoPlaybook.Workflow[startId] = Step{
-			Type:         CACAO_STEP_TYPE_START,
-			Name:         bpmnProcess.StartEvent.Name,
-			OnCompletion: stepMap[nextStepMap[fmt.Sprintf("%s:0", bpmnProcess.StartEvent.Id)]],
+		cacaoPlaybook.Workflow[startId] = &StartStep{
+			StepCommon: StepCommon{
+				Type:         CACAO_STEP_TYPE_START,
+				Name:         bpmnProcess.StartEvent.Name,
+				OnCompletion: nextStep(bpmnProcess.StartEvent.Id, specVersion, stepMap, graph, report, cacaoPlaybook),
+			},
 		}
 	}
-	for _, task := range bpmnProcess.IntermediateCatchEvent {
-		ProcessTask(task, CACAO_COMMAND_TYPE_MANUAL, specVersion, stepMap, nextStepMap, cacaoPlaybook)
+	// processTasks creates the steps of tasks of a BPMN element type, using
+	// the mapping rules if one matches and the default command type otherwise
+	laneNames := bpmnProcess.LaneNames()
+	namer := newVariableNamer(specVersion, report)
+	processData := newProcessData(bpmnDefinition, bpmnProcess, specVersion, namer, report, cacaoPlaybook)
+	processTasks := func(elementType string, tasks []bpmn.BpmnTask, commandType string) {
+		for _, task := range tasks {
+			data := newMappingTemplateData(elementType, task, laneNames[task.Id])
+			rule := settings.mappingRules.match(data, task.ExtensionElements)
+			ProcessTask(task, specVersion, stepMap, graph, report, cacaoPlaybook)
+			step, ok := cacaoPlaybook.Workflow[stepMap[task.Id]].(*ActionStep)
+			if !ok {
+				continue
+			}
+			applyCamundaExtensions(task, step, namer, report, cacaoPlaybook)
+			applyZeebeExtensions(task, step, namer, report, cacaoPlaybook)
+			processData.apply(task, step, cacaoPlaybook)
+			if rule != nil {
+				rule.apply(settings.mappingRules, data, commandType, specVersion, stepMap[task.Id], step, report, cacaoPlaybook)
+			}
+		}
 	}
+	processTasks(bpmn.BPMN_ELEMENT_INTERMEDIATE_CATCH_EVENT, bpmnProcess.IntermediateCatchEvent, CACAO_COMMAND_TYPE_MANUAL)
 	// create end steps
 	for _, endEvent := range bpmnProcess.EndEvent {
 		endId := stepMap[endEvent.Id]
-		cacaoPlaybook.Workflow[endId] = Step{
-			Type: CACAO_STEP_TYPE_END,
-			Name: "End",
-		}
+		cacaoPlaybook.Workflow[endId] = newEndStep()
 	}
 	// create the action steps
-	for _, task := range bpmnProcess.ServiceTask {
-		ProcessTask(task, CACAO_COMMAND_TYPE_HTTP, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
-	for _, task := range bpmnProcess.UserTask {
-		ProcessTask(task, CACAO_COMMAND_TYPE_MANUAL, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
-	for _, task := range bpmnProcess.ManualTask {
-		ProcessTask(task, CACAO_COMMAND_TYPE_MANUAL, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
-	for _, task := range bpmnProcess.ScriptTask {
-		ProcessTask(task, CACAO_COMMAND_TYPE_BASH, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
-	for _, task := range bpmnProcess.SendTask {
-		ProcessTask(task, CACAO_COMMAND_TYPE_BASH, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
-	for _, task := range bpmnProcess.Task {
-		ProcessTask(task, CACAO_COMMAND_TYPE_MANUAL, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
-	for _, task := range bpmnProcess.IntermediateThrowEvent {
-		ProcessTask(task, CACAO_COMMAND_TYPE_MANUAL, specVersion, stepMap, nextStepMap, cacaoPlaybook)
-	}
+	processTasks(bpmn.BPMN_ELEMENT_SERVICE_TASK, bpmnProcess.ServiceTask, CACAO_COMMAND_TYPE_HTTP)
+	processTasks(bpmn.BPMN_ELEMENT_USER_TASK, bpmnProcess.UserTask, CACAO_COMMAND_TYPE_MANUAL)
+	processTasks(bpmn.BPMN_ELEMENT_MANUAL_TASK, bpmnProcess.ManualTask, CACAO_COMMAND_TYPE_MANUAL)
+	processTasks(bpmn.BPMN_ELEMENT_SCRIPT_TASK, bpmnProcess.ScriptTask, CACAO_COMMAND_TYPE_BASH)
+	processTasks(bpmn.BPMN_ELEMENT_SEND_TASK, bpmnProcess.SendTask, CACAO_COMMAND_TYPE_BASH)
+	processTasks(bpmn.BPMN_ELEMENT_TASK, bpmnProcess.Task, CACAO_COMMAND_TYPE_MANUAL)
+	processTasks(bpmn.BPMN_ELEMENT_INTERMEDIATE_THROW_EVENT, bpmnProcess.IntermediateThrowEvent, CACAO_COMMAND_TYPE_MANUAL)
 	// create the branch steps
+	classifier := settings.branchClassifier
+	if classifier == nil {
+		classifier = NewLabelClassifier(settings.mappingRules.branchLabels()...)
+	}
 	for _, gateway := range bpmnProcess.ExclusiveGateway {
-		ProcessGateway(gateway, specVersion, false, stepMap, nextStepMap, cacaoPlaybook)
+		ProcessGateway(gateway, specVersion, false, stepMap, graph, classifier, namer, report, cacaoPlaybook)
 	}
 	for _, gateway := range bpmnProcess.ParallelGateway {
-		ProcessGateway(gateway, specVersion, true, stepMap, nextStepMap, cacaoPlaybook)
+		ProcessGateway(gateway, specVersion, true, stepMap, graph, classifier, namer, report, cacaoPlaybook)
 	}
 	for _, gateway := range bpmnProcess.InclusiveGateway {
-		ProcessGateway(gateway, specVersion, false, stepMap, nextStepMap, cacaoPlaybook)
+		ProcessGateway(gateway, specVersion, false, stepMap, graph, classifier, namer, report, cacaoPlaybook)
+	}
+	applyFlowConditions(bpmnProcess, specVersion, stepMap, graph, namer, report, cacaoPlaybook)
+	joinImplicitSplits(bpmnProcess, specVersion, report, cacaoPlaybook)
+	report.setInventory(inventory(bpmnDefinition, bpmnProcess, stepMap, graph, processData, cacaoPlaybook))
+	report.CrossReferences = crossReferences(graph, report, cacaoPlaybook)
+	if settings.crossReferenceExtension {
+		if specVersion == CACAO_SPEC_VERSION_20 {
+			embedCrossReferences(report.CrossReferences, cacaoPlaybook)
+		} else {
+			report.warnf(DIAGNOSTIC_CODE_UNSUPPORTED_FEATURE, bpmnProcess.Id, "", fmt.Sprintf("convert to CACAO %s", CACAO_SPEC_VERSION_20), "step extensions are only supported for CACAO %s, the cross-references were not embedded", CACAO_SPEC_VERSION_20)
+		}
+	}
+	if settings.provenance {
+		if specVersion == CACAO_SPEC_VERSION_20 {
+			if err := embedProvenance(report.CrossReferences, bpmnProcess.Id, settings.sourceFileHash, cacaoPlaybook); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			report.warnf(DIAGNOSTIC_CODE_UNSUPPORTED_FEATURE, bpmnProcess.Id, "", fmt.Sprintf("convert to CACAO %s", CACAO_SPEC_VERSION_20), "extensions are only supported for CACAO %s, the provenance was not embedded", CACAO_SPEC_VERSION_20)
+		}
 	}
-	return cacaoPlaybook, nil
+	return cacaoPlaybook, report, nil
 }
@@ -196,4 +196,11 @@ func TestConvertToCacao(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not marshal Cacao playbook: %s", err)
 	}
+	// tasks without a script or connector are manual commands, which is not a problem
+	_, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	assert.Empty(t, report.Warnings())
+	assert.Empty(t, report.Errors())
 }
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// Camunda http-connector input parameters
+const CAMUNDA_HTTP_URL string = "url"
+const CAMUNDA_HTTP_METHOD string = "method"
+const CAMUNDA_HTTP_HEADERS string = "headers"
+const CAMUNDA_HTTP_PAYLOAD string = "payload"
+
+// the prefix of the playbook labels recording the topics of external tasks
+const CAMUNDA_TOPIC_LABEL_PREFIX string = "camunda-topic:"
+
+// newConnectorCommand creates the HTTP API command described by the input
+// parameters of a Camunda http-connector. The method defaults to GET.
+func newConnectorCommand(specVersion string, task bpmn.BpmnTask, connector *bpmn.BpmnConnector, report *Report) (Command, error) {
+	url := connector.InputOutput.Input(CAMUNDA_HTTP_URL).Value()
+	if url == "" {
+		return Command{}, fmt.Errorf("the %s has no %s parameter", bpmn.CAMUNDA_CONNECTOR_HTTP, CAMUNDA_HTTP_URL)
+	}
+	method := connector.InputOutput.Input(CAMUNDA_HTTP_METHOD).Value()
+	if method == "" {
+		method = "GET"
+	}
+	var headers map[string][]string
+	if parameter := connector.InputOutput.Input(CAMUNDA_HTTP_HEADERS); parameter != nil {
+		if parameter.Map == nil {
+			report.warnf(DIAGNOSTIC_CODE_IGNORED_PARAMETER, task.Id, "", "give the headers as a camunda:map", "ignoring %s parameter of the %s that is not a map", CAMUNDA_HTTP_HEADERS, bpmn.CAMUNDA_CONNECTOR_HTTP)
+		} else {
+			headers = make(map[string][]string)
+			for _, entry := range parameter.Map.Entries {
+				headers[entry.Key] = append(headers[entry.Key], strings.TrimSpace(entry.Value))
+			}
+		}
+	}
+	payload := connector.InputOutput.Input(CAMUNDA_HTTP_PAYLOAD).Value()
+	return NewHttpApiCommand(specVersion, HttpRequestLine(method, url), headers, payload, task.Documentation)
+}
+
+// parameterVariable converts a Camunda parameter to a variable. Maps become
+// dictionaries and lists their JSON encoding, other values are kept as
+// written, including expressions such as ${ip}.
+func parameterVariable(parameter bpmn.BpmnParameter, description string) PlaybookVariable {
+	variable := PlaybookVariable{
+		Type:        "string",
+		Description: description,
+		Value:       parameter.Value(),
+	}
+	switch {
+	case parameter.Map != nil:
+		entries := make(map[string]string)
+		for _, entry := range parameter.Map.Entries {
+			entries[entry.Key] = strings.TrimSpace(entry.Value)
+		}
+		value, _ := json.Marshal(entries)
+		variable.Type = "dictionary"
+		variable.Value = string(value)
+	case parameter.List != nil:
+		values := make([]string, 0, len(parameter.List.Values))
+		for _, value := range parameter.List.Values {
+			values = append(values, strings.TrimSpace(value))
+		}
+		value, _ := json.Marshal(values)
+		variable.Value = string(value)
+	}
+	return variable
+}
+
+// applyCamundaExtensions adds the Camunda input parameters of a task to its
+// step as step variables and in_args, declares its output parameters as
+// playbook variables set through out_args, and labels the playbook with
+// the topic of an external task.
+func applyCamundaExtensions(task bpmn.BpmnTask, step *ActionStep, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	if task.CamundaTopic != "" {
+		label := CAMUNDA_TOPIC_LABEL_PREFIX + task.CamundaTopic
+		if !containsString(cacaoPlaybook.Labels, label) {
+			cacaoPlaybook.Labels = append(cacaoPlaybook.Labels, label)
+		}
+	}
+	if task.ExtensionElements == nil || task.ExtensionElements.InputOutput == nil {
+		return
+	}
+	for _, parameter := range task.ExtensionElements.InputOutput.InputParameters {
+		addStepInput(task, step, namer, report, parameter.Name, parameterVariable(parameter, fmt.Sprintf("Input parameter %s", parameter.Name)))
+	}
+	for _, parameter := range task.ExtensionElements.InputOutput.OutputParameters {
+		description := fmt.Sprintf("Output parameter %s of %s", parameter.Name, task.Name)
+		if value := parameter.Value(); value != "" {
+			description = fmt.Sprintf("%s, set from %s", description, value)
+		}
+		addStepOutput(task, step, namer, report, cacaoPlaybook, parameter.Name, parameterVariable(parameter, description))
+	}
+}
+
+// addStepInput adds a variable of a task to its step as a step variable
+// passed in in_args
+func addStepInput(task bpmn.BpmnTask, step *ActionStep, namer *variableNamer, report *Report, parameterName string, variable PlaybookVariable) {
+	if strings.TrimSpace(parameterName) == "" {
+		report.warnf(DIAGNOSTIC_CODE_IGNORED_PARAMETER, task.Id, "", "name the input", "ignoring an input without a name")
+		return
+	}
+	name := namer.processVariable(parameterName)
+	if step.StepVariables == nil {
+		step.StepVariables = make(map[string]PlaybookVariable)
+	}
+	step.StepVariables[name] = variable
+	step.InArgs = append(step.InArgs, name)
+}
+
+// addStepOutput adds a variable set by a task to the out_args of its step,
+// declaring it as a playbook variable unless it already is
+func addStepOutput(task bpmn.BpmnTask, step *ActionStep, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook, parameterName string, variable PlaybookVariable) {
+	if strings.TrimSpace(parameterName) == "" {
+		report.warnf(DIAGNOSTIC_CODE_IGNORED_PARAMETER, task.Id, "", "name the output", "ignoring an output without a name")
+		return
+	}
+	name := namer.processVariable(parameterName)
+	step.OutArgs = append(step.OutArgs, name)
+	if _, found := cacaoPlaybook.PlaybookVariables[name]; found {
+		return
+	}
+	if cacaoPlaybook.PlaybookVariables == nil {
+		cacaoPlaybook.PlaybookVariables = make(map[string]PlaybookVariable)
+	}
+	// the value is set when the step runs
+	variable.Value = ""
+	cacaoPlaybook.PlaybookVariables[name] = variable
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+const camundaProcessTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Camunda" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:serviceTask id="Activity_1" name="Block the address">
+      <bpmn:documentation>Ask the firewall to block the address</bpmn:documentation>
+      <bpmn:extensionElements>
+        <camunda:connector>
+          <camunda:inputOutput>
+            <camunda:inputParameter name="url">https://firewall.example.com/api/block</camunda:inputParameter>
+            <camunda:inputParameter name="method">post</camunda:inputParameter>
+            <camunda:inputParameter name="headers">
+              <camunda:map>
+                <camunda:entry key="Content-Type">application/json</camunda:entry>
+              </camunda:map>
+            </camunda:inputParameter>
+            <camunda:inputParameter name="payload">{"address": "${ip}"}</camunda:inputParameter>
+          </camunda:inputOutput>
+          <camunda:connectorId>http-connector</camunda:connectorId>
+        </camunda:connector>
+        <camunda:inputOutput>
+          <camunda:inputParameter name="ip">10.0.0.1</camunda:inputParameter>
+          <camunda:inputParameter name="tags">
+            <camunda:map>
+              <camunda:entry key="source">edr</camunda:entry>
+            </camunda:map>
+          </camunda:inputParameter>
+          <camunda:outputParameter name="Block ID">${response}</camunda:outputParameter>
+        </camunda:inputOutput>
+      </bpmn:extensionElements>
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:serviceTask>
+    <bpmn:serviceTask id="Activity_2" name="Enrich the alert" camunda:type="external" camunda:topic="enrichment">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+    </bpmn:serviceTask>
+    <bpmn:endEvent id="Event_1" name="End">
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Activity_2" />
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Activity_2" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestConvertCamundaExtensions(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(camundaProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		t.Run(specVersion, func(t *testing.T) {
+			cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+
+			block := stepByName(t, cacaoPlaybook, "Block the address")
+			command := block.Commands[0]
+			assert.Equal(t, cacao.CACAO_COMMAND_TYPE_HTTP, command.Type)
+			assert.Equal(t, "POST https://firewall.example.com/api/block", command.Command)
+			assert.Equal(t, map[string][]string{"Content-Type": {"application/json"}}, command.Headers)
+			assert.Equal(t, `{"address": "${ip}"}`, command.Content)
+			assert.Equal(t, "Ask the firewall to block the address", command.Description)
+			assert.Equal(t, []string{variableFor(specVersion, "ip"), variableFor(specVersion, "tags")}, block.InArgs)
+			assert.Equal(t, "10.0.0.1", block.StepVariables[variableFor(specVersion, "ip")].Value)
+			assert.Equal(t, "dictionary", block.StepVariables[variableFor(specVersion, "tags")].Type)
+			assert.JSONEq(t, `{"source": "edr"}`, block.StepVariables[variableFor(specVersion, "tags")].Value)
+			assert.Equal(t, []string{variableFor(specVersion, "block_id")}, block.OutArgs)
+			assert.Contains(t, cacaoPlaybook.PlaybookVariables, variableFor(specVersion, "block_id"))
+
+			// external tasks keep the default command and label the playbook with their topic
+			enrich := stepByName(t, cacaoPlaybook, "Enrich the alert")
+			assert.Equal(t, "Enrich the alert", enrich.Commands[0].Command)
+			assert.Contains(t, cacaoPlaybook.Labels, "camunda-topic:enrichment")
+
+			data, err := json.Marshal(cacaoPlaybook)
+			if err != nil {
+				t.Fatalf("could not marshal Cacao playbook: %s", err)
+			}
+			violations, err := cacao.ValidateSchema(data, specVersion)
+			assert.NoError(t, err)
+			assert.Empty(t, violations)
+		})
+	}
+}
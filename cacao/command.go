@@ -0,0 +1,290 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// Command represents a command that can be executed.
+// The populated fields depend on the command type, use the New*Command
+// constructors to build a command that is valid for a given spec version.
+type Command struct {
+	Type             string              `json:"type"`
+	Command          string              `json:"command,omitempty"`
+	CommandB64       string              `json:"command_b64,omitempty"`
+	Description      string              `json:"description,omitempty"`
+	Version          string              `json:"version,omitempty"`
+	PlaybookActivity string              `json:"playbook_activity,omitempty"`
+	Headers          map[string][]string `json:"headers,omitempty"`
+	Content          string              `json:"content,omitempty"`
+	ContentB64       string              `json:"content_b64,omitempty"`
+	// Extra holds properties that are not defined above, so that they
+	// survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (c Command) MarshalJSON() ([]byte, error) {
+	type plain Command
+	return marshalWithExtra((*plain)(&c), c.Extra)
+}
+
+func (c *Command) UnmarshalJSON(data []byte) (err error) {
+	type plain Command
+	c.Extra, err = unmarshalWithExtra(data, (*plain)(c))
+	return err
+}
+
+// commandRule describes the fields a command type accepts
+type commandRule struct {
+	b64Only   bool // the command must be supplied in command_b64
+	http      bool // headers and content are permitted
+	needsBody bool // content or content_b64 is required
+}
+
+// commandRules lists the supported command types for each spec version
+var commandRules = map[string]map[string]commandRule{
+	CACAO_SPEC_VERSION_11: {
+		CACAO_COMMAND_TYPE_MANUAL:  {},
+		CACAO_COMMAND_TYPE_BASH:    {},
+		CACAO_COMMAND_TYPE_HTTP:    {http: true},
+		CACAO_COMMAND_TYPE_SSH:     {},
+		CACAO_COMMAND_TYPE_CALDERA: {},
+		CACAO_COMMAND_TYPE_ELASTIC: {},
+		CACAO_COMMAND_TYPE_JUPYTER: {},
+		CACAO_COMMAND_TYPE_KESTREL: {},
+		CACAO_COMMAND_TYPE_OPENC2:  {},
+		CACAO_COMMAND_TYPE_SIGMA:   {},
+		CACAO_COMMAND_TYPE_YARA:    {},
+	},
+	CACAO_SPEC_VERSION_20: {
+		CACAO_COMMAND_TYPE_MANUAL:      {},
+		CACAO_COMMAND_TYPE_BASH:        {},
+		CACAO_COMMAND_TYPE_HTTP:        {http: true},
+		CACAO_COMMAND_TYPE_SSH:         {},
+		CACAO_COMMAND_TYPE_CALDERA:     {},
+		CACAO_COMMAND_TYPE_ELASTIC:     {},
+		CACAO_COMMAND_TYPE_JUPYTER:     {b64Only: true},
+		CACAO_COMMAND_TYPE_KESTREL:     {b64Only: true},
+		CACAO_COMMAND_TYPE_OPENC2_HTTP: {http: true, needsBody: true},
+		CACAO_COMMAND_TYPE_POWERSHELL:  {b64Only: true},
+		CACAO_COMMAND_TYPE_SIGMA:       {},
+		CACAO_COMMAND_TYPE_YARA:        {b64Only: true},
+	},
+}
+
+// Validate checks that the command has the fields required by its type
+func (c Command) Validate(specVersion string) error {
+	rules, found := commandRules[specVersion]
+	if !found {
+		return fmt.Errorf("unsupported CACAO spec version: %s", specVersion)
+	}
+	rule, found := rules[c.Type]
+	if !found {
+		return fmt.Errorf("command type %q is not supported by CACAO %s", c.Type, specVersion)
+	}
+	if c.Command == "" && c.CommandB64 == "" {
+		return fmt.Errorf("%s command requires command or command_b64", c.Type)
+	}
+	if rule.b64Only && c.Command != "" {
+		return fmt.Errorf("%s command must be supplied in command_b64", c.Type)
+	}
+	if c.CommandB64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.CommandB64); err != nil {
+			return fmt.Errorf("%s command_b64 is not valid base64: %s", c.Type, err)
+		}
+	}
+	if !rule.http && (len(c.Headers) > 0 || c.Content != "" || c.ContentB64 != "") {
+		return fmt.Errorf("%s command does not accept headers or content", c.Type)
+	}
+	if c.Content != "" && c.ContentB64 != "" {
+		return fmt.Errorf("%s command must not set both content and content_b64", c.Type)
+	}
+	if rule.needsBody && c.Content == "" && c.ContentB64 == "" {
+		return fmt.Errorf("%s command requires content or content_b64", c.Type)
+	}
+	return nil
+}
+
+// validated returns the command if it is valid for the spec version
+func validated(command Command, specVersion string) (Command, error) {
+	if err := command.Validate(specVersion); err != nil {
+		return Command{}, err
+	}
+	return command, nil
+}
+
+// NewManualCommand creates a command to be carried out by a human
+func NewManualCommand(specVersion, command, description string) (Command, error) {
+	return validated(Command{
+		Type:        CACAO_COMMAND_TYPE_MANUAL,
+		Command:     command,
+		Description: description,
+	}, specVersion)
+}
+
+// NewBashCommand creates a bash command. CACAO 2.0 commands carry the script
+// in command_b64 so that multi-line scripts survive intact.
+func NewBashCommand(specVersion, script, description string) (Command, error) {
+	command := Command{
+		Type:        CACAO_COMMAND_TYPE_BASH,
+		Description: description,
+	}
+	if specVersion == CACAO_SPEC_VERSION_11 {
+		command.Command = script
+	} else {
+		command.CommandB64 = base64.StdEncoding.EncodeToString([]byte(script))
+	}
+	return validated(command, specVersion)
+}
+
+// NewPowershellCommand creates a PowerShell command, only supported by CACAO 2.0
+func NewPowershellCommand(specVersion, script, description string) (Command, error) {
+	return validated(Command{
+		Type:        CACAO_COMMAND_TYPE_POWERSHELL,
+		CommandB64:  base64.StdEncoding.EncodeToString([]byte(script)),
+		Description: description,
+	}, specVersion)
+}
+
+// NewSshCommand creates a command to be run over SSH
+func NewSshCommand(specVersion, command, description string) (Command, error) {
+	return validated(Command{
+		Type:        CACAO_COMMAND_TYPE_SSH,
+		Command:     command,
+		Description: description,
+	}, specVersion)
+}
+
+// NewJupyterCommand creates a command that runs a Jupyter notebook
+func NewJupyterCommand(specVersion string, notebook []byte, description string) (Command, error) {
+	command := Command{
+		Type:        CACAO_COMMAND_TYPE_JUPYTER,
+		CommandB64:  base64.StdEncoding.EncodeToString(notebook),
+		Description: description,
+	}
+	return validated(command, specVersion)
+}
+
+// NewKestrelCommand creates a command that runs a Kestrel hunt
+func NewKestrelCommand(specVersion, hunt, description string) (Command, error) {
+	command := Command{
+		Type:        CACAO_COMMAND_TYPE_KESTREL,
+		CommandB64:  base64.StdEncoding.EncodeToString([]byte(hunt)),
+		Description: description,
+	}
+	return validated(command, specVersion)
+}
+
+// HttpRequestLine formats an HTTP method and URL as used by the command
+// property of http-api and openc2-http commands, eg. "GET /api/v1/alerts"
+func HttpRequestLine(method, url string) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s", strings.ToUpper(method), url))
+}
+
+// NewHttpApiCommand creates an HTTP API command. The command is the request
+// line (see HttpRequestLine), content is the optional request body.
+func NewHttpApiCommand(specVersion, command string, headers map[string][]string, content, description string) (Command, error) {
+	return validated(Command{
+		Type:        CACAO_COMMAND_TYPE_HTTP,
+		Command:     command,
+		Headers:     headers,
+		Content:     content,
+		Description: description,
+	}, specVersion)
+}
+
+// NewOpenC2Command creates an OpenC2 command from a JSON serialisable body.
+// CACAO 2.0 sends the body as the content of an openc2-http command posted
+// to url, while CACAO 1.1 embeds it in an openc2-json command.
+func NewOpenC2Command(specVersion, url string, headers map[string][]string, body interface{}, description string) (Command, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return Command{}, fmt.Errorf("could not marshal OpenC2 body: %s", err)
+	}
+	if specVersion == CACAO_SPEC_VERSION_11 {
+		return validated(Command{
+			Type:        CACAO_COMMAND_TYPE_OPENC2,
+			Command:     string(bodyBytes),
+			Description: description,
+		}, specVersion)
+	}
+	if headers == nil {
+		headers = make(map[string][]string)
+	}
+	if _, found := headers["Content-Type"]; !found {
+		headers["Content-Type"] = []string{"application/openc2+json;version=1.0"}
+	}
+	return validated(Command{
+		Type:        CACAO_COMMAND_TYPE_OPENC2_HTTP,
+		Command:     HttpRequestLine("POST", url),
+		Headers:     headers,
+		Content:     string(bodyBytes),
+		Description: description,
+	}, specVersion)
+}
+
+// NewTaskCommand creates the command for a BPMN task. A task with a Camunda
+// http-connector becomes the HTTP API request the connector describes, and a
+// script task with a script runs it in the command type selected by its
+// scriptFormat. Any other task becomes a manual command giving its name, as
+// the name is not a request, script or other command an agent can run.
+// Problems that do not prevent creating the command are logged.
+func NewTaskCommand(specVersion string, task bpmn.BpmnTask) (Command, error) {
+	return newTaskCommand(specVersion, task, nil)
+}
+
+// newTaskCommand creates the command for a BPMN task as NewTaskCommand does,
+// adding problems to the report
+func newTaskCommand(specVersion string, task bpmn.BpmnTask, report *Report) (Command, error) {
+	if hasScript(task) {
+		return newScriptCommand(specVersion, task)
+	}
+	if connector := task.HttpConnector(); connector != nil {
+		return newConnectorCommand(specVersion, task, connector, report)
+	}
+	return NewManualCommand(specVersion, task.Name, task.Documentation)
+}
+
+// newTextCommand creates a command of the given type from command text,
+// encoding it as the type requires
+func newTextCommand(commandType, specVersion, command, description string) (Command, error) {
+	switch commandType {
+	case CACAO_COMMAND_TYPE_MANUAL:
+		return NewManualCommand(specVersion, command, description)
+	case CACAO_COMMAND_TYPE_BASH:
+		return NewBashCommand(specVersion, command, description)
+	case CACAO_COMMAND_TYPE_POWERSHELL:
+		return NewPowershellCommand(specVersion, command, description)
+	case CACAO_COMMAND_TYPE_SSH:
+		return NewSshCommand(specVersion, command, description)
+	case CACAO_COMMAND_TYPE_KESTREL:
+		return NewKestrelCommand(specVersion, command, description)
+	case CACAO_COMMAND_TYPE_HTTP:
+		return NewHttpApiCommand(specVersion, command, nil, "", description)
+	}
+	return validated(Command{
+		Type:        commandType,
+		Command:     command,
+		Description: description,
+	}, specVersion)
+}
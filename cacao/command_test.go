@@ -0,0 +1,86 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBashCommand(t *testing.T) {
+	command11, err := cacao.NewBashCommand(cacao.CACAO_SPEC_VERSION_11, "echo hello", "say hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hello", command11.Command)
+	assert.Empty(t, command11.CommandB64)
+	command20, err := cacao.NewBashCommand(cacao.CACAO_SPEC_VERSION_20, "echo hello", "say hello")
+	assert.NoError(t, err)
+	assert.Empty(t, command20.Command)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("echo hello")), command20.CommandB64)
+}
+
+func TestNewPowershellCommand(t *testing.T) {
+	_, err := cacao.NewPowershellCommand(cacao.CACAO_SPEC_VERSION_11, "Get-Process", "")
+	assert.Error(t, err)
+	command, err := cacao.NewPowershellCommand(cacao.CACAO_SPEC_VERSION_20, "Get-Process", "")
+	assert.NoError(t, err)
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_POWERSHELL, command.Type)
+}
+
+func TestNewHttpApiCommand(t *testing.T) {
+	headers := map[string][]string{"Accept": {"application/json"}}
+	command, err := cacao.NewHttpApiCommand(cacao.CACAO_SPEC_VERSION_20, cacao.HttpRequestLine("get", "/api/alerts"), headers, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "GET /api/alerts", command.Command)
+	assert.Equal(t, headers, command.Headers)
+	_, err = cacao.NewHttpApiCommand(cacao.CACAO_SPEC_VERSION_20, "", headers, "", "")
+	assert.Error(t, err)
+}
+
+func TestNewOpenC2Command(t *testing.T) {
+	body := map[string]interface{}{"action": "deny", "target": map[string]string{"ipv4_net": "10.0.0.1"}}
+	command20, err := cacao.NewOpenC2Command(cacao.CACAO_SPEC_VERSION_20, "/openc2", nil, body, "")
+	assert.NoError(t, err)
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_OPENC2_HTTP, command20.Type)
+	assert.Equal(t, "POST /openc2", command20.Command)
+	assert.JSONEq(t, `{"action":"deny","target":{"ipv4_net":"10.0.0.1"}}`, command20.Content)
+	command11, err := cacao.NewOpenC2Command(cacao.CACAO_SPEC_VERSION_11, "/openc2", nil, body, "")
+	assert.NoError(t, err)
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_OPENC2, command11.Type)
+	assert.Empty(t, command11.Headers)
+}
+
+func TestCommandValidate(t *testing.T) {
+	assert.Error(t, cacao.Command{Type: cacao.CACAO_COMMAND_TYPE_BASH, Command: "ls", Content: "x"}.Validate(cacao.CACAO_SPEC_VERSION_20))
+	assert.Error(t, cacao.Command{Type: cacao.CACAO_COMMAND_TYPE_YARA, Command: "rule x {}"}.Validate(cacao.CACAO_SPEC_VERSION_20))
+	assert.Error(t, cacao.Command{Type: cacao.CACAO_COMMAND_TYPE_MANUAL, CommandB64: "not base64!"}.Validate(cacao.CACAO_SPEC_VERSION_20))
+	assert.Error(t, cacao.Command{Type: "unknown", Command: "x"}.Validate(cacao.CACAO_SPEC_VERSION_20))
+	assert.NoError(t, cacao.Command{Type: cacao.CACAO_COMMAND_TYPE_MANUAL, Command: "x"}.Validate(cacao.CACAO_SPEC_VERSION_11))
+}
+
+func TestNewTaskCommand(t *testing.T) {
+	task := bpmn.BpmnTask{Id: "Activity_1", Name: "Run script", Documentation: "docs"}
+	command, err := cacao.NewTaskCommand(cacao.CACAO_SPEC_VERSION_20, task)
+	assert.NoError(t, err)
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_MANUAL, command.Type)
+	assert.Equal(t, "Run script", command.Command)
+	assert.Empty(t, command.CommandB64)
+	assert.Equal(t, "docs", command.Description)
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// the agent-target type and category of the targets created for data stores
+const DATA_STORE_TARGET_TYPE string = "security-category"
+const DATA_STORE_TARGET_CATEGORY string = "database"
+
+// xsdVariableTypes maps XML schema types used as the structureRef of item
+// definitions to variable types
+var xsdVariableTypes = map[string]string{
+	"string":    "string",
+	"boolean":   "bool",
+	"int":       "integer",
+	"integer":   "integer",
+	"short":     "integer",
+	"byte":      "integer",
+	"long":      "long",
+	"float":     "float",
+	"double":    "float",
+	"decimal":   "float",
+	"anyuri":    "uri",
+	"hexbinary": "hexstring",
+}
+
+// nameVariableTypes infers the type of a variable from its name when its item
+// definition does not give one, the first matching pattern wins
+var nameVariableTypes = []struct {
+	pattern      *regexp.Regexp
+	variableType string
+}{
+	{regexp.MustCompile(`(?i)\bipv6\b`), "ipv6-addr"},
+	{regexp.MustCompile(`(?i)\b(ip|ipv4|ip[ _]address)\b`), "ipv4-addr"},
+	{regexp.MustCompile(`(?i)\bmac\b`), "mac-addr"},
+	{regexp.MustCompile(`(?i)\b(url|uri)s?\b`), "uri"},
+	{regexp.MustCompile(`(?i)sha-?256`), "sha256-hash"},
+	{regexp.MustCompile(`(?i)sha-?1\b`), "sha1-hash"},
+	{regexp.MustCompile(`(?i)\bmd5\b`), "md5-hash"},
+	{regexp.MustCompile(`(?i)\b(file)?hash(es)?\b`), "hash"},
+	{regexp.MustCompile(`(?i)\buuid\b`), "uuid"},
+}
+
+// bpmn.io appends the state of a data object to the name of its references, eg. "Alert [triaged]"
+var dataStatePattern = regexp.MustCompile(`\s*\[[^\]]*\]\s*$`)
+
+// inferVariableType returns the variable type of a data element from its
+// item definition, or failing that from its name, defaulting to string
+func inferVariableType(itemDefinitions map[string]bpmn.BpmnItemDefinition, element bpmn.BpmnDataElement, name string) string {
+	if itemDefinition, found := itemDefinitions[element.ItemSubjectRef]; found && !itemDefinition.IsCollection && !element.IsCollection {
+		structure := itemDefinition.StructureRef
+		if i := strings.LastIndex(structure, ":"); i >= 0 {
+			structure = structure[i+1:]
+		}
+		if variableType, found := xsdVariableTypes[strings.ToLower(structure)]; found {
+			return variableType
+		}
+	}
+	for _, candidate := range nameVariableTypes {
+		if candidate.pattern.MatchString(name) {
+			return candidate.variableType
+		}
+	}
+	return "string"
+}
+
+// processData holds the variables and targets created for the data of a process
+type processData struct {
+	itemDefinitions map[string]bpmn.BpmnItemDefinition
+	namer           *variableNamer
+	report          *Report
+	// variables maps the IDs of data objects, their references and
+	// properties to the name of their variable
+	variables map[string]string
+	// targets maps the IDs of data store references to the ID of their target
+	targets map[string]string
+}
+
+// newProcessData declares a playbook variable for each data object and
+// property of a process, and for CACAO 2.0 a target for each data store
+func newProcessData(bpmnDefinitions *bpmn.BpmnDefinitions, bpmnProcess bpmn.BpmnProcess, specVersion string, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) *processData {
+	data := &processData{
+		itemDefinitions: make(map[string]bpmn.BpmnItemDefinition),
+		namer:           namer,
+		report:          report,
+		variables:       make(map[string]string),
+		targets:         make(map[string]string),
+	}
+	for _, itemDefinition := range bpmnDefinitions.ItemDefinitions {
+		data.itemDefinitions[itemDefinition.Id] = itemDefinition
+	}
+	// bpmn.io names the references rather than the data objects
+	referenceNames := make(map[string]string)
+	for _, reference := range bpmnProcess.DataObjectReferences {
+		name := dataStatePattern.ReplaceAllString(reference.Name, "")
+		if _, found := referenceNames[reference.DataObjectRef]; !found && name != "" {
+			referenceNames[reference.DataObjectRef] = name
+		}
+	}
+	declare := func(element bpmn.BpmnDataElement, kind string) {
+		name := element.Name
+		if name == "" {
+			name = referenceNames[element.Id]
+		}
+		if name == "" {
+			name = element.Id
+		}
+		variable := namer.dataElement(element.Id, name)
+		data.variables[element.Id] = variable
+		if _, found := cacaoPlaybook.PlaybookVariables[variable]; found {
+			return
+		}
+		if cacaoPlaybook.PlaybookVariables == nil {
+			cacaoPlaybook.PlaybookVariables = make(map[string]PlaybookVariable)
+		}
+		cacaoPlaybook.PlaybookVariables[variable] = PlaybookVariable{
+			Type:        inferVariableType(data.itemDefinitions, element, name),
+			Description: fmt.Sprintf("%s %s", kind, name),
+		}
+	}
+	for _, property := range bpmnProcess.Properties {
+		declare(property, "Property")
+	}
+	for _, dataObject := range bpmnProcess.DataObjects {
+		declare(dataObject, "Data object")
+	}
+	for _, reference := range bpmnProcess.DataObjectReferences {
+		if variable, found := data.variables[reference.DataObjectRef]; found {
+			data.variables[reference.Id] = variable
+		}
+	}
+
+	dataStores := make(map[string]bpmn.BpmnDataElement)
+	for _, dataStore := range bpmnDefinitions.DataStores {
+		dataStores[dataStore.Id] = dataStore
+	}
+	for _, reference := range bpmnProcess.DataStoreReferences {
+		if specVersion != CACAO_SPEC_VERSION_20 {
+			report.warnf(DIAGNOSTIC_CODE_UNSUPPORTED_FEATURE, reference.Id, "", fmt.Sprintf("convert to CACAO %s", CACAO_SPEC_VERSION_20), "data store targets are only supported for CACAO %s", CACAO_SPEC_VERSION_20)
+			continue
+		}
+		// references to the same data store share a target
+		key, name := reference.Id, reference.Name
+		if dataStore, found := dataStores[reference.DataStoreRef]; found {
+			key = dataStore.Id
+			if dataStore.Name != "" {
+				name = dataStore.Name
+			}
+		}
+		if name == "" {
+			name = key
+		}
+		if cacaoPlaybook.TargetDefinitions == nil {
+			cacaoPlaybook.TargetDefinitions = make(map[string]AgentTarget)
+		}
+		category, _ := json.Marshal([]string{DATA_STORE_TARGET_CATEGORY})
+		data.targets[reference.Id] = addAgentTarget(cacaoPlaybook.TargetDefinitions, "data-store", key, AgentTarget{
+			Type:  DATA_STORE_TARGET_TYPE,
+			Name:  name,
+			Extra: map[string]json.RawMessage{"category": category},
+		})
+	}
+	return data
+}
+
+// apply adds the data a task reads through its data input associations to
+// the in_args of its step, and the data it writes to the out_args. Data
+// stores become targets of the step. Data inputs and outputs of the task
+// that are not associated with process data become step variables.
+func (d *processData) apply(task bpmn.BpmnTask, step *ActionStep, cacaoPlaybook *CacaoPlaybook) {
+	associated := make(map[string]bool)
+	for _, association := range task.DataInputAssociations {
+		associated[association.TargetRef] = true
+		for _, sourceRef := range association.SourceRefs {
+			d.use(sourceRef, &step.InArgs, step)
+		}
+	}
+	for _, association := range task.DataOutputAssociations {
+		for _, sourceRef := range association.SourceRefs {
+			associated[sourceRef] = true
+		}
+		d.use(association.TargetRef, &step.OutArgs, step)
+	}
+	if task.IoSpecification == nil {
+		return
+	}
+	for _, dataInput := range task.IoSpecification.DataInputs {
+		if associated[dataInput.Id] || dataInput.Name == "" {
+			continue
+		}
+		addStepInput(task, step, d.namer, d.report, dataInput.Name, PlaybookVariable{
+			Type:        inferVariableType(d.itemDefinitions, dataInput, dataInput.Name),
+			Description: fmt.Sprintf("Data input %s", dataInput.Name),
+		})
+	}
+	for _, dataOutput := range task.IoSpecification.DataOutputs {
+		if associated[dataOutput.Id] || dataOutput.Name == "" {
+			continue
+		}
+		addStepOutput(task, step, d.namer, d.report, cacaoPlaybook, dataOutput.Name, PlaybookVariable{
+			Type:        inferVariableType(d.itemDefinitions, dataOutput, dataOutput.Name),
+			Description: fmt.Sprintf("Data output %s of %s", dataOutput.Name, task.Name),
+		})
+	}
+}
+
+// use adds the variable of a data element to the given arguments of a step,
+// or its target to the targets of the step if it is a data store
+func (d *processData) use(ref string, args *[]string, step *ActionStep) {
+	if variable, found := d.variables[ref]; found {
+		if !containsString(*args, variable) {
+			*args = append(*args, variable)
+		}
+	} else if target, found := d.targets[ref]; found {
+		if !containsString(step.Targets, target) {
+			step.Targets = append(step.Targets, target)
+		}
+	}
+}
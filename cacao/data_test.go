@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+const dataProcessTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:xsd="http://www.w3.org/2001/XMLSchema" id="Definitions_1">
+  <bpmn:itemDefinition id="ItemDefinition_1" structureRef="xsd:boolean" />
+  <bpmn:dataStore id="DataStore_1" name="Asset inventory" />
+  <bpmn:process id="Process_1" name="Data" isExecutable="true">
+    <bpmn:property id="Property_1" name="Is malicious" itemSubjectRef="ItemDefinition_1" />
+    <bpmn:dataObject id="DataObject_1" />
+    <bpmn:dataObjectReference id="DataObjectReference_1" name="Source IP [received]" dataObjectRef="DataObject_1" />
+    <bpmn:dataObjectReference id="DataObjectReference_2" name="Source IP [enriched]" dataObjectRef="DataObject_1" />
+    <bpmn:dataObject id="DataObject_2" name="Verdict" />
+    <bpmn:dataObjectReference id="DataObjectReference_3" dataObjectRef="DataObject_2" />
+    <bpmn:dataStoreReference id="DataStoreReference_1" dataStoreRef="DataStore_1" />
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:task id="Activity_1" name="Look up the host">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+      <bpmn:ioSpecification>
+        <bpmn:dataInput id="DataInput_1" name="address" />
+        <bpmn:dataInput id="DataInput_2" name="Lookup URL" />
+        <bpmn:dataOutput id="DataOutput_1" name="hostname" />
+      </bpmn:ioSpecification>
+      <bpmn:dataInputAssociation id="DataInputAssociation_1">
+        <bpmn:sourceRef>DataObjectReference_1</bpmn:sourceRef>
+        <bpmn:targetRef>DataInput_1</bpmn:targetRef>
+      </bpmn:dataInputAssociation>
+      <bpmn:dataInputAssociation id="DataInputAssociation_2">
+        <bpmn:sourceRef>DataStoreReference_1</bpmn:sourceRef>
+        <bpmn:targetRef>DataInput_1</bpmn:targetRef>
+      </bpmn:dataInputAssociation>
+      <bpmn:dataOutputAssociation id="DataOutputAssociation_1">
+        <bpmn:targetRef>DataObjectReference_3</bpmn:targetRef>
+      </bpmn:dataOutputAssociation>
+    </bpmn:task>
+    <bpmn:endEvent id="Event_1" name="End">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestConvertDataObjects(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(dataProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		t.Run(specVersion, func(t *testing.T) {
+			cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			// the data object is named after its references, without their state
+			assert.Equal(t, "ipv4-addr", cacaoPlaybook.PlaybookVariables[variableFor(specVersion, "source_ip")].Type)
+			assert.Equal(t, "string", cacaoPlaybook.PlaybookVariables[variableFor(specVersion, "verdict")].Type)
+			assert.Equal(t, "bool", cacaoPlaybook.PlaybookVariables[variableFor(specVersion, "is_malicious")].Type, "the type of the item definition is used")
+
+			lookup := stepByName(t, cacaoPlaybook, "Look up the host")
+			assert.Equal(t, []string{variableFor(specVersion, "source_ip"), variableFor(specVersion, "lookup_url")}, lookup.InArgs)
+			assert.Equal(t, "uri", lookup.StepVariables[variableFor(specVersion, "lookup_url")].Type)
+			assert.Equal(t, []string{variableFor(specVersion, "verdict"), variableFor(specVersion, "hostname")}, lookup.OutArgs)
+			assert.Contains(t, cacaoPlaybook.PlaybookVariables, variableFor(specVersion, "hostname"))
+
+			if specVersion == cacao.CACAO_SPEC_VERSION_20 {
+				if assert.Len(t, lookup.Targets, 1) && assert.Contains(t, cacaoPlaybook.TargetDefinitions, lookup.Targets[0]) {
+					target := cacaoPlaybook.TargetDefinitions[lookup.Targets[0]]
+					assert.Equal(t, cacao.DATA_STORE_TARGET_TYPE, target.Type)
+					assert.Equal(t, "Asset inventory", target.Name)
+					assert.JSONEq(t, `["database"]`, string(target.Extra["category"]))
+				}
+			} else {
+				assert.Empty(t, lookup.Targets)
+			}
+
+			data, err := json.Marshal(cacaoPlaybook)
+			if err != nil {
+				t.Fatalf("could not marshal Cacao playbook: %s", err)
+			}
+			violations, err := cacao.ValidateSchema(data, specVersion)
+			assert.NoError(t, err)
+			assert.Empty(t, violations)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// gatewayDecision describes the decision made by an exclusive gateway:
+// either a question answered by its branches, held in a bool variable, or a choice between
+// named branches, held in a string variable whose values are the labels
+// of the outgoing flows
+type gatewayDecision struct {
+	binary bool
+	// flows are the outgoing flows, in document order
+	flows []bpmn.BpmnSequenceFlow
+	// keys are the values of the decision selecting each flow, "" for an
+	// unlabelled default flow which is only selected by the default case
+	keys []string
+	// onTrue and onFalse are the branches of a two-way gateway
+	onTrue, onFalse bpmn.BpmnSequenceFlow
+	defaultFlow     *bpmn.BpmnSequenceFlow
+}
+
+// GUESSED_BRANCH_FIX suggests how to avoid guessing the positive branch
+const GUESSED_BRANCH_FIX string = "label the outgoing flows with answers such as Yes and No, or add labels to branch_labels in the mapping rules"
+
+// flowLabel returns the label of a flow as used for switch cases and
+// enumerated values
+func flowLabel(flow bpmn.BpmnSequenceFlow) string {
+	return strings.ToUpper(strings.TrimSpace(flow.Name))
+}
+
+// newGatewayDecision works out the decision of an exclusive gateway from the
+// labels of its outgoing flows, reporting when it has to guess which branch
+// of a two-way gateway is the positive one
+func newGatewayDecision(gateway bpmn.BpmnGateway, graph *bpmn.FlowGraph, classifier BranchClassifier, stepId string, report *Report) *gatewayDecision {
+	decision := &gatewayDecision{flows: graph.Outgoing(gateway.Id)}
+	for i, flow := range decision.flows {
+		if flow.Id == gateway.Default {
+			decision.defaultFlow = &decision.flows[i]
+		}
+		decision.keys = append(decision.keys, decision.caseKey(gateway, graph, flow, stepId, report))
+	}
+	if len(decision.flows) != 2 {
+		return decision
+	}
+	first, second := decision.flows[0], decision.flows[1]
+	firstAnswer, firstIsAnswer, firstGuessed := classifier.Classify(first.Name)
+	secondAnswer, secondIsAnswer, secondGuessed := classifier.Classify(second.Name)
+	isDefault := func(flow bpmn.BpmnSequenceFlow) bool {
+		return decision.defaultFlow != nil && decision.defaultFlow.Id == flow.Id
+	}
+	switch {
+	case firstIsAnswer && secondIsAnswer && firstAnswer != secondAnswer:
+		decision.binary = true
+		decision.onTrue, decision.onFalse = first, second
+		if !firstAnswer {
+			decision.onTrue, decision.onFalse = second, first
+		}
+		if firstGuessed || secondGuessed {
+			report.warnf(DIAGNOSTIC_CODE_GUESSED_BRANCH, gateway.Id, stepId, GUESSED_BRANCH_FIX, "guessed from the words of flows %s (%q) and %s (%q) that flow %s is the positive branch", first.Id, first.Name, second.Id, second.Name, decision.onTrue.Id)
+		}
+	case firstIsAnswer != secondIsAnswer:
+		// one answer, the other flow is its opposite
+		answered, answer, guessed, other := first, firstAnswer, firstGuessed, second
+		if secondIsAnswer {
+			answered, answer, guessed, other = second, secondAnswer, secondGuessed, first
+		}
+		decision.binary = true
+		decision.onTrue, decision.onFalse = answered, other
+		if !answer {
+			decision.onTrue, decision.onFalse = other, answered
+		}
+		if flowLabel(other) != "" {
+			report.warnf(DIAGNOSTIC_CODE_GUESSED_BRANCH, gateway.Id, stepId, GUESSED_BRANCH_FIX, "guessed that flow %s (%q) is the opposite of flow %s (%q)", other.Id, other.Name, answered.Id, answered.Name)
+		} else if guessed {
+			report.warnf(DIAGNOSTIC_CODE_GUESSED_BRANCH, gateway.Id, stepId, GUESSED_BRANCH_FIX, "guessed from the words of flow %s (%q) that flow %s is the positive branch", answered.Id, answered.Name, decision.onTrue.Id)
+		}
+	case firstIsAnswer && secondIsAnswer:
+		decision.binary = true
+		decision.onTrue, decision.onFalse = first, second
+		if isDefault(first) {
+			decision.onTrue, decision.onFalse = second, first
+		}
+		report.warnf(DIAGNOSTIC_CODE_GUESSED_BRANCH, gateway.Id, stepId, GUESSED_BRANCH_FIX, "flows %s (%q) and %s (%q) give the same answer, guessed that flow %s is the positive branch", first.Id, first.Name, second.Id, second.Name, decision.onTrue.Id)
+	case flowLabel(first) == "" && flowLabel(second) == "":
+		// an unlabelled question, its default flow is the negative branch
+		decision.binary = true
+		decision.onTrue, decision.onFalse = first, second
+		if isDefault(first) {
+			decision.onTrue, decision.onFalse = second, first
+		}
+		if decision.defaultFlow == nil {
+			report.warnf(DIAGNOSTIC_CODE_GUESSED_BRANCH, gateway.Id, stepId, GUESSED_BRANCH_FIX, "the flows are not labelled, guessed that flow %s is the positive branch", decision.onTrue.Id)
+		}
+	default:
+		// a choice between named branches, tested for the first named
+		// branch that is not the default
+		decision.onTrue, decision.onFalse = first, second
+		if flowLabel(first) == "" || (isDefault(first) && flowLabel(second) != "") {
+			decision.onTrue, decision.onFalse = second, first
+		}
+	}
+	return decision
+}
+
+// caseKey returns the value of the decision selecting a flow: its label, or
+// failing that the upper-cased name of the element it leads to, or its ID.
+// Keys are made unique by numbering those already used.
+func (d *gatewayDecision) caseKey(gateway bpmn.BpmnGateway, graph *bpmn.FlowGraph, flow bpmn.BpmnSequenceFlow, stepId string, report *Report) string {
+	key := flowLabel(flow)
+	if key == "" {
+		if flow.Id == gateway.Default {
+			return ""
+		}
+		key = strings.ToUpper(strings.TrimSpace(graph.Name(flow.TargetRef)))
+	}
+	if key == "" {
+		key = flow.Id
+	}
+	if !containsString(d.keys, key) {
+		return key
+	}
+	unique := key
+	for i := 2; containsString(d.keys, unique); i++ {
+		unique = fmt.Sprintf("%s_%d", key, i)
+	}
+	report.warnf(DIAGNOSTIC_CODE_DUPLICATE_CASE, gateway.Id, stepId, "give each outgoing flow a different label", "flows share the label %q, flow %s is selected by %q", key, flow.Id, unique)
+	return unique
+}
+
+// key returns the value of the decision selecting an outgoing flow
+func (d *gatewayDecision) key(flow bpmn.BpmnSequenceFlow) string {
+	for i := range d.flows {
+		if d.flows[i].Id == flow.Id {
+			return d.keys[i]
+		}
+	}
+	return ""
+}
+
+// values returns the values of the decision selecting the outgoing flows, in order
+func (d *gatewayDecision) values() []string {
+	var values []string
+	for _, key := range d.keys {
+		if key != "" {
+			values = append(values, key)
+		}
+	}
+	return values
+}
+
+// variable returns the declaration of the variable holding the decision,
+// whose initial value is the one selecting the default flow
+func (d *gatewayDecision) variable(gatewayName string) PlaybookVariable {
+	if d.binary {
+		value := "false"
+		if d.defaultFlow != nil && d.defaultFlow.Id == d.onTrue.Id {
+			value = "true"
+		}
+		return PlaybookVariable{
+			Type:        "bool",
+			Description: gatewayName,
+			Value:       value,
+		}
+	}
+	value := ""
+	if d.defaultFlow != nil {
+		value = d.key(*d.defaultFlow)
+	}
+	description := gatewayName
+	if values := d.values(); len(values) > 0 {
+		description = fmt.Sprintf("%s, one of %s", gatewayName, strings.Join(values, ", "))
+	}
+	return PlaybookVariable{
+		Type:        "string",
+		Description: description,
+		Value:       value,
+	}
+}
+
+// condition returns the condition of the if condition step of a two-way
+// gateway, which holds when the decision selects the onTrue branch
+func (d *gatewayDecision) condition(variable string) string {
+	if d.binary {
+		return fmt.Sprintf("%s == true", variable)
+	}
+	return fmt.Sprintf("%s == %s", variable, strconv.Quote(d.key(d.onTrue)))
+}
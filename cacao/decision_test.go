@@ -0,0 +1,189 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatewayProcess returns a process with an exclusive gateway named "Kind of
+// indicator?" whose outgoing flows have the given labels and each lead to a
+// task named after their label. defaultFlow is the index of the default
+// flow, or -1 for none.
+func gatewayProcess(labels []string, defaultFlow int) string {
+	var elements, flows strings.Builder
+	gatewayDefault := ""
+	if defaultFlow >= 0 {
+		gatewayDefault = fmt.Sprintf(` default="Flow_%d"`, defaultFlow)
+	}
+	fmt.Fprintf(&elements, `    <bpmn:exclusiveGateway id="Gateway_1" name="Kind of indicator?"%s>
+      <bpmn:incoming>Flow_start</bpmn:incoming>
+`, gatewayDefault)
+	for i := range labels {
+		fmt.Fprintf(&elements, "      <bpmn:outgoing>Flow_%d</bpmn:outgoing>\n", i)
+	}
+	elements.WriteString("    </bpmn:exclusiveGateway>\n")
+	for i, label := range labels {
+		fmt.Fprintf(&elements, `    <bpmn:userTask id="Activity_%d" name="Handle %s">
+      <bpmn:incoming>Flow_%d</bpmn:incoming>
+      <bpmn:outgoing>Flow_end_%d</bpmn:outgoing>
+    </bpmn:userTask>
+`, i, label, i, i)
+		fmt.Fprintf(&flows, `    <bpmn:sequenceFlow id="Flow_%d" name="%s" sourceRef="Gateway_1" targetRef="Activity_%d" />
+    <bpmn:sequenceFlow id="Flow_end_%d" sourceRef="Activity_%d" targetRef="Event_1" />
+`, i, label, i, i, i)
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Decision" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_start</bpmn:outgoing>
+    </bpmn:startEvent>
+%s    <bpmn:endEvent id="Event_1" name="End" />
+    <bpmn:sequenceFlow id="Flow_start" sourceRef="StartEvent_1" targetRef="Gateway_1" />
+%s  </bpmn:process>
+</bpmn:definitions>`, elements.String(), flows.String())
+}
+
+func TestGatewayVariableTypes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		labels      []string
+		defaultFlow int
+		varType     string
+		value       string
+		condition   string
+		onTrue      string
+	}{
+		{"yes/no question", []string{"Yes", "No"}, 1, "bool", "false", "__kind_of_indicator__ == true", "Handle Yes"},
+		{"answers in any order", []string{"no", "yes"}, 1, "bool", "true", "__kind_of_indicator__ == true", "Handle yes"},
+		{"without a default", []string{"True", "False"}, -1, "bool", "false", "__kind_of_indicator__ == true", "Handle True"},
+		{"named branches", []string{"FILEHASH", "URL"}, 0, "string", "FILEHASH", `__kind_of_indicator__ == "URL"`, "Handle URL"},
+		{"switch", []string{"FILEHASH", "URL", "IP"}, 2, "string", "IP", "", ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bpmnDefinitions, err := bpmn.ReadBpmn([]byte(gatewayProcess(testCase.labels, testCase.defaultFlow)))
+			if err != nil {
+				t.Fatalf("could not read input: %s", err)
+			}
+			cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			variable := cacaoPlaybook.PlaybookVariables["__kind_of_indicator__"]
+			assert.Equal(t, testCase.varType, variable.Type)
+			assert.Equal(t, testCase.value, variable.Value)
+			for _, step := range cacaoPlaybook.Workflow {
+				switch typed := step.(type) {
+				case *cacao.IfConditionStep:
+					assert.Equal(t, testCase.condition, typed.Condition)
+					assert.Equal(t, testCase.onTrue, cacaoPlaybook.Workflow[typed.OnTrue].Common().Name)
+				case *cacao.SwitchConditionStep:
+					assert.Equal(t, "Kind of indicator?, one of FILEHASH, URL, IP", variable.Description)
+				}
+			}
+
+			data, err := json.Marshal(cacaoPlaybook)
+			if err != nil {
+				t.Fatalf("could not marshal Cacao playbook: %s", err)
+			}
+			violations, err := cacao.ValidateSchema(data, cacao.CACAO_SPEC_VERSION_20)
+			assert.NoError(t, err)
+			assert.Empty(t, violations)
+		})
+	}
+}
+
+func TestSwitchCases(t *testing.T) {
+	// Gateway_1 is a prefix of Gateway_10, and Gateway_10 has unnamed flows
+	// and an unnamed default flow
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Switches" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Start" />
+    <bpmn:exclusiveGateway id="Gateway_1" name="Kind of indicator?" default="Flow_1c" />
+    <bpmn:exclusiveGateway id="Gateway_10" name="Severity?" default="Flow_10c" />
+    <bpmn:userTask id="Activity_hash" name="Look up hash" />
+    <bpmn:userTask id="Activity_url" name="Look up URL" />
+    <bpmn:userTask id="Activity_ip" name="Look up IP" />
+    <bpmn:userTask id="Activity_high" name="Escalate" />
+    <bpmn:endEvent id="Event_1" name="End" />
+    <bpmn:sequenceFlow id="Flow_start" sourceRef="StartEvent_1" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_1a" name="FileHash" sourceRef="Gateway_1" targetRef="Activity_hash" />
+    <bpmn:sequenceFlow id="Flow_1b" name="URL" sourceRef="Gateway_1" targetRef="Activity_url" />
+    <bpmn:sequenceFlow id="Flow_1c" name="IP" sourceRef="Gateway_1" targetRef="Activity_ip" />
+    <bpmn:sequenceFlow id="Flow_hash" sourceRef="Activity_hash" targetRef="Gateway_10" />
+    <bpmn:sequenceFlow id="Flow_url" sourceRef="Activity_url" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_ip" sourceRef="Activity_ip" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_10a" sourceRef="Gateway_10" targetRef="Activity_high" />
+    <bpmn:sequenceFlow id="Flow_10b" sourceRef="Gateway_10" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_10c" sourceRef="Gateway_10" targetRef="Activity_url" />
+    <bpmn:sequenceFlow id="Flow_high" sourceRef="Activity_high" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	cases := make(map[string]map[string]string)
+	for _, step := range cacaoPlaybook.Workflow {
+		if typed, ok := step.(*cacao.SwitchConditionStep); ok {
+			names := make(map[string]string)
+			for key, next := range typed.Cases {
+				if assert.Len(t, next, 1) {
+					names[key] = cacaoPlaybook.Workflow[next[0]].Common().Name
+				}
+			}
+			cases[typed.Name] = names
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"FILEHASH": "Look up hash",
+		"URL":      "Look up URL",
+		"IP":       "Look up IP",
+		"default":  "Look up IP",
+	}, cases["Kind of indicator?"])
+	// unnamed flows are selected by the name of the element they lead to,
+	// or failing that their ID, and an unnamed default flow only by the
+	// default case
+	assert.Equal(t, map[string]string{
+		"ESCALATE": "Escalate",
+		"END":      "End",
+		"default":  "Look up URL",
+	}, cases["Severity?"])
+	assert.Equal(t, "Severity?, one of ESCALATE, END", cacaoPlaybook.PlaybookVariables["__severity__"].Description)
+	assert.Equal(t, "IP", cacaoPlaybook.PlaybookVariables["__kind_of_indicator__"].Value)
+
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	violations, err := cacao.ValidateSchema(data, cacao.CACAO_SPEC_VERSION_20)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
@@ -0,0 +1,345 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diff changes
+const DIFF_CHANGE_ADDED string = "added"
+const DIFF_CHANGE_REMOVED string = "removed"
+const DIFF_CHANGE_MODIFIED string = "modified"
+
+// the step properties holding the conditions of condition steps
+var conditionProperties = map[string]bool{"condition": true, "switch": true}
+
+// the step properties holding references to other steps, compared as transitions
+var transitionProperties = map[string]bool{"on_completion": true, "on_success": true, "on_failure": true, "on_true": true, "on_false": true, "next_steps": true, "cases": true}
+
+// ValueChange is a change to a property, the old or new value is absent if
+// the property was added or removed
+type ValueChange struct {
+	Property string          `json:"property"`
+	Old      json.RawMessage `json:"old,omitempty"`
+	New      json.RawMessage `json:"new,omitempty"`
+}
+
+// TransitionChange is a change to the step a property refers to, eg.
+// on_completion or next_steps[1], giving the names of the steps as well as
+// their IDs
+type TransitionChange struct {
+	Property  string `json:"property"`
+	OldStepID string `json:"old_step_id,omitempty"`
+	OldName   string `json:"old_name,omitempty"`
+	NewStepID string `json:"new_step_id,omitempty"`
+	NewName   string `json:"new_name,omitempty"`
+}
+
+// StepDiff is a step that was added, removed or modified. The name is the
+// new name of the step, or its old name if it was removed.
+type StepDiff struct {
+	StepID      string             `json:"step_id"`
+	Name        string             `json:"name,omitempty"`
+	Change      string             `json:"change"`
+	Transitions []TransitionChange `json:"transitions,omitempty"`
+	Conditions  []ValueChange      `json:"conditions,omitempty"`
+	// Properties lists the changes to the other properties of a modified step
+	Properties []ValueChange `json:"properties,omitempty"`
+}
+
+// VariableDiff is a playbook variable that was added, removed or modified
+type VariableDiff struct {
+	Name   string          `json:"name"`
+	Change string          `json:"change"`
+	Old    json.RawMessage `json:"old,omitempty"`
+	New    json.RawMessage `json:"new,omitempty"`
+}
+
+// PlaybookDiff lists the differences between two playbooks: the changed
+// metadata, the changed workflow_start and workflow_exception steps, and the
+// changed variables and steps. Steps are sorted by name, then ID.
+type PlaybookDiff struct {
+	Metadata    []ValueChange      `json:"metadata"`
+	Transitions []TransitionChange `json:"transitions"`
+	Variables   []VariableDiff     `json:"variables"`
+	Steps       []StepDiff         `json:"steps"`
+}
+
+// Empty tells whether the playbooks are the same
+func (d *PlaybookDiff) Empty() bool {
+	return len(d.Metadata) == 0 && len(d.Transitions) == 0 && len(d.Variables) == 0 && len(d.Steps) == 0
+}
+
+// properties returns the JSON properties of a value
+func properties(value interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// diffProperties compares the properties of two values, in the order of
+// their names, leaving out those skipped
+func diffProperties(old, updated map[string]json.RawMessage, skip func(string) bool) []ValueChange {
+	names := make([]string, 0, len(old)+len(updated))
+	for _, values := range []map[string]json.RawMessage{old, updated} {
+		for name := range values {
+			names = append(names, name)
+		}
+	}
+	var changes []ValueChange
+	for _, name := range sortedUnion(names) {
+		oldValue, inOld := old[name]
+		newValue, inNew := updated[name]
+		if skip(name) || (inOld && inNew && jsonEquivalent(oldValue, newValue)) {
+			continue
+		}
+		changes = append(changes, ValueChange{Property: name, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+// stepName returns the name of a step of a workflow, or "" if it has none
+func stepName(workflow Workflow, stepId string) string {
+	if step, found := workflow[stepId]; found {
+		return step.Common().Name
+	}
+	return ""
+}
+
+// Diff compares two playbooks, eg. two versions of the same playbook.
+// Steps are matched by their IDs and variables by their names.
+func Diff(old, updated *CacaoPlaybook) (*PlaybookDiff, error) {
+	diff := &PlaybookDiff{Metadata: []ValueChange{}, Transitions: []TransitionChange{}, Variables: []VariableDiff{}, Steps: []StepDiff{}}
+	oldProperties, err := properties(old)
+	if err != nil {
+		return nil, err
+	}
+	newProperties, err := properties(updated)
+	if err != nil {
+		return nil, err
+	}
+	diff.Metadata = append(diff.Metadata, diffProperties(oldProperties, newProperties, func(name string) bool {
+		return name == "workflow" || name == "playbook_variables" || name == "workflow_start" || name == "workflow_exception"
+	})...)
+	transition := func(property, oldStepId, newStepId string) {
+		if oldStepId != newStepId {
+			diff.Transitions = append(diff.Transitions, TransitionChange{Property: property, OldStepID: oldStepId, OldName: stepName(old.Workflow, oldStepId), NewStepID: newStepId, NewName: stepName(updated.Workflow, newStepId)})
+		}
+	}
+	transition("workflow_start", old.WorkflowStart, updated.WorkflowStart)
+	transition("workflow_exception", old.WorkflowException, updated.WorkflowException)
+
+	for _, name := range sortedUnion(sortedVariableNames(old.PlaybookVariables), sortedVariableNames(updated.PlaybookVariables)) {
+		oldVariable, inOld := old.PlaybookVariables[name]
+		newVariable, inNew := updated.PlaybookVariables[name]
+		variableDiff := VariableDiff{Name: name, Change: DIFF_CHANGE_MODIFIED, Old: marshalPresent(oldVariable, inOld), New: marshalPresent(newVariable, inNew)}
+		switch {
+		case !inOld:
+			variableDiff.Change = DIFF_CHANGE_ADDED
+		case !inNew:
+			variableDiff.Change = DIFF_CHANGE_REMOVED
+		case jsonEquivalent(oldVariable, newVariable):
+			continue
+		}
+		diff.Variables = append(diff.Variables, variableDiff)
+	}
+
+	for _, stepId := range sortedUnion(sortedStepIds(old.Workflow), sortedStepIds(updated.Workflow)) {
+		oldStep, inOld := old.Workflow[stepId]
+		newStep, inNew := updated.Workflow[stepId]
+		switch {
+		case !inOld:
+			diff.Steps = append(diff.Steps, StepDiff{StepID: stepId, Name: newStep.Common().Name, Change: DIFF_CHANGE_ADDED})
+			continue
+		case !inNew:
+			diff.Steps = append(diff.Steps, StepDiff{StepID: stepId, Name: oldStep.Common().Name, Change: DIFF_CHANGE_REMOVED})
+			continue
+		}
+		stepDiff := StepDiff{StepID: stepId, Name: newStep.Common().Name, Change: DIFF_CHANGE_MODIFIED}
+		oldReferences, newReferences := make(map[string]string), make(map[string]string)
+		var referenceProperties []string
+		for _, references := range []struct {
+			step   Step
+			target map[string]string
+		}{{oldStep, oldReferences}, {newStep, newReferences}} {
+			for _, reference := range stepReferences(references.step) {
+				references.target[reference.Property] = reference.StepID
+				referenceProperties = append(referenceProperties, reference.Property)
+			}
+		}
+		for _, property := range sortedUnion(referenceProperties) {
+			if oldStepId, newStepId := oldReferences[property], newReferences[property]; oldStepId != newStepId {
+				stepDiff.Transitions = append(stepDiff.Transitions, TransitionChange{Property: property, OldStepID: oldStepId, OldName: stepName(old.Workflow, oldStepId), NewStepID: newStepId, NewName: stepName(updated.Workflow, newStepId)})
+			}
+		}
+		oldStepProperties, err := properties(oldStep)
+		if err != nil {
+			return nil, err
+		}
+		newStepProperties, err := properties(newStep)
+		if err != nil {
+			return nil, err
+		}
+		stepDiff.Conditions = diffProperties(oldStepProperties, newStepProperties, func(name string) bool {
+			return !conditionProperties[name]
+		})
+		stepDiff.Properties = diffProperties(oldStepProperties, newStepProperties, func(name string) bool {
+			return conditionProperties[name] || transitionProperties[name]
+		})
+		if len(stepDiff.Transitions) > 0 || len(stepDiff.Conditions) > 0 || len(stepDiff.Properties) > 0 {
+			diff.Steps = append(diff.Steps, stepDiff)
+		}
+	}
+	sort.SliceStable(diff.Steps, func(i, j int) bool {
+		if diff.Steps[i].Name != diff.Steps[j].Name {
+			return diff.Steps[i].Name < diff.Steps[j].Name
+		}
+		return diff.Steps[i].StepID < diff.Steps[j].StepID
+	})
+	return diff, nil
+}
+
+// formatValue returns the compact JSON of a value, or "(none)" if it is absent
+func formatValue(value json.RawMessage) string {
+	if value == nil {
+		return "(none)"
+	}
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, value); err != nil {
+		return string(value)
+	}
+	return buffer.String()
+}
+
+// formatStep returns the name and ID of a step, or "(none)" if there is no step
+func formatStep(name, stepId string) string {
+	switch {
+	case stepId == "":
+		return "(none)"
+	case name == "":
+		return stepId
+	}
+	return fmt.Sprintf("%s (%s)", name, stepId)
+}
+
+// diffSymbols are the markers of added, removed and modified items in the text output
+var diffSymbols = map[string]string{DIFF_CHANGE_ADDED: "+", DIFF_CHANGE_REMOVED: "-", DIFF_CHANGE_MODIFIED: "~"}
+
+// Text returns the differences as indented plain text, marking added items
+// with +, removed items with - and modified items with ~
+func (d *PlaybookDiff) Text() string {
+	var text strings.Builder
+	if len(d.Metadata) > 0 || len(d.Transitions) > 0 {
+		text.WriteString("Metadata:\n")
+		for _, change := range d.Metadata {
+			fmt.Fprintf(&text, "  ~ %s: %s -> %s\n", change.Property, formatValue(change.Old), formatValue(change.New))
+		}
+		for _, change := range d.Transitions {
+			fmt.Fprintf(&text, "  ~ %s: %s -> %s\n", change.Property, formatStep(change.OldName, change.OldStepID), formatStep(change.NewName, change.NewStepID))
+		}
+	}
+	if len(d.Variables) > 0 {
+		text.WriteString("Variables:\n")
+		for _, variable := range d.Variables {
+			fmt.Fprintf(&text, "  %s %s: %s -> %s\n", diffSymbols[variable.Change], variable.Name, formatValue(variable.Old), formatValue(variable.New))
+		}
+	}
+	if len(d.Steps) > 0 {
+		text.WriteString("Steps:\n")
+		for _, step := range d.Steps {
+			fmt.Fprintf(&text, "  %s %s\n", diffSymbols[step.Change], formatStep(step.Name, step.StepID))
+			for _, change := range step.Transitions {
+				fmt.Fprintf(&text, "      %s: %s -> %s\n", change.Property, formatStep(change.OldName, change.OldStepID), formatStep(change.NewName, change.NewStepID))
+			}
+			for _, changes := range [][]ValueChange{step.Conditions, step.Properties} {
+				for _, change := range changes {
+					fmt.Fprintf(&text, "      %s: %s -> %s\n", change.Property, formatValue(change.Old), formatValue(change.New))
+				}
+			}
+		}
+	}
+	return text.String()
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(value string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(value)
+}
+
+// Markdown returns the differences as Markdown, eg. for a pull request comment
+func (d *PlaybookDiff) Markdown() string {
+	var text strings.Builder
+	if d.Empty() {
+		return "No differences.\n"
+	}
+	writeTable := func(rows [][3]string) {
+		text.WriteString("| Property | Old | New |\n| --- | --- | --- |\n")
+		for _, row := range rows {
+			fmt.Fprintf(&text, "| %s | %s | %s |\n", markdownCell(row[0]), markdownCell(row[1]), markdownCell(row[2]))
+		}
+	}
+	transitionRows := func(changes []TransitionChange) [][3]string {
+		var rows [][3]string
+		for _, change := range changes {
+			rows = append(rows, [3]string{change.Property, formatStep(change.OldName, change.OldStepID), formatStep(change.NewName, change.NewStepID)})
+		}
+		return rows
+	}
+	valueRows := func(changes []ValueChange) [][3]string {
+		var rows [][3]string
+		for _, change := range changes {
+			rows = append(rows, [3]string{change.Property, "`" + formatValue(change.Old) + "`", "`" + formatValue(change.New) + "`"})
+		}
+		return rows
+	}
+	if len(d.Metadata) > 0 || len(d.Transitions) > 0 {
+		text.WriteString("## Metadata\n\n")
+		writeTable(append(valueRows(d.Metadata), transitionRows(d.Transitions)...))
+		text.WriteString("\n")
+	}
+	if len(d.Variables) > 0 {
+		text.WriteString("## Variables\n\n| Variable | Change | Old | New |\n| --- | --- | --- | --- |\n")
+		for _, variable := range d.Variables {
+			fmt.Fprintf(&text, "| `%s` | %s | `%s` | `%s` |\n", variable.Name, variable.Change, markdownCell(formatValue(variable.Old)), markdownCell(formatValue(variable.New)))
+		}
+		text.WriteString("\n")
+	}
+	if len(d.Steps) > 0 {
+		text.WriteString("## Steps\n\n")
+		for _, step := range d.Steps {
+			fmt.Fprintf(&text, "### %s %s\n\n`%s`\n\n", strings.ToUpper(step.Change[:1])+step.Change[1:], step.Name, step.StepID)
+			rows := append(transitionRows(step.Transitions), valueRows(step.Conditions)...)
+			rows = append(rows, valueRows(step.Properties)...)
+			if len(rows) > 0 {
+				writeTable(rows)
+				text.WriteString("\n")
+			}
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	created := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	old := convertAndRead(t, implicitSplitTestString, cacao.WithTimestamp(created))
+	updated := convertAndRead(t, revisedSplitTestString, cacao.WithTimestamp(created))
+	diff, err := cacao.Diff(old, updated)
+	if err != nil {
+		t.Fatalf("could not compare playbooks: %s", err)
+	}
+	assert.False(t, diff.Empty())
+	assert.Empty(t, diff.Metadata)
+	assert.Empty(t, diff.Transitions)
+	assert.Empty(t, diff.Variables)
+
+	hostsId := stepIdNamed(t, old, "Isolate hosts")
+	reportId := stepIdNamed(t, old, "Report")
+	closeId := stepIdNamed(t, updated, "Close ticket")
+	endId := old.Workflow[reportId].Common().OnCompletion
+	// steps are sorted by name
+	if assert.Len(t, diff.Steps, 3) {
+		assert.Equal(t, cacao.StepDiff{StepID: closeId, Name: "Close ticket", Change: cacao.DIFF_CHANGE_ADDED}, diff.Steps[0])
+
+		hosts := diff.Steps[1]
+		assert.Equal(t, hostsId, hosts.StepID)
+		assert.Equal(t, "Isolate endpoints", hosts.Name)
+		assert.Equal(t, cacao.DIFF_CHANGE_MODIFIED, hosts.Change)
+		assert.Empty(t, hosts.Transitions)
+		if assert.Len(t, hosts.Properties, 2) {
+			assert.Equal(t, "commands", hosts.Properties[0].Property)
+			assert.Equal(t, cacao.ValueChange{Property: "name", Old: json.RawMessage(`"Isolate hosts"`), New: json.RawMessage(`"Isolate endpoints"`)}, hosts.Properties[1])
+		}
+
+		report := diff.Steps[2]
+		assert.Equal(t, "Report", report.Name)
+		assert.Equal(t, []cacao.TransitionChange{
+			{Property: "on_completion", OldStepID: endId, OldName: "End", NewStepID: closeId, NewName: "Close ticket"},
+		}, report.Transitions)
+		assert.Empty(t, report.Properties)
+	}
+
+	text := diff.Text()
+	assert.Contains(t, text, "  + Close ticket ("+closeId+")\n")
+	assert.Contains(t, text, "      on_completion: End ("+endId+") -> Close ticket ("+closeId+")\n")
+	assert.Contains(t, text, `      name: "Isolate hosts" -> "Isolate endpoints"`)
+	markdown := diff.Markdown()
+	assert.Contains(t, markdown, "### Added Close ticket\n")
+	assert.Contains(t, markdown, "| on_completion | End ("+endId+") | Close ticket ("+closeId+") |\n")
+
+	// a playbook does not differ from itself
+	diff, err = cacao.Diff(old, old)
+	if err != nil {
+		t.Fatalf("could not compare playbooks: %s", err)
+	}
+	assert.True(t, diff.Empty())
+	assert.Empty(t, diff.Text())
+	assert.Equal(t, "No differences.\n", diff.Markdown())
+	data, err := json.Marshal(diff)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":[],"transitions":[],"variables":[],"steps":[]}`, string(data))
+}
+
+func TestDiffConditionsAndVariables(t *testing.T) {
+	created := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	old := convertAndRead(t, inputDataTestString, cacao.WithTimestamp(created))
+	updated := convertAndRead(t, inputDataTestString, cacao.WithTimestamp(created))
+	updated.Name = "Renamed"
+	// change the condition and swap the branches of the first if condition step
+	var conditionId string
+	for stepId, step := range updated.Workflow {
+		if _, ok := step.(*cacao.IfConditionStep); ok && (conditionId == "" || stepId < conditionId) {
+			conditionId = stepId
+		}
+	}
+	if conditionId == "" {
+		t.Fatalf("no if condition step")
+	}
+	ifStep := updated.Workflow[conditionId].(*cacao.IfConditionStep)
+	oldCondition := ifStep.Condition
+	ifStep.Condition = "__answer__ == \"Maybe\""
+	ifStep.OnTrue, ifStep.OnFalse = ifStep.OnFalse, ifStep.OnTrue
+	updated.PlaybookVariables["__added__"] = cacao.PlaybookVariable{Type: "string"}
+
+	diff, err := cacao.Diff(old, updated)
+	if err != nil {
+		t.Fatalf("could not compare playbooks: %s", err)
+	}
+	assert.Equal(t, []cacao.ValueChange{{Property: "name", Old: json.RawMessage(`"` + old.Name + `"`), New: json.RawMessage(`"Renamed"`)}}, diff.Metadata)
+	if assert.Len(t, diff.Variables, 1) {
+		assert.Equal(t, "__added__", diff.Variables[0].Name)
+		assert.Equal(t, cacao.DIFF_CHANGE_ADDED, diff.Variables[0].Change)
+		assert.Nil(t, diff.Variables[0].Old)
+	}
+	if assert.Len(t, diff.Steps, 1) {
+		step := diff.Steps[0]
+		assert.Equal(t, conditionId, step.StepID)
+		oldConditionJson, _ := json.Marshal(oldCondition)
+		assert.Equal(t, []cacao.ValueChange{{Property: "condition", Old: oldConditionJson, New: json.RawMessage(`"__answer__ == \"Maybe\""`)}}, step.Conditions)
+		if assert.Len(t, step.Transitions, 2) {
+			assert.Equal(t, "on_false", step.Transitions[0].Property)
+			assert.Equal(t, "on_true", step.Transitions[1].Property)
+			assert.Equal(t, step.Transitions[0].OldStepID, step.Transitions[1].NewStepID)
+		}
+		assert.Empty(t, step.Properties)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// the JSON properties of each struct type, see knownProperties
+var knownPropertiesCache sync.Map
+
+// structProperty is a JSON property of a struct type
+type structProperty struct {
+	// index is the index sequence of the field, see reflect.Value.FieldByIndex
+	index []int
+	// omitEmpty is true if the property is left out when its field is empty
+	omitEmpty bool
+}
+
+// knownProperties returns the JSON properties of a struct type, including
+// those of embedded structs
+func knownProperties(structType reflect.Type) map[string]structProperty {
+	if cached, found := knownPropertiesCache.Load(structType); found {
+		return cached.(map[string]structProperty)
+	}
+	properties := make(map[string]structProperty)
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			for name, property := range knownProperties(field.Type) {
+				properties[name] = structProperty{index: append([]int{i}, property.index...), omitEmpty: property.omitEmpty}
+			}
+			continue
+		}
+		options := strings.Split(tag, ",")
+		name := options[0]
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := structProperty{index: []int{i}}
+		for _, option := range options[1:] {
+			if option == "omitempty" {
+				property.omitEmpty = true
+			}
+		}
+		properties[name] = property
+	}
+	knownPropertiesCache.Store(structType, properties)
+	return properties
+}
+
+// isEmptyValue reports whether encoding/json leaves out a value tagged omitempty
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	}
+	return false
+}
+
+// omitted reports whether a property of a struct is left out when marshaling it
+func (p structProperty) omitted(structValue reflect.Value) bool {
+	return p.omitEmpty && isEmptyValue(structValue.FieldByIndex(p.index))
+}
+
+// marshalWithExtra marshals a struct, which must not implement
+// json.Marshaler itself, followed by the extra properties it does not
+// write, ie. those it does not define and empty values that were given
+// explicitly, eg. "revoked": false
+func marshalWithExtra(value interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	structValue := reflect.ValueOf(value).Elem()
+	known := knownProperties(structValue.Type())
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		if property, found := known[name]; !found || property.omitted(structValue) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var buffer bytes.Buffer
+	buffer.Write(data[:len(data)-1])
+	for _, name := range names {
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		nameBytes, _ := json.Marshal(name)
+		buffer.Write(nameBytes)
+		buffer.WriteByte(':')
+		buffer.Write(extra[name])
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// unmarshalWithExtra unmarshals JSON into a struct, which must not implement
+// json.Unmarshaler itself, and returns the properties it would not write
+// back, see marshalWithExtra
+func unmarshalWithExtra(data []byte, value interface{}) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, err
+	}
+	var properties map[string]json.RawMessage
+	if err := json.Unmarshal(data, &properties); err != nil {
+		return nil, err
+	}
+	structValue := reflect.ValueOf(value).Elem()
+	known := knownProperties(structValue.Type())
+	var extra map[string]json.RawMessage
+	for name, raw := range properties {
+		if property, found := known[name]; found && !property.omitted(structValue) {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[name] = raw
+	}
+	return extra, nil
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// Inventory statuses
+const INVENTORY_STATUS_CONVERTED string = "converted"
+const INVENTORY_STATUS_SKIPPED string = "skipped"
+const INVENTORY_STATUS_UNSUPPORTED string = "unsupported"
+
+// the reason given for flow nodes without a step
+const NO_STEP_REASON string = "no step was created for the element"
+
+// InventoryEntry records what a BPMN element became in the playbook, or why
+// it was left out
+type InventoryEntry struct {
+	ElementID   string `json:"element_id,omitempty"`
+	ElementType string `json:"element_type"`
+	Name        string `json:"name,omitempty"`
+	Status      string `json:"status"`
+	// StepID is the step created for the element, or for a sequence flow
+	// the step of its source
+	StepID string `json:"step_id,omitempty"`
+	// Detail is what an element that did not become a step became, or why
+	// the element was skipped
+	Detail string `json:"detail,omitempty"`
+}
+
+// inventory lists the elements of a BPMN process in document order by type,
+// along with the step each became or the reason it was left out
+func inventory(bpmnDefinitions *bpmn.BpmnDefinitions, bpmnProcess bpmn.BpmnProcess, stepMap map[string]string, graph *bpmn.FlowGraph, data *processData, cacaoPlaybook *CacaoPlaybook) []InventoryEntry {
+	var entries []InventoryEntry
+	stepOf := func(id string) string {
+		if _, found := cacaoPlaybook.Workflow[stepMap[id]]; found {
+			return stepMap[id]
+		}
+		return ""
+	}
+	// addFlowNode adds an element that becomes a step, giving the reason
+	// if it does not
+	addFlowNode := func(elementType, id, name, reason string) {
+		entry := InventoryEntry{ElementID: id, ElementType: elementType, Name: name, Status: INVENTORY_STATUS_CONVERTED, StepID: stepOf(id)}
+		if entry.StepID == "" {
+			entry.Status = INVENTORY_STATUS_SKIPPED
+			entry.Detail = reason
+		}
+		entries = append(entries, entry)
+	}
+	if bpmnProcess.StartEvent != nil {
+		addFlowNode(bpmn.BPMN_ELEMENT_START_EVENT, bpmnProcess.StartEvent.Id, bpmnProcess.StartEvent.Name, NO_STEP_REASON)
+	}
+	for _, tasks := range []struct {
+		elementType string
+		tasks       []bpmn.BpmnTask
+	}{
+		{bpmn.BPMN_ELEMENT_INTERMEDIATE_CATCH_EVENT, bpmnProcess.IntermediateCatchEvent},
+		{bpmn.BPMN_ELEMENT_SERVICE_TASK, bpmnProcess.ServiceTask},
+		{bpmn.BPMN_ELEMENT_USER_TASK, bpmnProcess.UserTask},
+		{bpmn.BPMN_ELEMENT_MANUAL_TASK, bpmnProcess.ManualTask},
+		{bpmn.BPMN_ELEMENT_SCRIPT_TASK, bpmnProcess.ScriptTask},
+		{bpmn.BPMN_ELEMENT_SEND_TASK, bpmnProcess.SendTask},
+		{bpmn.BPMN_ELEMENT_TASK, bpmnProcess.Task},
+		{bpmn.BPMN_ELEMENT_INTERMEDIATE_THROW_EVENT, bpmnProcess.IntermediateThrowEvent},
+	} {
+		for _, task := range tasks.tasks {
+			addFlowNode(tasks.elementType, task.Id, task.Name, NO_STEP_REASON)
+		}
+	}
+	for _, gateways := range []struct {
+		elementType string
+		gateways    []bpmn.BpmnGateway
+	}{
+		{bpmn.BPMN_ELEMENT_EXCLUSIVE_GATEWAY, bpmnProcess.ExclusiveGateway},
+		{bpmn.BPMN_ELEMENT_INCLUSIVE_GATEWAY, bpmnProcess.InclusiveGateway},
+		{bpmn.BPMN_ELEMENT_PARALLEL_GATEWAY, bpmnProcess.ParallelGateway},
+	} {
+		for _, gateway := range gateways.gateways {
+			addFlowNode(gateways.elementType, gateway.Id, gateway.Name, fmt.Sprintf("the gateway has %d outgoing flows", len(graph.Outgoing(gateway.Id))))
+		}
+	}
+	for _, endEvent := range bpmnProcess.EndEvent {
+		addFlowNode(bpmn.BPMN_ELEMENT_END_EVENT, endEvent.Id, endEvent.Name, NO_STEP_REASON)
+	}
+	for _, sequenceFlow := range bpmnProcess.SequenceFlow {
+		entry := InventoryEntry{ElementID: sequenceFlow.Id, ElementType: bpmn.BPMN_ELEMENT_SEQUENCE_FLOW, Name: sequenceFlow.Name, Status: INVENTORY_STATUS_CONVERTED, StepID: stepOf(sequenceFlow.SourceRef)}
+		if entry.StepID == "" {
+			entry.Status = INVENTORY_STATUS_SKIPPED
+			entry.Detail = fmt.Sprintf("its source %s has no step", sequenceFlow.SourceRef)
+		} else if stepOf(sequenceFlow.TargetRef) == "" {
+			entry.Status = INVENTORY_STATUS_SKIPPED
+			entry.Detail = fmt.Sprintf("its target %s has no step", sequenceFlow.TargetRef)
+		}
+		entries = append(entries, entry)
+	}
+	addData := func(elementType string, element bpmn.BpmnDataElement) {
+		entry := InventoryEntry{ElementID: element.Id, ElementType: elementType, Name: element.Name, Status: INVENTORY_STATUS_CONVERTED}
+		if variable, found := data.variables[element.Id]; found {
+			entry.Detail = fmt.Sprintf("playbook variable %s", variable)
+		} else {
+			entry.Status = INVENTORY_STATUS_SKIPPED
+			entry.Detail = "no variable was declared for the element"
+		}
+		entries = append(entries, entry)
+	}
+	for _, property := range bpmnProcess.Properties {
+		addData(bpmn.BPMN_ELEMENT_PROPERTY, property)
+	}
+	for _, dataObject := range bpmnProcess.DataObjects {
+		addData(bpmn.BPMN_ELEMENT_DATA_OBJECT, dataObject)
+	}
+	for _, reference := range bpmnProcess.DataObjectReferences {
+		addData(bpmn.BPMN_ELEMENT_DATA_OBJECT_REFERENCE, bpmn.BpmnDataElement{Id: reference.Id, Name: reference.Name})
+	}
+	for _, reference := range bpmnProcess.DataStoreReferences {
+		entry := InventoryEntry{ElementID: reference.Id, ElementType: bpmn.BPMN_ELEMENT_DATA_STORE_REFERENCE, Name: reference.Name, Status: INVENTORY_STATUS_CONVERTED}
+		if target, found := data.targets[reference.Id]; found {
+			entry.Detail = fmt.Sprintf("target %s", target)
+		} else {
+			entry.Status = INVENTORY_STATUS_SKIPPED
+			entry.Detail = fmt.Sprintf("data store targets are only supported for CACAO %s", CACAO_SPEC_VERSION_20)
+		}
+		entries = append(entries, entry)
+	}
+	addUnknown := func(element bpmn.BpmnElement) {
+		// the layout of the diagram and documentation do not affect the process
+		if element.XMLName.Space == bpmn.BPMN_DI_NAMESPACE || element.XMLName.Local == "documentation" {
+			return
+		}
+		entries = append(entries, InventoryEntry{
+			ElementID:   element.Id,
+			ElementType: element.XMLName.Local,
+			Name:        element.Name,
+			Status:      INVENTORY_STATUS_UNSUPPORTED,
+			Detail:      fmt.Sprintf("%s elements are not supported", element.XMLName.Local),
+		})
+	}
+	for _, element := range bpmnProcess.Unknown {
+		addUnknown(element)
+	}
+	for _, element := range bpmnDefinitions.Unknown {
+		addUnknown(element)
+	}
+	return entries
+}
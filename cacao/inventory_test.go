@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventory(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:bpmndi="http://www.omg.org/spec/BPMN/20100524/DI" id="Definitions_1">
+  <bpmn:collaboration id="Collaboration_1">
+    <bpmn:participant id="Participant_1" name="SOC" processRef="Process_1" />
+  </bpmn:collaboration>
+  <bpmn:process id="Process_1" name="Inventory" isExecutable="true">
+    <bpmn:documentation>Not an element of the process</bpmn:documentation>
+    <bpmn:startEvent id="StartEvent_1" name="Alert" />
+    <bpmn:userTask id="Activity_1" name="Triage" />
+    <bpmn:subProcess id="Activity_2" name="Contain" />
+    <bpmn:boundaryEvent id="Event_timeout" name="Timeout" attachedToRef="Activity_1" />
+    <bpmn:exclusiveGateway id="Gateway_1" name="Merge" />
+    <bpmn:endEvent id="Event_1" name="Done" />
+    <bpmn:dataStoreReference id="DataStoreReference_1" name="Case database" />
+    <bpmn:textAnnotation id="TextAnnotation_1" />
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Activity_2" />
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Activity_2" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Gateway_1" targetRef="Event_1" />
+  </bpmn:process>
+  <bpmndi:BPMNDiagram id="BPMNDiagram_1" />
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	_, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_11)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	statuses := make(map[string]string)
+	for _, entry := range report.Inventory {
+		statuses[entry.ElementID] = entry.Status
+		if entry.Status == cacao.INVENTORY_STATUS_CONVERTED && entry.ElementType != bpmn.BPMN_ELEMENT_DATA_STORE_REFERENCE {
+			assert.NotEmpty(t, entry.StepID, entry.ElementID)
+		} else {
+			assert.NotEmpty(t, entry.Detail, entry.ElementID)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"StartEvent_1":         cacao.INVENTORY_STATUS_CONVERTED,
+		"Activity_1":           cacao.INVENTORY_STATUS_CONVERTED,
+		"Gateway_1":            cacao.INVENTORY_STATUS_SKIPPED,
+		"Event_1":              cacao.INVENTORY_STATUS_CONVERTED,
+		"Flow_1":               cacao.INVENTORY_STATUS_CONVERTED,
+		"Flow_2":               cacao.INVENTORY_STATUS_SKIPPED,
+		"Flow_3":               cacao.INVENTORY_STATUS_SKIPPED,
+		"Flow_4":               cacao.INVENTORY_STATUS_SKIPPED,
+		"DataStoreReference_1": cacao.INVENTORY_STATUS_SKIPPED,
+		"Activity_2":           cacao.INVENTORY_STATUS_UNSUPPORTED,
+		"Event_timeout":        cacao.INVENTORY_STATUS_UNSUPPORTED,
+		"TextAnnotation_1":     cacao.INVENTORY_STATUS_UNSUPPORTED,
+		"Collaboration_1":      cacao.INVENTORY_STATUS_UNSUPPORTED,
+	}, statuses)
+	converted, total := report.Converted()
+	assert.Equal(t, 4, converted)
+	assert.Equal(t, 13, total)
+	assert.Equal(t, 30.8, report.Coverage)
+	assert.Equal(t, 50.0, cacao.CoveragePercent(1, 2))
+	assert.Equal(t, 100.0, cacao.CoveragePercent(0, 0))
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Canonicalize serialises JSON using the JSON Canonicalization Scheme of
+// RFC 8785: no whitespace, object properties sorted by their UTF-16 code
+// units, and strings and numbers in the form produced by ECMAScript.
+func Canonicalize(data []byte) ([]byte, error) {
+	value, err := decodeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := writeCanonical(&buffer, value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeCanonical writes a value decoded by decodeJson in canonical form
+func writeCanonical(buffer *bytes.Buffer, value interface{}) error {
+	switch typed := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(typed))
+	case json.Number:
+		number, err := typed.Float64()
+		if err != nil {
+			return fmt.Errorf("number %s cannot be canonicalized: %s", typed, err)
+		}
+		formatted, err := formatCanonicalNumber(number)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(formatted)
+	case string:
+		writeCanonicalString(buffer, typed)
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, item := range typed {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeCanonical(buffer, item); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case map[string]interface{}:
+		names := make([]string, 0, len(typed))
+		for name := range typed {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return lessUtf16(names[i], names[j])
+		})
+		buffer.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			writeCanonicalString(buffer, name)
+			buffer.WriteByte(':')
+			if err := writeCanonical(buffer, typed[name]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected JSON value of type %T", value)
+	}
+	return nil
+}
+
+// lessUtf16 compares strings by their UTF-16 code units, as RFC 8785 requires
+func lessUtf16(a, b string) bool {
+	unitsA := utf16.Encode([]rune(a))
+	unitsB := utf16.Encode([]rune(b))
+	for i := 0; i < len(unitsA) && i < len(unitsB); i++ {
+		if unitsA[i] != unitsB[i] {
+			return unitsA[i] < unitsB[i]
+		}
+	}
+	return len(unitsA) < len(unitsB)
+}
+
+// writeCanonicalString writes a string, escaping only what JSON requires
+func writeCanonicalString(buffer *bytes.Buffer, value string) {
+	buffer.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buffer, `\u%04x`, r)
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+	buffer.WriteByte('"')
+}
+
+// formatCanonicalNumber formats a number as ECMAScript Number.prototype.toString does
+func formatCanonicalNumber(number float64) (string, error) {
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", fmt.Errorf("number %v cannot be represented in JSON", number)
+	}
+	if number == 0 {
+		return "0", nil
+	}
+	sign := ""
+	if number < 0 {
+		sign = "-"
+		number = -number
+	}
+	// the shortest digits that round trip, and the decimal exponent
+	scientific := strconv.FormatFloat(number, 'e', -1, 64)
+	separator := strings.IndexByte(scientific, 'e')
+	digits := strings.Replace(scientific[:separator], ".", "", 1)
+	exponent, err := strconv.Atoi(scientific[separator+1:])
+	if err != nil {
+		return "", err
+	}
+	// the position of the decimal point relative to the digits
+	point := exponent + 1
+	var formatted string
+	switch {
+	case len(digits) <= point && point <= 21:
+		formatted = digits + strings.Repeat("0", point-len(digits))
+	case 0 < point && point <= 21:
+		formatted = digits[:point] + "." + digits[point:]
+	case -6 < point && point <= 0:
+		formatted = "0." + strings.Repeat("0", -point) + digits
+	default:
+		formatted = digits[:1]
+		if len(digits) > 1 {
+			formatted += "." + digits[1:]
+		}
+		if exponent >= 0 {
+			formatted += "e+" + strconv.Itoa(exponent)
+		} else {
+			formatted += "e" + strconv.Itoa(exponent)
+		}
+	}
+	return sign + formatted, nil
+}
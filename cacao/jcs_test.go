@@ -0,0 +1,61 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalize(t *testing.T) {
+	testCases := map[string]struct {
+		input    string
+		expected string
+	}{
+		// the examples of RFC 8785 section 3.2.2 and 3.2.3
+		"rfc 8785 example": {
+			`{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			  "literals": [null, true, false]}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		"sorting by utf-16": {
+			`{"\u20ac": 1, "\r": 2, "\ufb33": 3, "1": 4, "\ud83d\ude00": 5, "\u0080": 6, "\u00f6": 7}`,
+			"{\"\\r\":2,\"1\":4,\"\u0080\":6,\"ö\":7,\"€\":1,\"😀\":5,\"\ufb33\":3}",
+		},
+		"numbers": {
+			`[0, -0, 1e21, 1e20, 1e-6, 1e-7, 9007199254740992, -1.5, 5e-324, 123456789012345680000]`,
+			`[0,0,1e+21,100000000000000000000,0.000001,1e-7,9007199254740992,-1.5,5e-324,123456789012345680000]`,
+		},
+		"nested": {
+			`{ "b": [ {"d": 1, "c": "x"} ], "a": {} }`,
+			`{"a":{},"b":[{"c":"x","d":1}]}`,
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			canonical, err := cacao.Canonicalize([]byte(testCase.input))
+			if assert.NoError(t, err) {
+				assert.Equal(t, testCase.expected, string(canonical))
+			}
+		})
+	}
+	_, err := cacao.Canonicalize([]byte(`{"a": 1e400}`))
+	assert.Error(t, err, "numbers outside the range of IEEE 754 doubles cannot be canonicalized")
+}
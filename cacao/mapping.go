@@ -0,0 +1,329 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"gopkg.in/yaml.v3"
+)
+
+// MappingRules select how BPMN tasks are converted to commands, overriding
+// the default command type of each BPMN element type. The first rule that
+// matches a task is applied. See LoadMappingRules for the file format.
+type MappingRules struct {
+	// Agents and Targets define the agents and targets that rules refer to by name
+	Agents  map[string]AgentTarget `json:"agents,omitempty"`
+	Targets map[string]AgentTarget `json:"targets,omitempty"`
+	Rules   []MappingRule          `json:"rules"`
+	// BranchLabels adds to the labels of positive and negative gateway branches
+	BranchLabels *BranchLabels `json:"branch_labels,omitempty"`
+}
+
+// MappingRule selects the command, agent and targets for the tasks it matches
+type MappingRule struct {
+	Match   MappingMatch   `json:"match"`
+	Command MappingCommand `json:"command"`
+	Agent   string         `json:"agent,omitempty"`
+	Targets []string       `json:"targets,omitempty"`
+}
+
+// MappingMatch holds the conditions of a rule, all of which must hold
+type MappingMatch struct {
+	// Element is a BPMN element type, eg. "sendTask"
+	Element string `json:"element,omitempty"`
+	// Name is a regular expression matched against the task name
+	Name string `json:"name,omitempty"`
+	// Lane is a regular expression matched against the name of the lane of the task
+	Lane string `json:"lane,omitempty"`
+	// Property is the name of an extension property the task must have,
+	// or name=value to also require its value
+	Property string `json:"property,omitempty"`
+	// JobType is a regular expression matched against the Zeebe job type of the task
+	JobType string `json:"job_type,omitempty"`
+	name    *regexp.Regexp
+	lane    *regexp.Regexp
+	jobType *regexp.Regexp
+}
+
+// MappingCommand describes the command created for a task. The template
+// and description are Go templates, see MappingTemplateData.
+type MappingCommand struct {
+	Type        string `json:"type,omitempty"`
+	Template    string `json:"template,omitempty"`
+	Description string `json:"description,omitempty"`
+	template    *template.Template
+	description *template.Template
+}
+
+// MappingTemplateData is the data available to command templates, eg.
+// "Send an email to {{.Properties.recipient}} about {{.Name}}"
+type MappingTemplateData struct {
+	Id            string
+	Name          string
+	Documentation string
+	Element       string
+	Lane          string
+	Properties    map[string]string
+	// JobType and Headers are the Zeebe job type and task headers
+	JobType string
+	Headers map[string]string
+}
+
+// LoadMappingRules parses mapping rules from YAML or JSON, eg.
+//
+//	agents:
+//	  soc:
+//	    type: organization
+//	    name: Security Operations Centre
+//	rules:
+//	  - match:
+//	      element: sendTask
+//	      name: (?i)e-?mail
+//	    command:
+//	      type: manual
+//	      template: "Send an email: {{.Name}}"
+//	    agent: soc
+func LoadMappingRules(data []byte) (*MappingRules, error) {
+	// decode the YAML generically, then decode it as JSON so that the JSON
+	// names of the playbook types apply and unknown keys are rejected
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	rules := new(MappingRules)
+	if err := decoder.Decode(rules); err != nil {
+		return nil, err
+	}
+	for name, agent := range rules.Agents {
+		if agent.Type == "" {
+			return nil, fmt.Errorf("agent %q has no type", name)
+		}
+	}
+	for name, target := range rules.Targets {
+		if target.Type == "" {
+			return nil, fmt.Errorf("target %q has no type", name)
+		}
+	}
+	for i := range rules.Rules {
+		if err := rules.Rules[i].compile(rules); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+// branchLabels returns the extra branch labels of the rules, if any
+func (m *MappingRules) branchLabels() []BranchLabels {
+	if m == nil || m.BranchLabels == nil {
+		return nil
+	}
+	return []BranchLabels{*m.BranchLabels}
+}
+
+// compile checks a rule and prepares its regular expressions and templates
+func (r *MappingRule) compile(rules *MappingRules) error {
+	var err error
+	if r.Match.Name != "" {
+		if r.Match.name, err = regexp.Compile(r.Match.Name); err != nil {
+			return fmt.Errorf("name: %s", err)
+		}
+	}
+	if r.Match.Lane != "" {
+		if r.Match.lane, err = regexp.Compile(r.Match.Lane); err != nil {
+			return fmt.Errorf("lane: %s", err)
+		}
+	}
+	if r.Match.JobType != "" {
+		if r.Match.jobType, err = regexp.Compile(r.Match.JobType); err != nil {
+			return fmt.Errorf("job_type: %s", err)
+		}
+	}
+	if r.Command.Type != "" {
+		if _, found := commandRules[CACAO_SPEC_VERSION_20][r.Command.Type]; !found {
+			if _, found := commandRules[CACAO_SPEC_VERSION_11][r.Command.Type]; !found {
+				return fmt.Errorf("unknown command type %q", r.Command.Type)
+			}
+		}
+	}
+	if r.Command.Template != "" {
+		if r.Command.template, err = template.New("template").Option("missingkey=zero").Parse(r.Command.Template); err != nil {
+			return err
+		}
+	}
+	if r.Command.Description != "" {
+		if r.Command.description, err = template.New("description").Option("missingkey=zero").Parse(r.Command.Description); err != nil {
+			return err
+		}
+	}
+	if r.Agent != "" {
+		if _, found := rules.Agents[r.Agent]; !found {
+			return fmt.Errorf("unknown agent %q", r.Agent)
+		}
+	}
+	for _, target := range r.Targets {
+		if _, found := rules.Targets[target]; !found {
+			return fmt.Errorf("unknown target %q", target)
+		}
+	}
+	return nil
+}
+
+// match returns the first rule matching a task, or nil if none does
+func (m *MappingRules) match(data MappingTemplateData, extensionElements *bpmn.BpmnExtensionElements) *MappingRule {
+	if m == nil {
+		return nil
+	}
+	for i := range m.Rules {
+		rule := &m.Rules[i]
+		if rule.Match.Element != "" && !strings.EqualFold(rule.Match.Element, data.Element) {
+			continue
+		}
+		if rule.Match.name != nil && !rule.Match.name.MatchString(data.Name) {
+			continue
+		}
+		if rule.Match.lane != nil && !rule.Match.lane.MatchString(data.Lane) {
+			continue
+		}
+		if rule.Match.jobType != nil && !rule.Match.jobType.MatchString(data.JobType) {
+			continue
+		}
+		if rule.Match.Property != "" {
+			name, value, hasValue := strings.Cut(rule.Match.Property, "=")
+			actual, found := extensionElements.Property(name)
+			if !found || (hasValue && actual != value) {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+// newMappingTemplateData collects the data of a task used to match rules and fill templates
+func newMappingTemplateData(elementType string, task bpmn.BpmnTask, lane string) MappingTemplateData {
+	data := MappingTemplateData{
+		Id:            task.Id,
+		Name:          task.Name,
+		Documentation: task.Documentation,
+		Element:       elementType,
+		Lane:          lane,
+		Properties:    make(map[string]string),
+		Headers:       make(map[string]string),
+	}
+	if task.ExtensionElements != nil {
+		for _, property := range task.ExtensionElements.Properties {
+			if _, found := data.Properties[property.Name]; !found {
+				data.Properties[property.Name] = property.Value
+			}
+		}
+		if task.ExtensionElements.TaskDefinition != nil {
+			data.JobType = task.ExtensionElements.TaskDefinition.Type
+		}
+		for _, header := range task.ExtensionElements.TaskHeaders {
+			data.Headers[header.Key] = header.Value
+		}
+	}
+	return data
+}
+
+// render executes a template, returning the fallback if there is no template
+func render(tmpl *template.Template, data MappingTemplateData, fallback string) (string, error) {
+	if tmpl == nil {
+		return fallback, nil
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// apply replaces the command of an action step as the rule describes, and
+// sets its agent and targets, adding their definitions to the playbook. A
+// rule giving only a description keeps the command, eg. the script of a
+// script task, and only replaces its description.
+func (r *MappingRule) apply(rules *MappingRules, data MappingTemplateData, commandType, specVersion, stepId string, step *ActionStep, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	description, err := render(r.Command.description, data, data.Documentation)
+	if err != nil {
+		report.warnf(DIAGNOSTIC_CODE_INVALID_TEMPLATE, data.Id, stepId, "fix the description template of the mapping rule", "description template: %s", err)
+		return
+	}
+	if r.Command.Type == "" && r.Command.template == nil && r.Command.description != nil {
+		for i := range step.Commands {
+			step.Commands[i].Description = description
+		}
+	}
+	if r.Command.Type != "" || r.Command.template != nil {
+		if r.Command.Type != "" {
+			commandType = r.Command.Type
+		}
+		text, err := render(r.Command.template, data, data.Name)
+		if err != nil {
+			report.warnf(DIAGNOSTIC_CODE_INVALID_TEMPLATE, data.Id, stepId, "fix the command template of the mapping rule", "command template: %s", err)
+			return
+		}
+		command, err := newTextCommand(commandType, specVersion, text, description)
+		if err != nil {
+			report.warnf(DIAGNOSTIC_CODE_INVALID_COMMAND, data.Id, stepId, "change the command type or template of the mapping rule", "%s, keeping the default command", err)
+		} else {
+			step.Commands = []Command{command}
+		}
+	}
+	if r.Agent == "" && len(r.Targets) == 0 {
+		return
+	}
+	if specVersion != CACAO_SPEC_VERSION_20 {
+		report.warnf(DIAGNOSTIC_CODE_UNSUPPORTED_FEATURE, data.Id, stepId, fmt.Sprintf("convert to CACAO %s", CACAO_SPEC_VERSION_20), "agents and targets are only supported for CACAO %s", CACAO_SPEC_VERSION_20)
+		return
+	}
+	if r.Agent != "" {
+		if cacaoPlaybook.AgentDefinitions == nil {
+			cacaoPlaybook.AgentDefinitions = make(map[string]AgentTarget)
+		}
+		step.Agent = addAgentTarget(cacaoPlaybook.AgentDefinitions, "agent", r.Agent, rules.Agents[r.Agent])
+	}
+	for _, target := range r.Targets {
+		if cacaoPlaybook.TargetDefinitions == nil {
+			cacaoPlaybook.TargetDefinitions = make(map[string]AgentTarget)
+		}
+		step.Targets = append(step.Targets, addAgentTarget(cacaoPlaybook.TargetDefinitions, "target", target, rules.Targets[target]))
+	}
+}
+
+// addAgentTarget adds a definition to a playbook under an ID derived from
+// its name in the mapping rules, and returns the ID
+func addAgentTarget(definitions map[string]AgentTarget, kind string, name string, definition AgentTarget) string {
+	id := fmt.Sprintf("%s--%s", definition.Type, deterministicUuid(fmt.Sprintf("%s:%s", kind, name)))
+	if definition.Name == "" {
+		definition.Name = name
+	}
+	definitions[id] = definition
+	return id
+}
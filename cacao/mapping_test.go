@@ -0,0 +1,219 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+const mappingRulesTestString = `
+agents:
+  soc:
+    type: organization
+    name: Security Operations Centre
+targets:
+  firewall:
+    type: http-api
+    address:
+      url: ["https://firewall.example.com/api"]
+rules:
+  - match:
+      element: sendTask
+      name: (?i)e-?mail
+    command:
+      type: manual
+      template: "Send an email to {{.Properties.recipient}}: {{.Name}}"
+    agent: soc
+  - match:
+      lane: ^Network$
+    command:
+      type: http-api
+      template: "POST /block"
+      description: "{{.Name}} ({{.Lane}})"
+    targets: [firewall]
+  - match:
+      property: automation=ssh
+    command:
+      type: ssh
+`
+
+const mappedProcessTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Mapped" isExecutable="true">
+    <bpmn:laneSet id="LaneSet_1">
+      <bpmn:lane id="Lane_1" name="Network">
+        <bpmn:flowNodeRef>Activity_2</bpmn:flowNodeRef>
+      </bpmn:lane>
+    </bpmn:laneSet>
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:sendTask id="Activity_1" name="Email the customer">
+      <bpmn:extensionElements>
+        <camunda:properties>
+          <camunda:property name="recipient" value="customer@example.com" />
+        </camunda:properties>
+      </bpmn:extensionElements>
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:sendTask>
+    <bpmn:serviceTask id="Activity_2" name="Block the address">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+    </bpmn:serviceTask>
+    <bpmn:userTask id="Activity_3" name="Isolate the host">
+      <bpmn:extensionElements>
+        <camunda:properties>
+          <camunda:property name="automation" value="ssh" />
+        </camunda:properties>
+      </bpmn:extensionElements>
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+      <bpmn:outgoing>Flow_4</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:sendTask id="Activity_4" name="Page the on-call analyst">
+      <bpmn:incoming>Flow_4</bpmn:incoming>
+      <bpmn:outgoing>Flow_5</bpmn:outgoing>
+    </bpmn:sendTask>
+    <bpmn:endEvent id="Event_1" name="End">
+      <bpmn:incoming>Flow_5</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Activity_2" />
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Activity_2" targetRef="Activity_3" />
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Activity_3" targetRef="Activity_4" />
+    <bpmn:sequenceFlow id="Flow_5" sourceRef="Activity_4" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+// stepByName returns the action step with the given name
+func stepByName(t *testing.T, cacaoPlaybook *cacao.CacaoPlaybook, name string) *cacao.ActionStep {
+	for _, step := range cacaoPlaybook.Workflow {
+		if actionStep, ok := step.(*cacao.ActionStep); ok && actionStep.Name == name {
+			return actionStep
+		}
+	}
+	t.Fatalf("no action step named %q", name)
+	return nil
+}
+
+func TestConvertWithMappingRules(t *testing.T) {
+	rules, err := cacao.LoadMappingRules([]byte(mappingRulesTestString))
+	if err != nil {
+		t.Fatalf("could not load mapping rules: %s", err)
+	}
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(mappedProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithMappingRules(rules))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+
+	email := stepByName(t, cacaoPlaybook, "Email the customer")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_MANUAL, email.Commands[0].Type)
+	assert.Equal(t, "Send an email to customer@example.com: Email the customer", email.Commands[0].Command)
+	if assert.Contains(t, cacaoPlaybook.AgentDefinitions, email.Agent) {
+		assert.Equal(t, "Security Operations Centre", cacaoPlaybook.AgentDefinitions[email.Agent].Name)
+	}
+
+	block := stepByName(t, cacaoPlaybook, "Block the address")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_HTTP, block.Commands[0].Type)
+	assert.Equal(t, "POST /block", block.Commands[0].Command)
+	assert.Equal(t, "Block the address (Network)", block.Commands[0].Description)
+	if assert.Len(t, block.Targets, 1) && assert.Contains(t, cacaoPlaybook.TargetDefinitions, block.Targets[0]) {
+		firewall := cacaoPlaybook.TargetDefinitions[block.Targets[0]]
+		assert.Equal(t, "http-api", firewall.Type)
+		assert.Equal(t, "firewall", firewall.Name, "the rule name is used when the definition has none")
+		assert.JSONEq(t, `{"url": ["https://firewall.example.com/api"]}`, string(firewall.Extra["address"]))
+	}
+
+	isolate := stepByName(t, cacaoPlaybook, "Isolate the host")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_SSH, isolate.Commands[0].Type)
+	assert.Equal(t, "Isolate the host", isolate.Commands[0].Command)
+
+	// no rule matches, so the send task becomes a manual command
+	page := stepByName(t, cacaoPlaybook, "Page the on-call analyst")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_MANUAL, page.Commands[0].Type)
+	assert.Empty(t, page.Agent)
+
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	violations, err := cacao.ValidateSchema(data, "")
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestMappingRuleDescriptionOnly(t *testing.T) {
+	rules, err := cacao.LoadMappingRules([]byte(`{"rules": [{"command": {"description": "Automated: {{.Name}}"}}]}`))
+	if err != nil {
+		t.Fatalf("could not load mapping rules: %s", err)
+	}
+	process := strings.Replace(mappedProcessTestString, `<bpmn:sendTask id="Activity_4" name="Page the on-call analyst">`, `<bpmn:scriptTask id="Activity_4" name="Page the on-call analyst" scriptFormat="bash">`, 1)
+	process = strings.Replace(process, `<bpmn:outgoing>Flow_5</bpmn:outgoing>
+    </bpmn:sendTask>`, `<bpmn:outgoing>Flow_5</bpmn:outgoing>
+      <bpmn:script>page --team soc</bpmn:script>
+    </bpmn:scriptTask>`, 1)
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(process))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithMappingRules(rules))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	assert.Empty(t, report.Diagnostics)
+
+	// the script is kept, only the description changes
+	page := stepByName(t, cacaoPlaybook, "Page the on-call analyst")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_BASH, page.Commands[0].Type)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("page --team soc")), page.Commands[0].CommandB64)
+	assert.Equal(t, "Automated: Page the on-call analyst", page.Commands[0].Description)
+
+	// as is the manual command of a service task without a connector
+	block := stepByName(t, cacaoPlaybook, "Block the address")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_MANUAL, block.Commands[0].Type)
+	assert.Equal(t, "Block the address", block.Commands[0].Command)
+	assert.Equal(t, "Automated: Block the address", block.Commands[0].Description)
+}
+
+func TestLoadMappingRulesErrors(t *testing.T) {
+	testCases := map[string]string{
+		"unknown key":          `{"rules": [{"match": {"colour": "red"}}]}`,
+		"invalid regex":        `{"rules": [{"match": {"name": "("}}]}`,
+		"unknown command type": `{"rules": [{"command": {"type": "telepathy"}}]}`,
+		"invalid template":     `{"rules": [{"command": {"template": "{{.Name"}}]}`,
+		"unknown agent":        `{"rules": [{"agent": "nobody"}]}`,
+		"agent without type":   `{"agents": {"soc": {"name": "SOC"}}, "rules": []}`,
+		"invalid yaml":         "rules: [",
+	}
+	for name, rules := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := cacao.LoadMappingRules([]byte(rules))
+			assert.Error(t, err)
+		})
+	}
+}
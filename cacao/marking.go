@@ -0,0 +1,243 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// CACAO data marking types
+const CACAO_MARKING_TYPE_STATEMENT string = "marking-statement"
+const CACAO_MARKING_TYPE_TLP string = "marking-tlp"
+const CACAO_MARKING_TYPE_IEP string = "marking-iep"
+
+// TLP 2.0 levels
+const TLP_RED string = "TLP:RED"
+const TLP_AMBER_STRICT string = "TLP:AMBER+STRICT"
+const TLP_AMBER string = "TLP:AMBER"
+const TLP_GREEN string = "TLP:GREEN"
+const TLP_CLEAR string = "TLP:CLEAR"
+
+// BPMN process extension properties that add data markings
+const MARKING_PROPERTY_TLP string = "cacao:tlp"
+const MARKING_PROPERTY_STATEMENT string = "cacao:statement"
+const MARKING_PROPERTY_IEP string = "cacao:iep"
+
+// DataMarking represents a data marking definition. The populated fields
+// depend on the marking type, use NewTlpMarking, NewStatementMarking or
+// ParseIepMarking to build one.
+type DataMarking struct {
+	Type        string     `json:"type"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	CreatedBy   string     `json:"created_by"`
+	Created     *time.Time `json:"created"`
+	Revoked     bool       `json:"revoked,omitempty"`
+	// marking-statement
+	Statement string `json:"statement,omitempty"`
+	// marking-tlp
+	TlpLevel string `json:"tlpv2_level,omitempty"`
+	// marking-iep
+	Tlp                        string     `json:"tlp,omitempty"`
+	IepVersion                 string     `json:"iep_version,omitempty"`
+	StartDate                  *time.Time `json:"start_date,omitempty"`
+	EndDate                    *time.Time `json:"end_date,omitempty"`
+	EncryptInTransit           string     `json:"encrypt_in_transit,omitempty"`
+	PermittedActions           string     `json:"permitted_actions,omitempty"`
+	AffectedPartyNotifications string     `json:"affected_party_notifications,omitempty"`
+	Attribution                string     `json:"attribution,omitempty"`
+	UnmodifiedResale           string     `json:"unmodified_resale,omitempty"`
+	// Extra holds properties that are not defined above, so that they
+	// survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (m DataMarking) MarshalJSON() ([]byte, error) {
+	type plain DataMarking
+	return marshalWithExtra((*plain)(&m), m.Extra)
+}
+
+func (m *DataMarking) UnmarshalJSON(data []byte) (err error) {
+	type plain DataMarking
+	m.Extra, err = unmarshalWithExtra(data, (*plain)(m))
+	return err
+}
+
+// tlpLevels maps the accepted spellings of TLP levels to TLP 2.0 levels.
+// TLP:WHITE from TLP 1.0 is accepted as TLP:CLEAR.
+var tlpLevels = map[string]string{
+	"RED":          TLP_RED,
+	"AMBER+STRICT": TLP_AMBER_STRICT,
+	"AMBER":        TLP_AMBER,
+	"GREEN":        TLP_GREEN,
+	"CLEAR":        TLP_CLEAR,
+	"WHITE":        TLP_CLEAR,
+}
+
+// normalizeTlpLevel converts eg. "amber" or "tlp:amber" to "TLP:AMBER"
+func normalizeTlpLevel(level string) (string, error) {
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(level)), "TLP:")
+	if normalized, found := tlpLevels[name]; found {
+		return normalized, nil
+	}
+	return "", fmt.Errorf("unknown TLP level %q", level)
+}
+
+// markingId derives the ID of a marking from its type and content, so that
+// the same marking always has the same ID
+func markingId(markingType string, content string) string {
+	return fmt.Sprintf("%s--%s", markingType, deterministicUuid(fmt.Sprintf("%s:%s", markingType, content)))
+}
+
+// NewTlpMarking creates a TLP 2.0 marking, the level may be given with or
+// without the "TLP:" prefix and in any case
+func NewTlpMarking(level string) (DataMarking, error) {
+	normalized, err := normalizeTlpLevel(level)
+	if err != nil {
+		return DataMarking{}, err
+	}
+	return DataMarking{
+		Type:     CACAO_MARKING_TYPE_TLP,
+		ID:       markingId(CACAO_MARKING_TYPE_TLP, normalized),
+		Name:     normalized,
+		TlpLevel: normalized,
+	}, nil
+}
+
+// NewStatementMarking creates a marking holding a statement, eg. a copyright notice
+func NewStatementMarking(statement string) DataMarking {
+	return DataMarking{
+		Type:      CACAO_MARKING_TYPE_STATEMENT,
+		ID:        markingId(CACAO_MARKING_TYPE_STATEMENT, statement),
+		Statement: statement,
+	}
+}
+
+// ParseIepMarking creates a FIRST Information Exchange Policy marking from
+// comma separated key=value pairs, eg.
+// "tlp=amber,encrypt_in_transit=must,permitted_actions=internal".
+// The tlp key is required, start_date and end_date are RFC 3339 timestamps.
+func ParseIepMarking(policy string) (DataMarking, error) {
+	marking := DataMarking{
+		Type:       CACAO_MARKING_TYPE_IEP,
+		IepVersion: "2.0",
+	}
+	for _, pair := range strings.Split(policy, ",") {
+		key, value, found := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !found || key == "" {
+			return DataMarking{}, fmt.Errorf("expected key=value, found %q", pair)
+		}
+		switch key {
+		case "tlp":
+			level, err := normalizeTlpLevel(value)
+			if err != nil {
+				return DataMarking{}, err
+			}
+			marking.Tlp = level
+		case "name":
+			marking.Name = value
+		case "iep_version":
+			marking.IepVersion = value
+		case "start_date", "end_date":
+			date, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return DataMarking{}, fmt.Errorf("%s: %s", key, err)
+			}
+			date = date.UTC()
+			if key == "start_date" {
+				marking.StartDate = &date
+			} else {
+				marking.EndDate = &date
+			}
+		case "encrypt_in_transit":
+			marking.EncryptInTransit = value
+		case "permitted_actions":
+			marking.PermittedActions = value
+		case "affected_party_notifications":
+			marking.AffectedPartyNotifications = value
+		case "attribution":
+			marking.Attribution = value
+		case "unmodified_resale":
+			marking.UnmodifiedResale = value
+		default:
+			return DataMarking{}, fmt.Errorf("unknown IEP property %q", key)
+		}
+	}
+	if marking.Tlp == "" {
+		return DataMarking{}, fmt.Errorf("an IEP marking requires tlp")
+	}
+	// the ID is derived from the policy itself, so the order of the pairs does not matter
+	content, err := json.Marshal(&marking)
+	if err != nil {
+		return DataMarking{}, err
+	}
+	marking.ID = markingId(CACAO_MARKING_TYPE_IEP, string(content))
+	return marking, nil
+}
+
+// processMarkings returns the markings set on a BPMN process using the
+// cacao:tlp, cacao:statement and cacao:iep extension properties
+func processMarkings(bpmnProcess bpmn.BpmnProcess, report *Report) []DataMarking {
+	if bpmnProcess.ExtensionElements == nil {
+		return nil
+	}
+	var markings []DataMarking
+	for _, property := range bpmnProcess.ExtensionElements.Properties {
+		var marking DataMarking
+		var err error
+		switch property.Name {
+		case MARKING_PROPERTY_TLP:
+			marking, err = NewTlpMarking(property.Value)
+		case MARKING_PROPERTY_STATEMENT:
+			marking = NewStatementMarking(property.Value)
+		case MARKING_PROPERTY_IEP:
+			marking, err = ParseIepMarking(property.Value)
+		default:
+			continue
+		}
+		if err != nil {
+			report.warnf(DIAGNOSTIC_CODE_INVALID_MARKING, bpmnProcess.Id, "", "", "ignoring property %s: %s", property.Name, err)
+			continue
+		}
+		markings = append(markings, marking)
+	}
+	return markings
+}
+
+// applyMarkings adds markings to a playbook, skipping those it already has.
+// The definitions are created by the playbook creator at the given time.
+func applyMarkings(cacaoPlaybook *CacaoPlaybook, markings []DataMarking, created time.Time) {
+	for _, marking := range markings {
+		if _, found := cacaoPlaybook.DataMarkingDefinitions[marking.ID]; found {
+			continue
+		}
+		if cacaoPlaybook.DataMarkingDefinitions == nil {
+			cacaoPlaybook.DataMarkingDefinitions = make(map[string]DataMarking)
+		}
+		marking.CreatedBy = cacaoPlaybook.CreatedBy
+		marking.Created = &created
+		cacaoPlaybook.DataMarkingDefinitions[marking.ID] = marking
+		cacaoPlaybook.Markings = append(cacaoPlaybook.Markings, marking.ID)
+	}
+}
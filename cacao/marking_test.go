@@ -0,0 +1,147 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTlpMarking(t *testing.T) {
+	for _, level := range []string{"amber", "TLP:AMBER", "tlp:Amber "} {
+		marking, err := cacao.NewTlpMarking(level)
+		if assert.NoError(t, err) {
+			assert.Equal(t, cacao.TLP_AMBER, marking.TlpLevel)
+			assert.True(t, strings.HasPrefix(marking.ID, "marking-tlp--"))
+		}
+	}
+	white, _ := cacao.NewTlpMarking("white")
+	clear, _ := cacao.NewTlpMarking("clear")
+	assert.Equal(t, clear, white, "TLP:WHITE is TLP:CLEAR in TLP 2.0")
+	_, err := cacao.NewTlpMarking("purple")
+	assert.Error(t, err)
+}
+
+func TestParseIepMarking(t *testing.T) {
+	marking, err := cacao.ParseIepMarking("tlp=amber, encrypt_in_transit=must, permitted_actions=internal")
+	if err != nil {
+		t.Fatalf("could not parse IEP marking: %s", err)
+	}
+	assert.Equal(t, cacao.CACAO_MARKING_TYPE_IEP, marking.Type)
+	assert.Equal(t, cacao.TLP_AMBER, marking.Tlp)
+	assert.Equal(t, "must", marking.EncryptInTransit)
+	assert.Equal(t, "internal", marking.PermittedActions)
+	reordered, err := cacao.ParseIepMarking("permitted_actions=internal,tlp=AMBER,encrypt_in_transit=must")
+	if assert.NoError(t, err) {
+		assert.Equal(t, marking.ID, reordered.ID, "the ID must not depend on the order of the pairs")
+	}
+	for _, policy := range []string{"encrypt_in_transit=must", "tlp=amber,colour=blue", "tlp", "tlp=amber,start_date=yesterday"} {
+		_, err := cacao.ParseIepMarking(policy)
+		assert.Error(t, err, policy)
+	}
+}
+
+const markedProcessTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:camunda="http://camunda.org/schema/1.0/bpmn" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Marked" isExecutable="true">
+    <bpmn:extensionElements>
+      <camunda:properties>
+        <camunda:property name="cacao:tlp" value="amber" />
+        <camunda:property name="cacao:statement" value="Copyright 2023 Example" />
+        <camunda:property name="cacao:iep" value="tlp=green" />
+        <camunda:property name="cacao:tlp" value="purple" />
+        <camunda:property name="owner" value="SOC" />
+      </camunda:properties>
+    </bpmn:extensionElements>
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:userTask id="Activity_1" name="Review">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:endEvent id="Event_1" name="End">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestConvertWithMarkings(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(markedProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	tlp, _ := cacao.NewTlpMarking("amber")
+	red, _ := cacao.NewTlpMarking("red")
+	timestamp := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(timestamp), cacao.WithMarkings(red, tlp))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	// the flag markings come first, the duplicate TLP:AMBER and the invalid TLP level are skipped
+	statement := cacao.NewStatementMarking("Copyright 2023 Example")
+	iep, _ := cacao.ParseIepMarking("tlp=green")
+	assert.Equal(t, []string{red.ID, tlp.ID, statement.ID, iep.ID}, cacaoPlaybook.Markings)
+	assert.Len(t, cacaoPlaybook.DataMarkingDefinitions, 4)
+	for _, markingId := range cacaoPlaybook.Markings {
+		definition := cacaoPlaybook.DataMarkingDefinitions[markingId]
+		assert.Equal(t, markingId, definition.ID)
+		assert.Equal(t, cacaoPlaybook.CreatedBy, definition.CreatedBy)
+		assert.Equal(t, timestamp, *definition.Created)
+	}
+	assert.Equal(t, "Copyright 2023 Example", cacaoPlaybook.DataMarkingDefinitions[statement.ID].Statement)
+
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	violations, err := cacao.ValidateSchema(data, "")
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestUpdateKeepsMarkings(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(markedProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	firstRun := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(firstRun))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	generated, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	updated := cacao.UpdatePlaybook(previous, generated)
+	for _, markingId := range updated.Markings {
+		assert.Equal(t, firstRun, *updated.DataMarkingDefinitions[markingId].Created)
+	}
+	// nothing changed, so neither does the output
+	previousData, _ := json.Marshal(previous)
+	updatedData, _ := json.Marshal(updated)
+	assert.Equal(t, string(previousData), string(updatedData))
+}
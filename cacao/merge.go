@@ -0,0 +1,252 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Step merge actions
+const MERGE_ACTION_UPDATED string = "updated"
+const MERGE_ACTION_ADDED string = "added"
+const MERGE_ACTION_REMOVED string = "removed"
+
+// Merge conflict kinds
+const MERGE_CONFLICT_MODIFIED string = "modified-in-both"
+const MERGE_CONFLICT_ADDED string = "added-in-both"
+const MERGE_CONFLICT_REMOVED_BY_EDIT string = "removed-by-edit"
+const MERGE_CONFLICT_REMOVED_FROM_BPMN string = "removed-from-bpmn"
+const MERGE_CONFLICT_DANGLING_REFERENCE string = "dangling-reference"
+
+// StepMerge records what a merge did to a step
+type StepMerge struct {
+	StepID string `json:"step_id"`
+	Name   string `json:"name,omitempty"`
+	Action string `json:"action"`
+	// Edited tells whether the step had been edited by hand, in which case
+	// the edits were lost
+	Edited bool `json:"edited,omitempty"`
+}
+
+// MergeConflict is a step, variable or playbook property that was changed
+// both by hand and by the BPMN. The edited version is kept, the versions of
+// the base, edited and generated playbooks are given to resolve it by hand
+// and are absent where the value did not exist. A dangling-reference
+// conflict is a property of a merged step, or workflow_start or
+// workflow_exception, whose Reference is not in the merged workflow.
+type MergeConflict struct {
+	StepID    string          `json:"step_id,omitempty"`
+	Variable  string          `json:"variable,omitempty"`
+	Property  string          `json:"property,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Kind      string          `json:"kind"`
+	Reference string          `json:"reference,omitempty"`
+	Base      json.RawMessage `json:"base,omitempty"`
+	Edited    json.RawMessage `json:"edited,omitempty"`
+	Generated json.RawMessage `json:"generated,omitempty"`
+}
+
+// MergeReport lists the steps a merge updated, added or removed, and the
+// conflicts it found
+type MergeReport struct {
+	Steps     []StepMerge     `json:"steps"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// marshalPresent returns the JSON of a value, or nil if it is not present
+func marshalPresent(value interface{}, present bool) json.RawMessage {
+	if !present {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// mergeThreeWay decides which version of a value to keep given its JSON in
+// the base, edited and generated playbooks, nil where it is absent. Changes
+// made on one side only are kept, the edited version is kept on conflict
+// and the kind of the conflict is returned.
+func mergeThreeWay(base, edited, generated json.RawMessage) (useGenerated bool, conflict string) {
+	equal := func(a, b json.RawMessage) bool {
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		return jsonEquivalent(a, b)
+	}
+	switch {
+	case equal(edited, generated), equal(base, generated):
+		return false, ""
+	case equal(base, edited):
+		return true, ""
+	case base == nil:
+		return false, MERGE_CONFLICT_ADDED
+	case edited == nil:
+		return false, MERGE_CONFLICT_REMOVED_BY_EDIT
+	case generated == nil:
+		return false, MERGE_CONFLICT_REMOVED_FROM_BPMN
+	}
+	return false, MERGE_CONFLICT_MODIFIED
+}
+
+// Merge combines a playbook edited by hand with a newly generated version
+// of it, given the generated playbook the edits started from. Steps,
+// playbook variables and the workflow start and exception steps are merged
+// by their stable IDs and names: a change made only by hand or only in the
+// BPMN is kept, and a change made in both is a conflict, for which the
+// edited version is kept. As steps kept on one side may refer to steps
+// removed on the other, each reference to a step missing from the merged
+// workflow is a conflict too. The metadata is combined as by UpdatePlaybook.
+// The report lists the steps taken from the generated playbook and the
+// conflicts.
+func Merge(base, edited, generated *CacaoPlaybook) (*CacaoPlaybook, *MergeReport) {
+	merged := UpdatePlaybook(edited, generated)
+	report := &MergeReport{Steps: []StepMerge{}, Conflicts: []MergeConflict{}}
+
+	merged.Workflow = make(Workflow)
+	for _, stepId := range sortedUnion(sortedStepIds(base.Workflow), sortedStepIds(edited.Workflow), sortedStepIds(generated.Workflow)) {
+		baseStep, inBase := base.Workflow[stepId]
+		editedStep, inEdited := edited.Workflow[stepId]
+		generatedStep, inGenerated := generated.Workflow[stepId]
+		baseJson, editedJson, generatedJson := marshalPresent(baseStep, inBase), marshalPresent(editedStep, inEdited), marshalPresent(generatedStep, inGenerated)
+		useGenerated, conflict := mergeThreeWay(baseJson, editedJson, generatedJson)
+		var name string
+		for _, step := range []Step{editedStep, generatedStep, baseStep} {
+			if step != nil && name == "" {
+				name = step.Common().Name
+			}
+		}
+		if conflict != "" {
+			report.Conflicts = append(report.Conflicts, MergeConflict{StepID: stepId, Name: name, Kind: conflict, Base: baseJson, Edited: editedJson, Generated: generatedJson})
+		}
+		if !useGenerated {
+			if inEdited {
+				merged.Workflow[stepId] = editedStep
+			}
+			continue
+		}
+		change := StepMerge{StepID: stepId, Name: name, Action: MERGE_ACTION_UPDATED}
+		switch {
+		case !inGenerated:
+			change.Action = MERGE_ACTION_REMOVED
+		case !inEdited:
+			change.Action = MERGE_ACTION_ADDED
+		}
+		if inGenerated {
+			merged.Workflow[stepId] = generatedStep
+		}
+		report.Steps = append(report.Steps, change)
+	}
+
+	merged.PlaybookVariables = make(map[string]PlaybookVariable)
+	for _, name := range sortedUnion(sortedVariableNames(base.PlaybookVariables), sortedVariableNames(edited.PlaybookVariables), sortedVariableNames(generated.PlaybookVariables)) {
+		baseVariable, inBase := base.PlaybookVariables[name]
+		editedVariable, inEdited := edited.PlaybookVariables[name]
+		generatedVariable, inGenerated := generated.PlaybookVariables[name]
+		baseJson, editedJson, generatedJson := marshalPresent(baseVariable, inBase), marshalPresent(editedVariable, inEdited), marshalPresent(generatedVariable, inGenerated)
+		useGenerated, conflict := mergeThreeWay(baseJson, editedJson, generatedJson)
+		if conflict != "" {
+			report.Conflicts = append(report.Conflicts, MergeConflict{Variable: name, Kind: conflict, Base: baseJson, Edited: editedJson, Generated: generatedJson})
+		}
+		if useGenerated && inGenerated {
+			merged.PlaybookVariables[name] = generatedVariable
+		} else if !useGenerated && inEdited {
+			merged.PlaybookVariables[name] = editedVariable
+		}
+	}
+	if len(merged.PlaybookVariables) == 0 {
+		merged.PlaybookVariables = nil
+	}
+
+	for _, property := range []struct {
+		name                    string
+		base, edited, generated string
+		merged                  *string
+	}{
+		{"workflow_start", base.WorkflowStart, edited.WorkflowStart, generated.WorkflowStart, &merged.WorkflowStart},
+		{"workflow_exception", base.WorkflowException, edited.WorkflowException, generated.WorkflowException, &merged.WorkflowException},
+	} {
+		baseJson, editedJson, generatedJson := marshalPresent(property.base, property.base != ""), marshalPresent(property.edited, property.edited != ""), marshalPresent(property.generated, property.generated != "")
+		useGenerated, conflict := mergeThreeWay(baseJson, editedJson, generatedJson)
+		if conflict != "" {
+			report.Conflicts = append(report.Conflicts, MergeConflict{Property: property.name, Kind: conflict, Base: baseJson, Edited: editedJson, Generated: generatedJson})
+		}
+		*property.merged = property.edited
+		if useGenerated {
+			*property.merged = property.generated
+		}
+	}
+	report.Conflicts = append(report.Conflicts, danglingReferences(merged)...)
+	setMergedModified(merged, edited, generated)
+	return merged, report
+}
+
+// danglingReferences returns a conflict for each reference of the workflow
+// start and exception and of each step to a step not in the workflow
+func danglingReferences(playbook *CacaoPlaybook) []MergeConflict {
+	var conflicts []MergeConflict
+	for _, property := range []struct{ name, stepId string }{
+		{"workflow_start", playbook.WorkflowStart},
+		{"workflow_exception", playbook.WorkflowException},
+	} {
+		if _, found := playbook.Workflow[property.stepId]; property.stepId != "" && !found {
+			conflicts = append(conflicts, MergeConflict{Property: property.name, Kind: MERGE_CONFLICT_DANGLING_REFERENCE, Reference: property.stepId})
+		}
+	}
+	for _, stepId := range sortedStepIds(playbook.Workflow) {
+		step := playbook.Workflow[stepId]
+		for _, reference := range stepReferences(step) {
+			if _, found := playbook.Workflow[reference.StepID]; !found {
+				conflicts = append(conflicts, MergeConflict{StepID: stepId, Name: step.Common().Name, Property: reference.Property, Kind: MERGE_CONFLICT_DANGLING_REFERENCE, Reference: reference.StepID})
+			}
+		}
+	}
+	return conflicts
+}
+
+// setMergedModified sets the ID, modified timestamp and derived_from of a
+// merged playbook, which is a new version of the edited playbook only if
+// the merge changed the edited workflow
+func setMergedModified(merged, edited, generated *CacaoPlaybook) {
+	merged.ID = generated.ID
+	merged.Modified = generated.Modified
+	merged.DerivedFrom = mergeStrings(edited.DerivedFrom, generated.DerivedFrom)
+	setVersion(merged, edited)
+}
+
+// sortedVariableNames returns the names of playbook variables, sorted
+func sortedVariableNames(variables map[string]PlaybookVariable) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedUnion returns the values of the given slices without duplicates, sorted
+func sortedUnion(lists ...[]string) []string {
+	var union []string
+	for _, list := range lists {
+		union = mergeStrings(union, list)
+	}
+	sort.Strings(union)
+	return union
+}
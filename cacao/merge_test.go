@@ -0,0 +1,167 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// revisedSplitTestString renames "Isolate hosts" and adds a task after "Report"
+var revisedSplitTestString = strings.Replace(strings.Replace(implicitSplitTestString,
+	`name="Isolate hosts"`, `name="Isolate endpoints"`, 1),
+	`<bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_report" targetRef="Event_1" />`, `<bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_report" targetRef="Activity_close" />
+    <bpmn:userTask id="Activity_close" name="Close ticket" />
+    <bpmn:sequenceFlow id="Flow_8" sourceRef="Activity_close" targetRef="Event_1" />`, 1)
+
+func TestMerge(t *testing.T) {
+	created := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	base := convertAndRead(t, implicitSplitTestString, cacao.WithTimestamp(created))
+	edited := convertAndRead(t, implicitSplitTestString, cacao.WithTimestamp(created))
+
+	// an analyst edits two steps, adds a step and a variable
+	notifyId := stepIdNamed(t, edited, "Notify")
+	edited.Workflow[notifyId].Common().Description = "Page the on-call analyst"
+	hostsId := stepIdNamed(t, edited, "Isolate hosts")
+	edited.Workflow[hostsId].Common().Description = "Use the EDR console"
+	handAddedId := "action--7b0a1c6e-3f1d-4d7e-9a2b-5c8e4f6d2a10"
+	edited.Workflow[handAddedId] = &cacao.ActionStep{
+		StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_ACTION, Name: "Hand-added", OnCompletion: edited.Workflow[notifyId].Common().OnCompletion},
+	}
+	edited.PlaybookVariables = map[string]cacao.PlaybookVariable{"__ticket__": {Type: "string", Description: "Ticket"}}
+
+	generated := convertAndRead(t, revisedSplitTestString, cacao.WithTimestamp(created.Add(24*time.Hour)))
+	merged, report := cacao.Merge(base, edited, generated)
+
+	reportId := stepIdNamed(t, generated, "Report")
+	closeId := stepIdNamed(t, generated, "Close ticket")
+	assert.ElementsMatch(t, []cacao.StepMerge{
+		{StepID: reportId, Name: "Report", Action: cacao.MERGE_ACTION_UPDATED},
+		{StepID: closeId, Name: "Close ticket", Action: cacao.MERGE_ACTION_ADDED},
+	}, report.Steps)
+	// both sides changed "Isolate hosts", the edited version is kept
+	if assert.Len(t, report.Conflicts, 1) {
+		conflict := report.Conflicts[0]
+		assert.Equal(t, hostsId, conflict.StepID)
+		assert.Equal(t, "Isolate hosts", conflict.Name)
+		assert.Equal(t, cacao.MERGE_CONFLICT_MODIFIED, conflict.Kind)
+		var baseStep, editedStep, generatedStep cacao.ActionStep
+		assert.NoError(t, json.Unmarshal(conflict.Base, &baseStep))
+		assert.NoError(t, json.Unmarshal(conflict.Edited, &editedStep))
+		assert.NoError(t, json.Unmarshal(conflict.Generated, &generatedStep))
+		assert.Equal(t, "Isolate hosts", baseStep.Name)
+		assert.Equal(t, "Use the EDR console", editedStep.Description)
+		assert.Equal(t, "Isolate endpoints", generatedStep.Name)
+	}
+	assert.Equal(t, "Use the EDR console", merged.Workflow[hostsId].Common().Description)
+
+	// changes made on one side only are kept
+	assert.Equal(t, "Page the on-call analyst", merged.Workflow[notifyId].Common().Description)
+	assert.Contains(t, merged.Workflow, handAddedId)
+	assert.Contains(t, merged.PlaybookVariables, "__ticket__")
+	assert.Equal(t, closeId, merged.Workflow[reportId].Common().OnCompletion)
+	assert.Contains(t, merged.Workflow, closeId)
+	assert.Len(t, merged.Workflow, len(generated.Workflow)+1)
+	assert.Equal(t, edited.WorkflowStart, merged.WorkflowStart)
+
+	assert.Equal(t, created, *merged.Created)
+	assert.Equal(t, *generated.Modified, *merged.Modified)
+	assert.NotEqual(t, edited.ID, merged.ID, "the merged playbook is a new version")
+	assert.Contains(t, merged.DerivedFrom, edited.ID)
+}
+
+func TestMergeRemoved(t *testing.T) {
+	base := convertAndRead(t, implicitSplitTestString)
+	edited := convertAndRead(t, implicitSplitTestString)
+	notifyId := stepIdNamed(t, edited, "Notify")
+	edited.Workflow[notifyId].Common().Description = "Page the on-call analyst"
+	// the BPMN drops "Notify"
+	revised := strings.Replace(implicitSplitTestString, `<bpmn:outgoing>Flow_1</bpmn:outgoing>`, "", 1)
+	revised = strings.Replace(revised, `<bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_notify" />`, "", 1)
+	revised = strings.Replace(revised, `<bpmn:userTask id="Activity_notify" name="Notify">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+    </bpmn:userTask>`, "", 1)
+	generated := convertAndRead(t, revised)
+
+	merged, report := cacao.Merge(base, edited, generated)
+	if assert.Len(t, report.Conflicts, 2) {
+		assert.Equal(t, notifyId, report.Conflicts[0].StepID)
+		assert.Equal(t, cacao.MERGE_CONFLICT_REMOVED_FROM_BPMN, report.Conflicts[0].Kind)
+		assert.Nil(t, report.Conflicts[0].Generated)
+		// the kept "Notify" still refers to its removed end step
+		assert.Equal(t, cacao.MergeConflict{StepID: notifyId, Name: "Notify", Property: "on_completion", Kind: cacao.MERGE_CONFLICT_DANGLING_REFERENCE, Reference: edited.Workflow[notifyId].Common().OnCompletion}, report.Conflicts[1])
+	}
+	assert.Contains(t, merged.Workflow, notifyId)
+	var removed []string
+	for _, change := range report.Steps {
+		if change.Action == cacao.MERGE_ACTION_REMOVED {
+			removed = append(removed, change.Name)
+		}
+	}
+	// the parallel step of the start event and the end step after "Notify" are gone
+	assert.ElementsMatch(t, []string{"Alert", "End"}, removed)
+}
+
+func TestMergeDanglingReference(t *testing.T) {
+	base := convertAndRead(t, implicitSplitTestString)
+	edited := convertAndRead(t, implicitSplitTestString)
+	// an analyst deletes "Report" and routes the parallel step around it
+	reportId := stepIdNamed(t, edited, "Report")
+	for _, step := range edited.Workflow {
+		if step.Common().OnCompletion == reportId {
+			step.Common().OnCompletion = edited.Workflow[reportId].Common().OnCompletion
+		}
+	}
+	delete(edited.Workflow, reportId)
+	// the BPMN renames "Notify" and routes it to "Report"
+	generated := convertAndRead(t, implicitSplitTestString)
+	notifyId := stepIdNamed(t, generated, "Notify")
+	generated.Workflow[notifyId].Common().Name = "Notify team"
+	generated.Workflow[notifyId].Common().OnCompletion = reportId
+
+	merged, report := cacao.Merge(base, edited, generated)
+	assert.Equal(t, "Notify team", merged.Workflow[notifyId].Common().Name)
+	assert.NotContains(t, merged.Workflow, reportId)
+	assert.Equal(t, []cacao.MergeConflict{
+		{StepID: notifyId, Name: "Notify team", Property: "on_completion", Kind: cacao.MERGE_CONFLICT_DANGLING_REFERENCE, Reference: reportId},
+	}, report.Conflicts)
+
+	// a workflow start removed on one side is a dangling reference too
+	delete(edited.Workflow, edited.WorkflowStart)
+	_, report = cacao.Merge(base, edited, generated)
+	assert.Contains(t, report.Conflicts, cacao.MergeConflict{Property: "workflow_start", Kind: cacao.MERGE_CONFLICT_DANGLING_REFERENCE, Reference: edited.WorkflowStart})
+}
+
+func TestMergeUnchanged(t *testing.T) {
+	created := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	base := convertAndRead(t, implicitSplitTestString, cacao.WithTimestamp(created))
+	edited := convertAndRead(t, implicitSplitTestString, cacao.WithTimestamp(created))
+	edited.Workflow[stepIdNamed(t, edited, "Notify")].Common().Description = "Page the on-call analyst"
+	generated := convertAndRead(t, implicitSplitTestString, cacao.WithTimestamp(created.Add(time.Hour)))
+
+	// regenerating the same BPMN keeps the edited playbook as it is
+	merged, report := cacao.Merge(base, edited, generated)
+	assert.Empty(t, report.Steps)
+	assert.Empty(t, report.Conflicts)
+	assert.True(t, cacao.WorkflowEqual(edited, merged))
+	assert.Equal(t, created, *merged.Modified)
+}
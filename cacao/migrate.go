@@ -0,0 +1,211 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// migratedStepTypes maps CACAO 1.1 step types to their 2.0 equivalent
+var migratedStepTypes = map[string]string{
+	CACAO_STEP_TYPE_11_SINGLE:   CACAO_STEP_TYPE_ACTION,
+	CACAO_STEP_TYPE_11_PLAYBOOK: CACAO_STEP_TYPE_PLAYBOOK_ACTION,
+}
+
+// matches a CACAO 1.1 variable reference such as $$ip_address$$ or a plain variable name
+var variableNamePattern = regexp.MustCompile(`^(\$\$)?([A-Za-z0-9_-]+)(\$\$)?$`)
+
+// Migrate upgrades a CACAO 1.1 playbook to CACAO 2.0. Step IDs are renamed
+// from the step--<uuid> form to <type>--<uuid> using the 2.0 step types and
+// every reference to them is rewritten. Variables are renamed to the 2.0
+// __name__ form, commands are converted to their 2.0 equivalent, and
+// required 2.0 properties that are missing are added. The modified timestamp
+// is set to the current time, or the time given using WithTimestamp.
+func Migrate(playbook *CacaoPlaybook, options ...ConvertOption) (*CacaoPlaybook, error) {
+	if NormalizeSpecVersion(playbook.SpecVersion) != CACAO_SPEC_VERSION_11 {
+		return nil, fmt.Errorf("expected a CACAO %s playbook, found spec_version %q", CACAO_SPEC_VERSION_11, playbook.SpecVersion)
+	}
+	settings := newConvertOptions(options)
+	migrated := *playbook
+	migrated.SpecVersion = CACAO_SPEC_VERSION_20_VALUE
+	if migrated.CreatedBy == "" {
+		migrated.CreatedBy = defaultCreatedBy
+	}
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if settings.timestamp != nil {
+		now = *settings.timestamp
+	}
+	migrated.Modified = &now
+	if migrated.Created == nil {
+		migrated.Created = &now
+	}
+	// rename the variables
+	variableNames := make(map[string]string)
+	if playbook.PlaybookVariables != nil {
+		migrated.PlaybookVariables = make(map[string]PlaybookVariable)
+		for name, variable := range playbook.PlaybookVariables {
+			newName := migrateVariableName(name)
+			variableNames[name] = newName
+			migrated.PlaybookVariables[newName] = variable
+		}
+	}
+	// rename the steps
+	stepIds := make(map[string]string)
+	for stepId, step := range playbook.Workflow {
+		stepType := step.StepType()
+		if newType, found := migratedStepTypes[stepType]; found {
+			stepType = newType
+		}
+		separator := strings.Index(stepId, "--")
+		if separator < 0 {
+			return nil, fmt.Errorf("step ID %q is not of the form <type>--<uuid>", stepId)
+		}
+		stepIds[stepId] = fmt.Sprintf("%s--%s", stepType, stepId[separator+2:])
+	}
+	renameStep := func(stepId string) string {
+		if newId, found := stepIds[stepId]; found {
+			return newId
+		}
+		return stepId
+	}
+	migrated.WorkflowStart = renameStep(playbook.WorkflowStart)
+	if playbook.WorkflowException != "" {
+		migrated.WorkflowException = renameStep(playbook.WorkflowException)
+	}
+	migrated.Workflow = make(Workflow)
+	for stepId, originalStep := range playbook.Workflow {
+		step, err := CopyStep(originalStep)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %s", stepId, err)
+		}
+		common := step.Common()
+		if newType, found := migratedStepTypes[common.Type]; found {
+			common.Type = newType
+		}
+		renameStepReferences(step, renameStep)
+		// step variables shadow playbook variables of the same name
+		stepVariableNames := variableNames
+		if common.StepVariables != nil {
+			stepVariableNames = make(map[string]string)
+			for name, newName := range variableNames {
+				stepVariableNames[name] = newName
+			}
+			stepVariables := make(map[string]PlaybookVariable)
+			for name, variable := range common.StepVariables {
+				newName := migrateVariableName(name)
+				stepVariableNames[name] = newName
+				stepVariables[newName] = variable
+			}
+			common.StepVariables = stepVariables
+		}
+		for i, inArg := range common.InArgs {
+			common.InArgs[i] = renameVariable(inArg, stepVariableNames)
+		}
+		for i, outArg := range common.OutArgs {
+			common.OutArgs[i] = renameVariable(outArg, stepVariableNames)
+		}
+		switch typed := step.(type) {
+		case *IfConditionStep:
+			typed.Condition = renameVariables(typed.Condition, stepVariableNames)
+		case *WhileConditionStep:
+			typed.Condition = renameVariables(typed.Condition, stepVariableNames)
+		case *SwitchConditionStep:
+			typed.Switch = renameVariable(typed.Switch, stepVariableNames)
+		case *ActionStep:
+			for i, command := range typed.Commands {
+				migratedCommand, err := migrateCommand(command)
+				if err != nil {
+					return nil, fmt.Errorf("step %s: %s", stepId, err)
+				}
+				typed.Commands[i] = migratedCommand
+			}
+		}
+		migrated.Workflow[renameStep(stepId)] = step
+	}
+	return &migrated, nil
+}
+
+// migrateVariableName converts a CACAO 1.1 variable name to the 2.0 __name__ form
+func migrateVariableName(name string) string {
+	if strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__") && len(name) > 4 {
+		return name
+	}
+	if match := variableNamePattern.FindStringSubmatch(name); match != nil {
+		return fmt.Sprintf("__%s__", match[2])
+	}
+	return name
+}
+
+// renameVariable returns the new name of a variable, or the name unchanged if it is not known
+func renameVariable(name string, variableNames map[string]string) string {
+	if newName, found := variableNames[name]; found {
+		return newName
+	}
+	return name
+}
+
+// renameVariables rewrites the variable references in a condition
+func renameVariables(condition string, variableNames map[string]string) string {
+	// replace longer names first so that a name is never replaced inside another
+	names := make([]string, 0, len(variableNames))
+	for name := range variableNames {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		newName := variableNames[name]
+		if newName == name {
+			continue
+		}
+		pattern := regexp.MustCompile(`(^|[^A-Za-z0-9_$])` + regexp.QuoteMeta(name) + `($|[^A-Za-z0-9_$])`)
+		// repeat to handle adjacent references that share a delimiter
+		for pattern.MatchString(condition) {
+			condition = pattern.ReplaceAllString(condition, "${1}"+newName+"${2}")
+		}
+	}
+	return condition
+}
+
+// migrateCommand converts a CACAO 1.1 command to its 2.0 equivalent
+func migrateCommand(command Command) (Command, error) {
+	switch command.Type {
+	case CACAO_COMMAND_TYPE_OPENC2:
+		// the 1.1 command holds the OpenC2 JSON, the endpoint is not known
+		var body interface{}
+		if err := json.Unmarshal([]byte(command.Command), &body); err != nil {
+			return Command{}, fmt.Errorf("could not parse OpenC2 command: %s", err)
+		}
+		return NewOpenC2Command(CACAO_SPEC_VERSION_20, "/", nil, body, command.Description)
+	case CACAO_COMMAND_TYPE_JUPYTER, CACAO_COMMAND_TYPE_KESTREL, CACAO_COMMAND_TYPE_YARA:
+		if command.Command != "" {
+			command.CommandB64 = base64.StdEncoding.EncodeToString([]byte(command.Command))
+			command.Command = ""
+		}
+	}
+	return validated(command, CACAO_SPEC_VERSION_20)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	timestamp := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cacaoPlaybook11, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_11, cacao.WithTimestamp(timestamp))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	migrationTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	migrated, err := cacao.Migrate(cacaoPlaybook11, cacao.WithTimestamp(migrationTime))
+	if err != nil {
+		t.Fatalf("could not migrate playbook: %s", err)
+	}
+	assert.Equal(t, cacao.CACAO_SPEC_VERSION_20_VALUE, migrated.SpecVersion)
+	assert.Equal(t, timestamp, *migrated.Created)
+	assert.Equal(t, migrationTime, *migrated.Modified)
+	assert.Equal(t, cacao.CACAO_SPEC_VERSION_11, cacaoPlaybook11.SpecVersion, "the input must not be modified")
+	assert.Empty(t, cacao.Validate(migrated))
+	data, err := json.Marshal(migrated)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	violations, err := cacao.ValidateSchema(data, "")
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	// the step IDs match those of a direct 2.0 conversion
+	cacaoPlaybook20, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(timestamp))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	assert.Equal(t, cacaoPlaybook20.WorkflowStart, migrated.WorkflowStart)
+	for stepId, step := range cacaoPlaybook20.Workflow {
+		migratedStep, found := migrated.Workflow[stepId]
+		if assert.True(t, found, "step %s", stepId) {
+			assert.Equal(t, step.StepType(), migratedStep.StepType())
+			assert.Equal(t, step.Common().OnCompletion, migratedStep.Common().OnCompletion)
+		}
+	}
+}
+
+func TestMigrateVariablesAndCommands(t *testing.T) {
+	cacaoPlaybook := &cacao.CacaoPlaybook{
+		Type:          "playbook",
+		SpecVersion:   cacao.CACAO_SPEC_VERSION_11,
+		WorkflowStart: "step--00000000-0000-0000-0000-000000000001",
+		PlaybookVariables: map[string]cacao.PlaybookVariable{
+			"$$ip_addr$$": {Type: "ipv4-addr"},
+			"verdict":     {Type: "string"},
+		},
+		Workflow: cacao.Workflow{
+			"step--00000000-0000-0000-0000-000000000001": &cacao.StartStep{
+				StepCommon: cacao.StepCommon{
+					Type:         cacao.CACAO_STEP_TYPE_START,
+					OnCompletion: "step--00000000-0000-0000-0000-000000000002",
+				},
+			},
+			"step--00000000-0000-0000-0000-000000000002": &cacao.IfConditionStep{
+				StepCommon: cacao.StepCommon{
+					Type:   cacao.CACAO_STEP_TYPE_IF_COND,
+					InArgs: []string{"verdict", "$$ip_addr$$"},
+				},
+				Condition: "verdict == 'malicious' AND $$ip_addr$$ != '10.0.0.1'",
+				OnTrue:    "step--00000000-0000-0000-0000-000000000003",
+			},
+			"step--00000000-0000-0000-0000-000000000003": &cacao.ActionStep{
+				StepCommon: cacao.StepCommon{
+					Type: cacao.CACAO_STEP_TYPE_11_SINGLE,
+				},
+				Commands: []cacao.Command{
+					{Type: cacao.CACAO_COMMAND_TYPE_OPENC2, Command: `{"action":"deny"}`},
+					{Type: cacao.CACAO_COMMAND_TYPE_YARA, Command: "rule x { condition: true }"},
+				},
+			},
+		},
+	}
+	migrated, err := cacao.Migrate(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not migrate playbook: %s", err)
+	}
+	assert.Contains(t, migrated.PlaybookVariables, "__ip_addr__")
+	assert.Contains(t, migrated.PlaybookVariables, "__verdict__")
+	assert.Equal(t, "start--00000000-0000-0000-0000-000000000001", migrated.WorkflowStart)
+	ifStep := migrated.Workflow["if-condition--00000000-0000-0000-0000-000000000002"].(*cacao.IfConditionStep)
+	assert.Equal(t, "__verdict__ == 'malicious' AND __ip_addr__ != '10.0.0.1'", ifStep.Condition)
+	assert.Equal(t, []string{"__verdict__", "__ip_addr__"}, ifStep.InArgs)
+	assert.Equal(t, "action--00000000-0000-0000-0000-000000000003", ifStep.OnTrue)
+	actionStep := migrated.Workflow["action--00000000-0000-0000-0000-000000000003"].(*cacao.ActionStep)
+	assert.Equal(t, cacao.CACAO_STEP_TYPE_ACTION, actionStep.Type)
+	original := cacaoPlaybook.Workflow["step--00000000-0000-0000-0000-000000000003"].(*cacao.ActionStep)
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_OPENC2, original.Commands[0].Type, "the input must not be modified")
+	assert.Equal(t, cacao.CACAO_COMMAND_TYPE_OPENC2_HTTP, actionStep.Commands[0].Type)
+	assert.JSONEq(t, `{"action":"deny"}`, actionStep.Commands[0].Content)
+	assert.Empty(t, actionStep.Commands[1].Command)
+	assert.NotEmpty(t, actionStep.Commands[1].CommandB64)
+
+	_, err = cacao.Migrate(migrated)
+	assert.Error(t, err, "a 2.0 playbook cannot be migrated")
+}
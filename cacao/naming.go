@@ -0,0 +1,129 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MAX_VARIABLE_NAME_LENGTH limits the length of generated variable names,
+// not counting the underscores around CACAO 2.0 names
+const MAX_VARIABLE_NAME_LENGTH int = 32
+
+// variableName mangles a name to make it a valid variable name: lower case
+// ASCII letters, digits and single underscores between words
+func variableName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9')
+	})
+	return strings.Join(words, "_")
+}
+
+// shortVariableName returns the variable name of a text, shortened to
+// MAX_VARIABLE_NAME_LENGTH by dropping whole words where possible
+func shortVariableName(text string) string {
+	name := variableName(text)
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "v_" + name
+	}
+	for len(name) > MAX_VARIABLE_NAME_LENGTH {
+		i := strings.LastIndex(name, "_")
+		if i <= 0 {
+			name = name[:MAX_VARIABLE_NAME_LENGTH]
+			break
+		}
+		name = name[:i]
+	}
+	return name
+}
+
+// variableNamer gives out the variable names of a playbook. Each owner, eg.
+// a gateway or a process variable, has one name, and no two owners share a
+// name: when the names of two owners would collide the later one gets a
+// numbered name, eg. "is_it_malicious_2", and the collision is reported.
+type variableNamer struct {
+	specVersion string
+	report      *Report
+	// names maps each owner to its name
+	names map[string]string
+	// owners maps each name given out to its owner
+	owners map[string]string
+}
+
+func newVariableNamer(specVersion string, report *Report) *variableNamer {
+	return &variableNamer{
+		specVersion: specVersion,
+		report:      report,
+		names:       make(map[string]string),
+		owners:      make(map[string]string),
+	}
+}
+
+// format returns the variable name in the form of the spec version, eg. __ip__ for CACAO 2.0
+func (n *variableNamer) format(name string) string {
+	if n.specVersion == CACAO_SPEC_VERSION_20 {
+		return fmt.Sprintf("__%s__", name)
+	}
+	return name
+}
+
+// name returns the name of the variable of an owner, derived from the text
+// the first time the owner is named
+func (n *variableNamer) name(owner, text string) string {
+	if name, found := n.names[owner]; found {
+		return name
+	}
+	base := shortVariableName(text)
+	if base == "" {
+		base = "var"
+	}
+	name := n.format(base)
+	for i := 2; n.owners[name] != ""; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		truncated := base
+		if len(truncated)+len(suffix) > MAX_VARIABLE_NAME_LENGTH {
+			truncated = truncated[:MAX_VARIABLE_NAME_LENGTH-len(suffix)]
+		}
+		name = n.format(truncated + suffix)
+	}
+	if name != n.format(base) {
+		n.report.warnf(DIAGNOSTIC_CODE_RENAMED_VARIABLE, owner, "", "", "variable name %s is taken by %s, using %s", n.format(base), n.owners[n.format(base)], name)
+	}
+	n.names[owner] = name
+	n.owners[name] = owner
+	return name
+}
+
+// gateway returns the name of the variable holding the decision of a gateway
+func (n *variableNamer) gateway(id, question string) string {
+	if strings.TrimSpace(question) == "" {
+		question = id
+	}
+	return n.name("gateway "+id, question)
+}
+
+// dataElement returns the name of the variable of a data object or property
+func (n *variableNamer) dataElement(id, name string) string {
+	return n.name("data element "+id, name)
+}
+
+// processVariable returns the name of the variable of a process variable,
+// which tasks and conditions refer to by its name
+func (n *variableNamer) processVariable(name string) string {
+	return n.name(fmt.Sprintf("process variable %q", name), name)
+}
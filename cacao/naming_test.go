@@ -0,0 +1,103 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// variableFor returns a generated variable name in the form used by a spec version
+func variableFor(specVersion, name string) string {
+	if specVersion == cacao.CACAO_SPEC_VERSION_20 {
+		return fmt.Sprintf("__%s__", name)
+	}
+	return name
+}
+
+const namingProcessTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Naming" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:exclusiveGateway id="Gateway_1" name="Is it malicious?">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+    </bpmn:exclusiveGateway>
+    <bpmn:exclusiveGateway id="Gateway_2" name="Is it malicious!">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_4</bpmn:outgoing>
+      <bpmn:outgoing>Flow_5</bpmn:outgoing>
+    </bpmn:exclusiveGateway>
+    <bpmn:exclusiveGateway id="Gateway_3" name="Does the alert that was raised by the endpoint detection and response agent meet the policy threshold for review by the incident response team?">
+      <bpmn:incoming>Flow_4</bpmn:incoming>
+      <bpmn:outgoing>Flow_6</bpmn:outgoing>
+      <bpmn:outgoing>Flow_7</bpmn:outgoing>
+    </bpmn:exclusiveGateway>
+    <bpmn:endEvent id="Event_1" name="End">
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+      <bpmn:incoming>Flow_5</bpmn:incoming>
+      <bpmn:incoming>Flow_6</bpmn:incoming>
+      <bpmn:incoming>Flow_7</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_2" name="Yes" sourceRef="Gateway_1" targetRef="Gateway_2" />
+    <bpmn:sequenceFlow id="Flow_3" name="No" sourceRef="Gateway_1" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_4" name="Yes" sourceRef="Gateway_2" targetRef="Gateway_3" />
+    <bpmn:sequenceFlow id="Flow_5" name="No" sourceRef="Gateway_2" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_6" name="Yes" sourceRef="Gateway_3" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_7" name="No" sourceRef="Gateway_3" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestGatewayVariableNames(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(namingProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		t.Run(specVersion, func(t *testing.T) {
+			cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			conditions := make(map[string]string)
+			for _, step := range cacaoPlaybook.Workflow {
+				if ifStep, ok := step.(*cacao.IfConditionStep); ok {
+					conditions[ifStep.Name] = ifStep.InArgs[0]
+				}
+			}
+			// questions that differ only by punctuation get distinct variables
+			assert.Equal(t, variableFor(specVersion, "is_it_malicious"), conditions["Is it malicious?"])
+			assert.Equal(t, variableFor(specVersion, "is_it_malicious_2"), conditions["Is it malicious!"])
+			assert.Len(t, cacaoPlaybook.PlaybookVariables, 3)
+			for question, name := range conditions {
+				assert.Equal(t, question, cacaoPlaybook.PlaybookVariables[name].Description, "the description holds the question")
+				assert.LessOrEqual(t, len(strings.Trim(name, "_")), cacao.MAX_VARIABLE_NAME_LENGTH, name)
+			}
+			long := conditions["Does the alert that was raised by the endpoint detection and response agent meet the policy threshold for review by the incident response team?"]
+			assert.Equal(t, variableFor(specVersion, "does_the_alert_that_was_raised"), long, "long questions are cut at a word boundary")
+		})
+	}
+}
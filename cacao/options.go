@@ -0,0 +1,108 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ConvertOption customises the conversion performed by ConvertToCacao
+type ConvertOption func(*convertOptions)
+
+// convertOptions holds the settings of a conversion
+type convertOptions struct {
+	timestamp    *time.Time
+	markings     []DataMarking
+	mappingRules *MappingRules
+	// the classifier of the branches of two-way gateways
+	branchClassifier BranchClassifier
+	// whether to embed the cross-references as step extensions
+	crossReferenceExtension bool
+	// whether to embed the provenance extension, and the hash of the BPMN file
+	provenance     bool
+	sourceFileHash string
+}
+
+func newConvertOptions(options []ConvertOption) *convertOptions {
+	settings := new(convertOptions)
+	for _, option := range options {
+		option(settings)
+	}
+	return settings
+}
+
+// WithTimestamp sets the created and modified timestamps of the playbook
+// instead of using the current time, for reproducible output. The timestamp
+// is converted to UTC with millisecond precision.
+func WithTimestamp(timestamp time.Time) ConvertOption {
+	return func(settings *convertOptions) {
+		timestamp = timestamp.UTC().Truncate(time.Millisecond)
+		settings.timestamp = &timestamp
+	}
+}
+
+// WithMarkings adds data markings to the playbook, in addition to those set
+// on the BPMN process using the cacao:tlp, cacao:statement and cacao:iep
+// extension properties
+func WithMarkings(markings ...DataMarking) ConvertOption {
+	return func(settings *convertOptions) {
+		settings.markings = append(settings.markings, markings...)
+	}
+}
+
+// WithMappingRules selects the command, agent and targets of tasks using
+// mapping rules, see LoadMappingRules. Tasks that no rule matches keep the
+// default command type of their BPMN element type.
+func WithMappingRules(rules *MappingRules) ConvertOption {
+	return func(settings *convertOptions) {
+		settings.mappingRules = rules
+	}
+}
+
+// WithBranchClassifier selects the classifier deciding which flow leaving a
+// two-way gateway is the positive branch. By default a LabelClassifier
+// knowing the built-in labels and those of the mapping rules is used.
+func WithBranchClassifier(classifier BranchClassifier) ConvertOption {
+	return func(settings *convertOptions) {
+		settings.branchClassifier = classifier
+	}
+}
+
+// WithCrossReferenceExtension embeds the cross-reference of each step, see
+// CrossReference, in the step as a step extension and adds its extension
+// definition to the playbook. Step extensions only exist in CACAO 2.0, for
+// other versions a warning is reported instead.
+func WithCrossReferenceExtension() ConvertOption {
+	return func(settings *convertOptions) {
+		settings.crossReferenceExtension = true
+	}
+}
+
+// WithProvenance embeds the provenance extension, recording the BPMN
+// element of each step and the hash of the BPMN file it was read from, so
+// that the playbook can be edited and later merged with a newly generated
+// one, see MergeEdited. Extensions only exist in CACAO 2.0, for other
+// versions a warning is reported instead.
+func WithProvenance(source []byte) ConvertOption {
+	return func(settings *convertOptions) {
+		hash := sha256.Sum256(source)
+		settings.provenance = true
+		settings.sourceFileHash = hex.EncodeToString(hash[:])
+	}
+}
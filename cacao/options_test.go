@@ -0,0 +1,84 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// a process whose gateway and last task have no targets, so end steps are synthesised
+const danglingFlowsTestString string = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Dangling Flows">
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:userTask id="Activity_1" name="Triage">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:exclusiveGateway id="Gateway_1" name="Escalate?">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+      <bpmn:outgoing>Flow_4</bpmn:outgoing>
+    </bpmn:exclusiveGateway>
+    <bpmn:userTask id="Activity_2" name="Escalate">
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+    </bpmn:userTask>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_3" name="Yes" sourceRef="Gateway_1" targetRef="Activity_2" />
+    <bpmn:sequenceFlow id="Flow_4" name="Maybe" sourceRef="Gateway_1" targetRef="Activity_3" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestConvertToCacaoReproducible(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(danglingFlowsTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	timestamp := time.Date(2023, 5, 1, 12, 30, 0, 123456789, time.FixedZone("AEST", 10*60*60))
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(timestamp))
+		if err != nil {
+			t.Fatalf("could not convert BPMN to Cacao: %s", err)
+		}
+		assert.Equal(t, "2023-05-01T02:30:00.123Z", cacaoPlaybook.Created.Format("2006-01-02T15:04:05.000Z07:00"))
+		assert.Equal(t, cacaoPlaybook.Created, cacaoPlaybook.Modified)
+		// the last task and the unmatched "No" branch each get a synthesised end step
+		endSteps := 0
+		for _, step := range cacaoPlaybook.Workflow {
+			if _, ok := step.(*cacao.EndStep); ok {
+				endSteps++
+			}
+		}
+		assert.Equal(t, 2, endSteps)
+		output, err := json.Marshal(cacaoPlaybook)
+		if err != nil {
+			t.Fatalf("could not marshal Cacao playbook: %s", err)
+		}
+		outputs = append(outputs, string(output))
+	}
+	assert.Equal(t, outputs[0], outputs[1])
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// the roles of the steps generated for an implicit parallel split, see
+// synthesizedStepId
+const SPLIT_ROLE_PARALLEL string = "split"
+const SPLIT_ROLE_JOIN string = "join"
+
+// UNJOINED_BRANCHES_FIX suggests how to join parallel branches that could
+// not be joined
+const UNJOINED_BRANCHES_FIX string = "merge the branches with a parallel gateway"
+
+// nextStep returns the step following a task or event. A task or event with
+// more than one outgoing flow starts all of them, which is an implicit
+// parallel split, so a parallel step is generated whose branches are the
+// targets of the flows. An empty string is returned if no flow leaves it.
+func nextStep(sourceId, specVersion string, stepMap map[string]string, graph *bpmn.FlowGraph, report *Report, cacaoPlaybook *CacaoPlaybook) string {
+	outgoing := graph.Outgoing(sourceId)
+	if len(outgoing) < 2 {
+		return stepMap[graph.Next(sourceId)]
+	}
+	parallelStepType := CACAO_STEP_TYPE_PARALLEL
+	endStepType := CACAO_STEP_TYPE_END
+	if specVersion == CACAO_SPEC_VERSION_11 {
+		parallelStepType = CACAO_STEP_TYPE_11_STEP
+		endStepType = CACAO_STEP_TYPE_11_STEP
+	}
+	step := &ParallelStep{
+		StepCommon: StepCommon{
+			Type: CACAO_STEP_TYPE_PARALLEL,
+			Name: graph.Name(sourceId),
+		},
+	}
+	for _, flow := range outgoing {
+		branch := stepMap[flow.TargetRef]
+		if branch == "" {
+			// create another end step for a branch leading nowhere
+			branch = synthesizedStepId(endStepType, flow.Id, "end")
+			cacaoPlaybook.Workflow[branch] = newEndStep()
+			report.synthesize(branch, flow.Id, missingTargetReason(flow))
+		}
+		step.NextSteps = append(step.NextSteps, branch)
+	}
+	stepId := synthesizedStepId(parallelStepType, sourceId, SPLIT_ROLE_PARALLEL)
+	cacaoPlaybook.Workflow[stepId] = step
+	report.synthesize(stepId, sourceId, fmt.Sprintf("the element has %d outgoing flows, which are taken in parallel", len(outgoing)))
+	return stepId
+}
+
+// joinImplicitSplits completes the parallel steps generated for implicit
+// splits. Where the branches of a split merge again at a step, as when the
+// flows of a fan-out all enter the same task, that step becomes the
+// on_completion step of the parallel step and the branches end before it,
+// so that it runs once after all of them rather than once per branch.
+// Splits are left unjoined, and reported, where only some of the branches
+// merge or where a step of a branch that leads to the merge is also reached
+// from outside the split.
+func joinImplicitSplits(bpmnProcess bpmn.BpmnProcess, specVersion string, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	parallelStepType := CACAO_STEP_TYPE_PARALLEL
+	endStepType := CACAO_STEP_TYPE_END
+	if specVersion == CACAO_SPEC_VERSION_11 {
+		parallelStepType = CACAO_STEP_TYPE_11_STEP
+		endStepType = CACAO_STEP_TYPE_11_STEP
+	}
+	var sourceIds []string
+	if bpmnProcess.StartEvent != nil {
+		sourceIds = append(sourceIds, bpmnProcess.StartEvent.Id)
+	}
+	for _, tasks := range [][]bpmn.BpmnTask{bpmnProcess.IntermediateCatchEvent, bpmnProcess.ServiceTask, bpmnProcess.UserTask, bpmnProcess.ManualTask, bpmnProcess.ScriptTask, bpmnProcess.SendTask, bpmnProcess.Task, bpmnProcess.IntermediateThrowEvent} {
+		for _, task := range tasks {
+			sourceIds = append(sourceIds, task.Id)
+		}
+	}
+	for _, sourceId := range sourceIds {
+		stepId := synthesizedStepId(parallelStepType, sourceId, SPLIT_ROLE_PARALLEL)
+		step, ok := cacaoPlaybook.Workflow[stepId].(*ParallelStep)
+		if !ok {
+			continue
+		}
+		join := findJoin(step.NextSteps, cacaoPlaybook.Workflow)
+		if join == "" {
+			if merge := findPartialMerge(step.NextSteps, cacaoPlaybook.Workflow); merge != "" {
+				report.warnf(DIAGNOSTIC_CODE_UNJOINED_BRANCHES, sourceId, stepId, UNJOINED_BRANCHES_FIX, "only some of the parallel branches merge at step %q, which runs once for each of them", cacaoPlaybook.Workflow[merge].Common().Name)
+			}
+			continue
+		}
+		var branches []string
+		for _, branch := range step.NextSteps {
+			if branch != join {
+				branches = append(branches, branch)
+			}
+		}
+		if len(branches) == 0 {
+			continue
+		}
+		// steps also reached from outside the split must keep leading to the
+		// join, which the branches passing through them would then enter
+		steps := branchSteps(branches, join, cacaoPlaybook.Workflow)
+		shared := sharedSteps(steps, stepId, cacaoPlaybook.Workflow)
+		if entering := enteringStep(shared, join, cacaoPlaybook.Workflow); entering != "" {
+			report.warnf(DIAGNOSTIC_CODE_UNJOINED_BRANCHES, sourceId, stepId, UNJOINED_BRANCHES_FIX, "the parallel branches merge at step %q, but step %q leading to it is also reached from outside the branches", cacaoPlaybook.Workflow[join].Common().Name, cacaoPlaybook.Workflow[entering].Common().Name)
+			continue
+		}
+		// the branches end where they would have entered the join
+		endStepId := synthesizedStepId(endStepType, sourceId, SPLIT_ROLE_JOIN)
+		for branchStepId := range steps {
+			renameStepReferences(cacaoPlaybook.Workflow[branchStepId], func(referenced string) string {
+				if referenced == join {
+					return endStepId
+				}
+				return referenced
+			})
+		}
+		cacaoPlaybook.Workflow[endStepId] = newEndStep()
+		report.synthesize(endStepId, sourceId, fmt.Sprintf("the parallel branches end here before joining at %s", join))
+		step.NextSteps = branches
+		step.OnCompletion = join
+	}
+}
+
+// findJoin returns the nearest step, other than an end step, that every
+// branch reaches, or an empty string if the branches do not merge
+func findJoin(branches []string, workflow Workflow) string {
+	if len(branches) < 2 {
+		return ""
+	}
+	reachable := make([]map[string]bool, len(branches))
+	for i, branch := range branches {
+		reachable[i] = branchSteps([]string{branch}, "", workflow)
+	}
+	// search breadth first from the first branch so the nearest step is found
+	visited := map[string]bool{branches[0]: true}
+	queue := []string{branches[0]}
+	for len(queue) > 0 {
+		stepId := queue[0]
+		queue = queue[1:]
+		step, found := workflow[stepId]
+		if !found {
+			continue
+		}
+		if _, isEnd := step.(*EndStep); !isEnd {
+			reachedByAll := true
+			for _, steps := range reachable[1:] {
+				reachedByAll = reachedByAll && steps[stepId]
+			}
+			if reachedByAll {
+				return stepId
+			}
+		}
+		for _, reference := range stepReferences(step) {
+			if !visited[reference.StepID] {
+				visited[reference.StepID] = true
+				queue = append(queue, reference.StepID)
+			}
+		}
+	}
+	return ""
+}
+
+// findPartialMerge returns the nearest step, other than an end step, that
+// more than one branch reaches, or an empty string if no branches merge
+func findPartialMerge(branches []string, workflow Workflow) string {
+	reachable := make([]map[string]bool, len(branches))
+	for i, branch := range branches {
+		reachable[i] = branchSteps([]string{branch}, "", workflow)
+	}
+	for i, branch := range branches {
+		// search breadth first from each branch so the nearest step is found
+		visited := map[string]bool{branch: true}
+		queue := []string{branch}
+		for len(queue) > 0 {
+			stepId := queue[0]
+			queue = queue[1:]
+			step, found := workflow[stepId]
+			if !found {
+				continue
+			}
+			if _, isEnd := step.(*EndStep); !isEnd {
+				for j, steps := range reachable {
+					if j != i && steps[stepId] {
+						return stepId
+					}
+				}
+			}
+			for _, reference := range stepReferences(step) {
+				if !visited[reference.StepID] {
+					visited[reference.StepID] = true
+					queue = append(queue, reference.StepID)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// sharedSteps returns the given steps of the branches of a parallel step
+// that are also reachable from steps outside the branches other than the
+// parallel step itself
+func sharedSteps(steps map[string]bool, parallelStepId string, workflow Workflow) map[string]bool {
+	var entries []string
+	edges := make(map[string][]string)
+	for _, stepId := range sortedStepIds(workflow) {
+		for _, reference := range stepReferences(workflow[stepId]) {
+			if !steps[reference.StepID] {
+				continue
+			}
+			if steps[stepId] {
+				edges[stepId] = append(edges[stepId], reference.StepID)
+			} else if stepId != parallelStepId {
+				entries = append(entries, reference.StepID)
+			}
+		}
+	}
+	return reachableSteps(entries, edges)
+}
+
+// enteringStep returns one of the given steps that refers to the join
+// step, or an empty string if none does
+func enteringStep(steps map[string]bool, join string, workflow Workflow) string {
+	for _, stepId := range sortedStepIds(workflow) {
+		if !steps[stepId] {
+			continue
+		}
+		for _, reference := range stepReferences(workflow[stepId]) {
+			if reference.StepID == join {
+				return stepId
+			}
+		}
+	}
+	return ""
+}
+
+// branchSteps returns the steps reachable from the given steps without
+// passing through the stop step
+func branchSteps(from []string, stop string, workflow Workflow) map[string]bool {
+	edges := make(map[string][]string)
+	for stepId, step := range workflow {
+		if stepId == stop {
+			continue
+		}
+		for _, reference := range stepReferences(step) {
+			if reference.StepID != stop {
+				edges[stepId] = append(edges[stepId], reference.StepID)
+			}
+		}
+	}
+	reachable := reachableSteps(from, edges)
+	delete(reachable, stop)
+	return reachable
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// implicitSplitTestString fans out from the start event to two tasks without
+// a gateway, and from "Triage" to two tasks that merge again at "Report"
+const implicitSplitTestString string = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Fan out" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Alert">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:userTask id="Activity_notify" name="Notify">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+    </bpmn:userTask>
+    <bpmn:userTask id="Activity_triage" name="Triage">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+      <bpmn:outgoing>Flow_4</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:serviceTask id="Activity_hosts" name="Isolate hosts">
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+      <bpmn:outgoing>Flow_5</bpmn:outgoing>
+    </bpmn:serviceTask>
+    <bpmn:serviceTask id="Activity_accounts" name="Disable accounts">
+      <bpmn:incoming>Flow_4</bpmn:incoming>
+      <bpmn:outgoing>Flow_6</bpmn:outgoing>
+    </bpmn:serviceTask>
+    <bpmn:userTask id="Activity_report" name="Report">
+      <bpmn:incoming>Flow_5</bpmn:incoming>
+      <bpmn:incoming>Flow_6</bpmn:incoming>
+      <bpmn:outgoing>Flow_7</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:endEvent id="Event_1" name="Done">
+      <bpmn:incoming>Flow_7</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_notify" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="StartEvent_1" targetRef="Activity_triage" />
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Activity_triage" targetRef="Activity_hosts" />
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Activity_triage" targetRef="Activity_accounts" />
+    <bpmn:sequenceFlow id="Flow_5" sourceRef="Activity_hosts" targetRef="Activity_report" />
+    <bpmn:sequenceFlow id="Flow_6" sourceRef="Activity_accounts" targetRef="Activity_report" />
+    <bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_report" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestImplicitParallelSplit(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(implicitSplitTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		t.Run(specVersion, func(t *testing.T) {
+			cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			stepNamed := func(name string) cacao.Step {
+				for _, step := range cacaoPlaybook.Workflow {
+					if step.Common().Name == name && step.Common().Type != cacao.CACAO_STEP_TYPE_PARALLEL {
+						return step
+					}
+				}
+				t.Fatalf("no step named %q", name)
+				return nil
+			}
+			nameOf := func(stepId string) string {
+				return cacaoPlaybook.Workflow[stepId].Common().Name
+			}
+
+			// both flows leaving the start event are taken
+			start := stepNamed("Alert")
+			split, ok := cacaoPlaybook.Workflow[start.Common().OnCompletion].(*cacao.ParallelStep)
+			if assert.True(t, ok, "start step is not followed by a parallel step") {
+				assert.Len(t, split.NextSteps, 2)
+				assert.Equal(t, "Notify", nameOf(split.NextSteps[0]))
+				assert.Equal(t, "Triage", nameOf(split.NextSteps[1]))
+				assert.Empty(t, split.OnCompletion)
+			}
+
+			// the branches leaving "Triage" join at "Report", which runs once
+			// after both of them
+			triage := stepNamed("Triage")
+			split, ok = cacaoPlaybook.Workflow[triage.Common().OnCompletion].(*cacao.ParallelStep)
+			if assert.True(t, ok, "Triage is not followed by a parallel step") {
+				assert.Equal(t, "Report", nameOf(split.OnCompletion))
+				if assert.Len(t, split.NextSteps, 2) {
+					assert.Equal(t, "Isolate hosts", nameOf(split.NextSteps[0]))
+					assert.Equal(t, "Disable accounts", nameOf(split.NextSteps[1]))
+				}
+			}
+			for _, name := range []string{"Isolate hosts", "Disable accounts"} {
+				_, isEnd := cacaoPlaybook.Workflow[stepNamed(name).Common().OnCompletion].(*cacao.EndStep)
+				assert.True(t, isEnd, "branch %s does not end before the join", name)
+			}
+			_, isEnd := cacaoPlaybook.Workflow[stepNamed("Report").Common().OnCompletion].(*cacao.EndStep)
+			assert.True(t, isEnd)
+
+			assert.Empty(t, cacao.Validate(cacaoPlaybook))
+			data, err := json.Marshal(cacaoPlaybook)
+			if err != nil {
+				t.Fatalf("could not marshal Cacao playbook: %s", err)
+			}
+			violations, err := cacao.ValidateSchema(data, specVersion)
+			assert.NoError(t, err)
+			assert.Empty(t, violations)
+		})
+	}
+}
+
+// partialMergeTestString fans out to three tasks, two of which merge at "Report"
+const partialMergeTestString string = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Partial merge" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Alert">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:userTask id="Activity_triage" name="Triage" />
+    <bpmn:serviceTask id="Activity_hosts" name="Isolate hosts" />
+    <bpmn:serviceTask id="Activity_accounts" name="Disable accounts" />
+    <bpmn:userTask id="Activity_notify" name="Notify" />
+    <bpmn:userTask id="Activity_report" name="Report" />
+    <bpmn:endEvent id="Event_1" name="Done" />
+    <bpmn:endEvent id="Event_2" name="Notified" />
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_triage" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_triage" targetRef="Activity_hosts" />
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Activity_triage" targetRef="Activity_accounts" />
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Activity_triage" targetRef="Activity_notify" />
+    <bpmn:sequenceFlow id="Flow_5" sourceRef="Activity_hosts" targetRef="Activity_report" />
+    <bpmn:sequenceFlow id="Flow_6" sourceRef="Activity_accounts" targetRef="Activity_report" />
+    <bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_report" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_8" sourceRef="Activity_notify" targetRef="Event_2" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+// sharedBranchTestString fans out to two tasks that merge at "Report", one
+// of which is also reached from a gateway before the split
+const sharedBranchTestString string = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Shared branch" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Alert">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:exclusiveGateway id="Gateway_1" name="Malicious?" />
+    <bpmn:userTask id="Activity_triage" name="Triage" />
+    <bpmn:serviceTask id="Activity_hosts" name="Isolate hosts" />
+    <bpmn:serviceTask id="Activity_accounts" name="Disable accounts" />
+    <bpmn:userTask id="Activity_report" name="Report" />
+    <bpmn:endEvent id="Event_1" name="Done" />
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_2" name="Yes" sourceRef="Gateway_1" targetRef="Activity_triage" />
+    <bpmn:sequenceFlow id="Flow_3" name="No" sourceRef="Gateway_1" targetRef="Activity_accounts" />
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Activity_triage" targetRef="Activity_hosts" />
+    <bpmn:sequenceFlow id="Flow_5" sourceRef="Activity_triage" targetRef="Activity_accounts" />
+    <bpmn:sequenceFlow id="Flow_6" sourceRef="Activity_hosts" targetRef="Activity_report" />
+    <bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_accounts" targetRef="Activity_report" />
+    <bpmn:sequenceFlow id="Flow_8" sourceRef="Activity_report" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestUnjoinedParallelSplit(t *testing.T) {
+	testCases := []struct {
+		name       string
+		bpmnString string
+		message    string
+	}{
+		{"partial merge", partialMergeTestString, `only some of the parallel branches merge at step "Report"`},
+		{"shared branch", sharedBranchTestString, `step "Disable accounts" leading to it is also reached from outside the branches`},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bpmnDefinitions, err := bpmn.ReadBpmn([]byte(testCase.bpmnString))
+			if err != nil {
+				t.Fatalf("could not read input: %s", err)
+			}
+			cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			// the split is left as it is and reported
+			reportId := stepIdNamed(t, cacaoPlaybook, "Report")
+			for _, name := range []string{"Isolate hosts", "Disable accounts"} {
+				assert.Equal(t, reportId, cacaoPlaybook.Workflow[stepIdNamed(t, cacaoPlaybook, name)].Common().OnCompletion, name)
+			}
+			split := cacaoPlaybook.Workflow[cacaoPlaybook.Workflow[stepIdNamed(t, cacaoPlaybook, "Triage")].Common().OnCompletion]
+			assert.Empty(t, split.Common().OnCompletion)
+			if assert.Len(t, report.Warnings(), 1) {
+				warning := report.Warnings()[0]
+				assert.Equal(t, cacao.DIAGNOSTIC_CODE_UNJOINED_BRANCHES, warning.Code)
+				assert.Equal(t, "Activity_triage", warning.ElementID)
+				assert.Contains(t, warning.Message, testCase.message)
+			}
+			assert.Empty(t, cacao.Validate(cacaoPlaybook))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+)
+
+// the path of the module of the converter, used to find its version
+const CONVERTER_MODULE_PATH string = "github.com/cydarm/bpmn-to-cacao"
+
+// the version of the provenance extension
+const PROVENANCE_EXTENSION_VERSION string = "1.0.0"
+
+// the JSON schema of the provenance extension, the properties of the
+// playbook extension and of the step extensions are different
+const PROVENANCE_EXTENSION_SCHEMA string = `{"type":"object","properties":{"process_id":{"type":"string"},"source_file_hash":{"type":"string"},"converter_version":{"type":"string"},"element_id":{"type":"string"},"synthesized":{"type":"boolean"},"generated_hash":{"type":"string"}}}`
+
+// the ID of the extension definition of the provenance extension
+var provenanceExtensionId = fmt.Sprintf("extension-definition--%s", deterministicUuid("bpmn-provenance"))
+
+// PlaybookProvenance is the provenance extension of a playbook, recording
+// what it was converted from and by which version of the converter
+type PlaybookProvenance struct {
+	ProcessID string `json:"process_id"`
+	// SourceFileHash is the SHA-256 hash of the BPMN file
+	SourceFileHash   string `json:"source_file_hash"`
+	ConverterVersion string `json:"converter_version"`
+}
+
+// StepProvenance is the provenance extension of a step, recording the BPMN
+// element it was created for, see CrossReference, and a hash of the step as
+// it was generated. A step whose generated hash changes between two
+// conversions was affected by a change to the BPMN.
+type StepProvenance struct {
+	ElementID     string `json:"element_id"`
+	Synthesized   bool   `json:"synthesized,omitempty"`
+	GeneratedHash string `json:"generated_hash"`
+}
+
+// converterVersion returns the version of the converter module, which is
+// "(devel)" when it was not built from a released version
+func converterVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == CONVERTER_MODULE_PATH && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, module := range info.Deps {
+		if module.Path == CONVERTER_MODULE_PATH {
+			return module.Version
+		}
+	}
+	return "(devel)"
+}
+
+// stepHash returns the SHA-256 hash of the canonical JSON of a step,
+// ignoring its extensions
+func stepHash(step Step) (string, error) {
+	copied, err := CopyStep(step)
+	if err != nil {
+		return "", err
+	}
+	copied.Common().StepExtensions = nil
+	data, err := json.Marshal(copied)
+	if err != nil {
+		return "", err
+	}
+	canonical, err := Canonicalize(data)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(canonical)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// embedProvenance adds the provenance extension to the playbook and to the
+// steps with a cross-reference, and the definition of the extension
+func embedProvenance(references []CrossReference, processId, sourceFileHash string, cacaoPlaybook *CacaoPlaybook) error {
+	for _, reference := range references {
+		step := cacaoPlaybook.Workflow[reference.StepID]
+		hash, err := stepHash(step)
+		if err != nil {
+			return fmt.Errorf("cannot hash step %s: %s", reference.StepID, err)
+		}
+		setExtension(&step.Common().StepExtensions, provenanceExtensionId, StepProvenance{
+			ElementID:     reference.ElementID,
+			Synthesized:   reference.Synthesized,
+			GeneratedHash: hash,
+		})
+	}
+	setExtension(&cacaoPlaybook.PlaybookExtensions, provenanceExtensionId, PlaybookProvenance{
+		ProcessID:        processId,
+		SourceFileHash:   sourceFileHash,
+		ConverterVersion: converterVersion(),
+	})
+	addExtensionDefinition(cacaoPlaybook, provenanceExtensionId, ExtensionDefinition{
+		Type:        CACAO_TYPE_EXTENSION_DEFINITION,
+		Name:        "BPMN provenance",
+		Description: "The BPMN a playbook or step was converted from, used to merge regenerated playbooks with edited ones",
+		CreatedBy:   defaultCreatedBy,
+		Schema:      PROVENANCE_EXTENSION_SCHEMA,
+		Version:     PROVENANCE_EXTENSION_VERSION,
+	})
+	return nil
+}
+
+// stepProvenance returns the provenance extension of a step, or nil if it
+// has none, as for steps added by hand
+func stepProvenance(step Step) *StepProvenance {
+	data, found := step.Common().StepExtensions[provenanceExtensionId]
+	if !found {
+		return nil
+	}
+	provenance := new(StepProvenance)
+	if err := json.Unmarshal(data, provenance); err != nil || provenance.GeneratedHash == "" {
+		return nil
+	}
+	return provenance
+}
+
+// MergeEdited merges a newly generated playbook into a playbook that was
+// generated with the provenance extension, see WithProvenance, and edited
+// since. Steps whose generated hash is unchanged keep their edits, steps
+// whose BPMN source changed are replaced by the generated step, steps whose
+// BPMN element was removed are removed, and steps added by hand are kept.
+// Generated steps missing from the edited playbook are only added if the
+// merged workflow refers to them, so that steps deleted by hand stay deleted.
+// The metadata is combined as by UpdatePlaybook.
+func MergeEdited(edited, generated *CacaoPlaybook) (*CacaoPlaybook, *MergeReport, error) {
+	merged := UpdatePlaybook(edited, generated)
+	report := &MergeReport{Steps: []StepMerge{}, Conflicts: []MergeConflict{}}
+	workflow := make(Workflow)
+	for _, stepId := range sortedStepIds(edited.Workflow) {
+		step := edited.Workflow[stepId]
+		provenance := stepProvenance(step)
+		if provenance == nil {
+			workflow[stepId] = step
+			continue
+		}
+		generatedStep, found := generated.Workflow[stepId]
+		if found {
+			if generatedProvenance := stepProvenance(generatedStep); generatedProvenance != nil && generatedProvenance.GeneratedHash == provenance.GeneratedHash {
+				workflow[stepId] = step
+				continue
+			}
+		}
+		hash, err := stepHash(step)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot hash step %s: %s", stepId, err)
+		}
+		change := StepMerge{StepID: stepId, Name: step.Common().Name, Action: MERGE_ACTION_REMOVED, Edited: hash != provenance.GeneratedHash}
+		if found {
+			workflow[stepId] = generatedStep
+			change.Action = MERGE_ACTION_UPDATED
+		}
+		report.Steps = append(report.Steps, change)
+	}
+	merged.WorkflowStart = edited.WorkflowStart
+	if _, found := workflow[merged.WorkflowStart]; !found {
+		merged.WorkflowStart = generated.WorkflowStart
+	}
+	merged.WorkflowException = edited.WorkflowException
+	// add the generated steps the merged workflow refers to
+	add := func(stepId string) bool {
+		generatedStep, found := generated.Workflow[stepId]
+		if _, present := workflow[stepId]; present || !found {
+			return false
+		}
+		workflow[stepId] = generatedStep
+		report.Steps = append(report.Steps, StepMerge{StepID: stepId, Name: generatedStep.Common().Name, Action: MERGE_ACTION_ADDED})
+		return true
+	}
+	add(merged.WorkflowStart)
+	for changed := true; changed; {
+		changed = false
+		for _, stepId := range sortedStepIds(workflow) {
+			for _, reference := range stepReferences(workflow[stepId]) {
+				changed = add(reference.StepID) || changed
+			}
+		}
+	}
+	merged.Workflow = workflow
+
+	merged.PlaybookVariables = make(map[string]PlaybookVariable)
+	for _, variables := range []map[string]PlaybookVariable{generated.PlaybookVariables, edited.PlaybookVariables} {
+		for name, variable := range variables {
+			merged.PlaybookVariables[name] = variable
+		}
+	}
+	if len(merged.PlaybookVariables) == 0 {
+		merged.PlaybookVariables = nil
+	}
+	setMergedModified(merged, edited, generated)
+	return merged, report, nil
+}
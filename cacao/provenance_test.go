@@ -0,0 +1,195 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// convertWithProvenance converts BPMN to CACAO 2.0 with the provenance
+// extension and reads it back, as if it had been written to a file
+func convertWithProvenance(t *testing.T, source string, timestamp time.Time) *cacao.CacaoPlaybook {
+	return convertAndRead(t, source, cacao.WithProvenance([]byte(source)), cacao.WithTimestamp(timestamp))
+}
+
+// convertAndRead converts BPMN to CACAO 2.0 and reads it back, as if it
+// had been written to a file
+func convertAndRead(t *testing.T, source string, options ...cacao.ConvertOption) *cacao.CacaoPlaybook {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(source))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, _, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, options...)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	violations, err := cacao.ValidateSchema(data, cacao.CACAO_SPEC_VERSION_20)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+	cacaoPlaybook, err = cacao.ReadCacao(data)
+	if err != nil {
+		t.Fatalf("could not read Cacao playbook: %s", err)
+	}
+	return cacaoPlaybook
+}
+
+// stepIdNamed returns the ID of the step with the given name, other than a parallel step
+func stepIdNamed(t *testing.T, cacaoPlaybook *cacao.CacaoPlaybook, name string) string {
+	for stepId, step := range cacaoPlaybook.Workflow {
+		if step.Common().Name == name && step.StepType() != cacao.CACAO_STEP_TYPE_PARALLEL {
+			return stepId
+		}
+	}
+	t.Fatalf("no step named %q", name)
+	return ""
+}
+
+func TestProvenanceExtension(t *testing.T) {
+	cacaoPlaybook := convertWithProvenance(t, implicitSplitTestString, time.Now())
+	if !assert.Len(t, cacaoPlaybook.ExtensionDefinitions, 1) {
+		return
+	}
+	var extensionId string
+	for id, definition := range cacaoPlaybook.ExtensionDefinitions {
+		extensionId = id
+		assert.Equal(t, "BPMN provenance", definition.Name)
+	}
+
+	var playbookProvenance cacao.PlaybookProvenance
+	if assert.Contains(t, cacaoPlaybook.PlaybookExtensions, extensionId) {
+		assert.NoError(t, json.Unmarshal(cacaoPlaybook.PlaybookExtensions[extensionId], &playbookProvenance))
+	}
+	hash := sha256.Sum256([]byte(implicitSplitTestString))
+	assert.Equal(t, "Process_1", playbookProvenance.ProcessID)
+	assert.Equal(t, hex.EncodeToString(hash[:]), playbookProvenance.SourceFileHash)
+	assert.NotEmpty(t, playbookProvenance.ConverterVersion)
+
+	// every step records its element and the hash of the step
+	for stepId, step := range cacaoPlaybook.Workflow {
+		var stepProvenance cacao.StepProvenance
+		if assert.Contains(t, step.Common().StepExtensions, extensionId, stepId) {
+			assert.NoError(t, json.Unmarshal(step.Common().StepExtensions[extensionId], &stepProvenance))
+		}
+		assert.NotEmpty(t, stepProvenance.ElementID)
+		assert.Len(t, stepProvenance.GeneratedHash, 64)
+	}
+	var notify cacao.StepProvenance
+	assert.NoError(t, json.Unmarshal(cacaoPlaybook.Workflow[stepIdNamed(t, cacaoPlaybook, "Notify")].Common().StepExtensions[extensionId], &notify))
+	assert.Equal(t, cacao.StepProvenance{ElementID: "Activity_notify", GeneratedHash: notify.GeneratedHash}, notify)
+
+	// the hashes do not depend on the time of the conversion
+	regenerated := convertWithProvenance(t, implicitSplitTestString, time.Now().Add(time.Hour))
+	assert.True(t, cacao.WorkflowEqual(cacaoPlaybook, regenerated))
+}
+
+func TestMergeEdited(t *testing.T) {
+	created := time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	edited := convertWithProvenance(t, implicitSplitTestString, created)
+
+	// an analyst edits two steps and adds one
+	notifyId := stepIdNamed(t, edited, "Notify")
+	edited.Workflow[notifyId].Common().Description = "Page the on-call analyst"
+	hostsId := stepIdNamed(t, edited, "Isolate hosts")
+	edited.Workflow[hostsId].Common().Description = "Use the EDR console"
+	edited.Workflow["action--7b0a1c6e-3f1d-4d7e-9a2b-5c8e4f6d2a10"] = &cacao.ActionStep{
+		StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_ACTION, Name: "Hand-added", OnCompletion: edited.Workflow[notifyId].Common().OnCompletion},
+	}
+
+	// the BPMN renames a task, and adds one after "Report"
+	revised := strings.Replace(implicitSplitTestString, `name="Isolate hosts"`, `name="Isolate endpoints"`, 1)
+	revised = strings.Replace(revised, `<bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_report" targetRef="Event_1" />`, `<bpmn:sequenceFlow id="Flow_7" sourceRef="Activity_report" targetRef="Activity_close" />
+    <bpmn:userTask id="Activity_close" name="Close ticket" />
+    <bpmn:sequenceFlow id="Flow_8" sourceRef="Activity_close" targetRef="Event_1" />`, 1)
+	generated := convertWithProvenance(t, revised, created.Add(24*time.Hour))
+
+	merged, report, err := cacao.MergeEdited(edited, generated)
+	if err != nil {
+		t.Fatalf("could not merge: %s", err)
+	}
+	reportId := stepIdNamed(t, generated, "Report")
+	closeId := stepIdNamed(t, generated, "Close ticket")
+	assert.ElementsMatch(t, []cacao.StepMerge{
+		{StepID: hostsId, Name: "Isolate hosts", Action: cacao.MERGE_ACTION_UPDATED, Edited: true},
+		{StepID: reportId, Name: "Report", Action: cacao.MERGE_ACTION_UPDATED},
+		{StepID: closeId, Name: "Close ticket", Action: cacao.MERGE_ACTION_ADDED},
+	}, report.Steps)
+
+	// the edits of steps whose BPMN did not change are kept
+	assert.Equal(t, "Page the on-call analyst", merged.Workflow[notifyId].Common().Description)
+	assert.Contains(t, merged.Workflow, "action--7b0a1c6e-3f1d-4d7e-9a2b-5c8e4f6d2a10")
+	// the steps whose BPMN changed are regenerated
+	assert.Equal(t, "Isolate endpoints", merged.Workflow[hostsId].Common().Name)
+	assert.Empty(t, merged.Workflow[hostsId].Common().Description)
+	assert.Equal(t, closeId, merged.Workflow[reportId].Common().OnCompletion)
+	assert.Len(t, merged.Workflow, len(generated.Workflow)+1)
+
+	assert.Equal(t, created, *merged.Created)
+	assert.Equal(t, *generated.Modified, *merged.Modified)
+	assert.NotEqual(t, edited.ID, merged.ID, "the merged playbook is a new version")
+	assert.Contains(t, merged.DerivedFrom, edited.ID)
+
+	// merging again changes nothing
+	again, report, err := cacao.MergeEdited(merged, generated)
+	if err != nil {
+		t.Fatalf("could not merge: %s", err)
+	}
+	assert.Empty(t, report.Steps)
+	assert.True(t, cacao.WorkflowEqual(merged, again))
+	assert.Equal(t, *merged.Modified, *again.Modified)
+}
+
+func TestMergeEditedRemovedStep(t *testing.T) {
+	edited := convertWithProvenance(t, implicitSplitTestString, time.Now())
+	// the BPMN drops "Notify"
+	revised := strings.Replace(implicitSplitTestString, `<bpmn:outgoing>Flow_1</bpmn:outgoing>`, "", 1)
+	revised = strings.Replace(revised, `<bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_notify" />`, "", 1)
+	revised = strings.Replace(revised, `<bpmn:userTask id="Activity_notify" name="Notify">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+    </bpmn:userTask>`, "", 1)
+	generated := convertWithProvenance(t, revised, time.Now())
+
+	merged, report, err := cacao.MergeEdited(edited, generated)
+	if err != nil {
+		t.Fatalf("could not merge: %s", err)
+	}
+	notifyId := stepIdNamed(t, edited, "Notify")
+	assert.NotContains(t, merged.Workflow, notifyId)
+	var removed []string
+	for _, change := range report.Steps {
+		if change.Action == cacao.MERGE_ACTION_REMOVED {
+			removed = append(removed, change.Name)
+		}
+	}
+	// the start step now leads straight to "Triage", and the parallel step
+	// and the end step after "Notify" are gone
+	assert.ElementsMatch(t, []string{"Notify", "Alert", "End"}, removed)
+	assert.Empty(t, cacao.Validate(merged))
+	assert.True(t, cacao.WorkflowEqual(generated, merged))
+}
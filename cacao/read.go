@@ -0,0 +1,117 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// the step types defined by each spec version
+var specStepTypes = map[string]map[string]bool{
+	CACAO_SPEC_VERSION_11: {
+		CACAO_STEP_TYPE_START:       true,
+		CACAO_STEP_TYPE_END:         true,
+		CACAO_STEP_TYPE_11_SINGLE:   true,
+		CACAO_STEP_TYPE_11_PLAYBOOK: true,
+		CACAO_STEP_TYPE_PARALLEL:    true,
+		CACAO_STEP_TYPE_IF_COND:     true,
+		CACAO_STEP_TYPE_WHILE_COND:  true,
+		CACAO_STEP_TYPE_SWITCH_COND: true,
+	},
+	CACAO_SPEC_VERSION_20: {
+		CACAO_STEP_TYPE_START:           true,
+		CACAO_STEP_TYPE_END:             true,
+		CACAO_STEP_TYPE_ACTION:          true,
+		CACAO_STEP_TYPE_PLAYBOOK_ACTION: true,
+		CACAO_STEP_TYPE_PARALLEL:        true,
+		CACAO_STEP_TYPE_IF_COND:         true,
+		CACAO_STEP_TYPE_WHILE_COND:      true,
+		CACAO_STEP_TYPE_SWITCH_COND:     true,
+	},
+}
+
+// ReadCacao parses a CACAO 1.1 or 2.0 playbook. Each step is decoded into
+// the struct for its type, and properties that are not part of the model are
+// kept in the Extra maps so that writing the playbook back out preserves
+// them. An error is returned if the playbook has an unknown spec version,
+// a step type that is not defined by its spec version, or a step that is
+// missing a property required by its type.
+func ReadCacao(data []byte) (*CacaoPlaybook, error) {
+	cacaoPlaybook := new(CacaoPlaybook)
+	if err := json.Unmarshal(data, cacaoPlaybook); err != nil {
+		return nil, err
+	}
+	if cacaoPlaybook.Type != CACAO_TYPE_PLAYBOOK {
+		return nil, fmt.Errorf("expected type %q, found %q", CACAO_TYPE_PLAYBOOK, cacaoPlaybook.Type)
+	}
+	stepTypes, found := specStepTypes[NormalizeSpecVersion(cacaoPlaybook.SpecVersion)]
+	if !found {
+		return nil, fmt.Errorf("unsupported spec_version %q", cacaoPlaybook.SpecVersion)
+	}
+	for _, stepId := range sortedStepIds(cacaoPlaybook.Workflow) {
+		step := cacaoPlaybook.Workflow[stepId]
+		if !stepTypes[step.StepType()] {
+			return nil, fmt.Errorf("step %s: step type %q is not defined by spec_version %q", stepId, step.StepType(), cacaoPlaybook.SpecVersion)
+		}
+		if property := missingStepProperty(step); property != "" {
+			return nil, fmt.Errorf("step %s: %s step is missing %s", stepId, step.StepType(), property)
+		}
+	}
+	return cacaoPlaybook, nil
+}
+
+// missingStepProperty returns the name of a property required by the type
+// of a step that is not set, or an empty string if none is missing
+func missingStepProperty(step Step) string {
+	switch typed := step.(type) {
+	case *ActionStep:
+		if len(typed.Commands) == 0 {
+			return "commands"
+		}
+	case *PlaybookActionStep:
+		if typed.PlaybookID == "" {
+			return "playbook_id"
+		}
+	case *ParallelStep:
+		if len(typed.NextSteps) == 0 {
+			return "next_steps"
+		}
+	case *IfConditionStep:
+		if typed.Condition == "" {
+			return "condition"
+		}
+		if typed.OnTrue == "" {
+			return "on_true"
+		}
+	case *WhileConditionStep:
+		if typed.Condition == "" {
+			return "condition"
+		}
+		if typed.OnTrue == "" {
+			return "on_true"
+		}
+	case *SwitchConditionStep:
+		if typed.Switch == "" {
+			return "switch"
+		}
+		if len(typed.Cases) == 0 {
+			return "cases"
+		}
+	}
+	return ""
+}
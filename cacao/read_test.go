@@ -0,0 +1,190 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+const readCacaoTestString = `{
+  "type": "playbook",
+  "spec_version": "cacao-2.0",
+  "id": "playbook--00000000-0000-0000-0000-000000000000",
+  "name": "Read test",
+  "created_by": "identity--00000000-0000-0000-0000-000000000001",
+  "created": "2023-01-01T00:00:00Z",
+  "modified": "2023-01-01T00:00:00Z",
+  "revoked": false,
+  "priority": 0,
+  "severity": 0,
+  "impact": 0,
+  "x_vendor_playbook": {"score": 3},
+  "playbook_variables": {
+    "__verdict__": {"type": "string", "external": true}
+  },
+  "external_references": [
+    {"name": "Runbook", "url": "https://example.com/runbook", "reference_id": "RB-1"}
+  ],
+  "extension_definitions": {
+    "extension-definition--00000000-0000-0000-0000-000000000005": {
+      "type": "extension-definition",
+      "name": "Vendor extension",
+      "created_by": "identity--00000000-0000-0000-0000-000000000001",
+      "created": "2023-01-01T00:00:00Z",
+      "schema": "https://example.com/schema.json",
+      "version": "1.0"
+    }
+  },
+  "workflow_start": "start--1",
+  "workflow": {
+    "start--1": {"type": "start", "on_completion": "if-condition--2"},
+    "if-condition--2": {
+      "type": "if-condition",
+      "condition": "__verdict__ == 'bad'",
+      "on_true": "action--3",
+      "on_false": "end--4",
+      "x_vendor_step": "kept"
+    },
+    "action--3": {
+      "type": "action",
+      "on_completion": "end--4",
+      "commands": [{"type": "manual", "command": "Block it", "description": "", "x_vendor_command": true}]
+    },
+    "end--4": {"type": "end"}
+  }
+}`
+
+func TestReadCacao(t *testing.T) {
+	cacaoPlaybook, err := cacao.ReadCacao([]byte(readCacaoTestString))
+	if err != nil {
+		t.Fatalf("could not read Cacao playbook: %s", err)
+	}
+	assert.Equal(t, "Read test", cacaoPlaybook.Name)
+	assert.IsType(t, &cacao.StartStep{}, cacaoPlaybook.Workflow["start--1"])
+	assert.IsType(t, &cacao.EndStep{}, cacaoPlaybook.Workflow["end--4"])
+	ifStep, ok := cacaoPlaybook.Workflow["if-condition--2"].(*cacao.IfConditionStep)
+	if !ok {
+		t.Fatalf("expected an if-condition step, found %T", cacaoPlaybook.Workflow["if-condition--2"])
+	}
+	assert.Equal(t, "__verdict__ == 'bad'", ifStep.Condition)
+	assert.Equal(t, "action--3", ifStep.OnTrue)
+	actionStep, ok := cacaoPlaybook.Workflow["action--3"].(*cacao.ActionStep)
+	if !ok {
+		t.Fatalf("expected an action step, found %T", cacaoPlaybook.Workflow["action--3"])
+	}
+	assert.Equal(t, "Block it", actionStep.Commands[0].Command)
+	assert.Equal(t, "string", cacaoPlaybook.PlaybookVariables["__verdict__"].Type)
+	assert.Equal(t, "Runbook", cacaoPlaybook.ExternalReferences[0].Name)
+	assert.Equal(t, "1.0", cacaoPlaybook.ExtensionDefinitions["extension-definition--00000000-0000-0000-0000-000000000005"].Version)
+
+	// unknown properties survive a round trip at every level
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	var expected, actual interface{}
+	assert.NoError(t, json.Unmarshal([]byte(readCacaoTestString), &expected))
+	assert.NoError(t, json.Unmarshal(data, &actual))
+	assert.Equal(t, expected, actual)
+}
+
+func TestReadCacaoMinimalPlaybook(t *testing.T) {
+	// optional properties that are absent stay absent, so that signatures
+	// made over the playbook as read still hold after writing it
+	minimal := `{"type":"playbook","spec_version":"cacao-2.0","id":"playbook--00000000-0000-0000-0000-000000000000","name":"Minimal",` +
+		`"workflow_start":"start--1","workflow":{` +
+		`"action--2":{"type":"action","on_completion":"end--3","commands":[{"type":"manual","command":"Block it"}]},` +
+		`"end--3":{"type":"end"},` +
+		`"start--1":{"type":"start","on_completion":"action--2"}}}`
+	cacaoPlaybook, err := cacao.ReadCacao([]byte(minimal))
+	if err != nil {
+		t.Fatalf("could not read Cacao playbook: %s", err)
+	}
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	assert.Equal(t, minimal, string(data))
+
+	// as do empty values given explicitly
+	explicit := strings.Replace(minimal, `"name":"Minimal",`, `"name":"Minimal","created":null,"revoked":false,"priority":0,`, 1)
+	explicit = strings.Replace(explicit, `"command":"Block it"`, `"command":"Block it","description":""`, 1)
+	cacaoPlaybook, err = cacao.ReadCacao([]byte(explicit))
+	if err != nil {
+		t.Fatalf("could not read Cacao playbook: %s", err)
+	}
+	data, err = json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	assert.JSONEq(t, explicit, string(data))
+}
+
+func TestReadCacaoRoundTripsConvertedPlaybook(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion)
+		if err != nil {
+			t.Fatalf("could not convert BPMN to Cacao: %s", err)
+		}
+		data, err := json.Marshal(cacaoPlaybook)
+		if err != nil {
+			t.Fatalf("could not marshal Cacao playbook: %s", err)
+		}
+		read, err := cacao.ReadCacao(data)
+		if err != nil {
+			t.Fatalf("could not read CACAO %s playbook: %s", specVersion, err)
+		}
+		roundTripped, err := json.Marshal(read)
+		if err != nil {
+			t.Fatalf("could not marshal Cacao playbook: %s", err)
+		}
+		assert.JSONEq(t, string(data), string(roundTripped))
+	}
+}
+
+func TestReadCacaoErrors(t *testing.T) {
+	testCases := map[string]struct {
+		replace  string
+		with     string
+		expected string
+	}{
+		"unknown spec version": {`"cacao-2.0"`, `"cacao-3.0"`, `unsupported spec_version "cacao-3.0"`},
+		"unknown step type":    {`"type": "end"`, `"type": "finish"`, `unknown step type "finish"`},
+		"step type of 1.1":     {`"type": "action"`, `"type": "single"`, `step type "single" is not defined by spec_version "cacao-2.0"`},
+		"missing property":     {`"condition": "__verdict__ == 'bad'",`, ``, `if-condition step is missing condition`},
+		"not a playbook":       {`"type": "playbook"`, `"type": "bundle"`, `expected type "playbook"`},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			data := strings.Replace(readCacaoTestString, testCase.replace, testCase.with, 1)
+			_, err := cacao.ReadCacao([]byte(data))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/glog"
+)
+
+// Diagnostic severities
+const DIAGNOSTIC_SEVERITY_ERROR string = "error"
+const DIAGNOSTIC_SEVERITY_WARNING string = "warning"
+
+// Diagnostic codes
+const DIAGNOSTIC_CODE_INVALID_MARKING string = "invalid-marking"
+const DIAGNOSTIC_CODE_COMMAND_FALLBACK string = "command-fallback"
+const DIAGNOSTIC_CODE_IGNORED_PARAMETER string = "ignored-parameter"
+const DIAGNOSTIC_CODE_GUESSED_BRANCH string = "guessed-branch"
+const DIAGNOSTIC_CODE_DUPLICATE_CASE string = "duplicate-case"
+const DIAGNOSTIC_CODE_FLOW_COUNT string = "unexpected-flow-count"
+const DIAGNOSTIC_CODE_RENAMED_VARIABLE string = "renamed-variable"
+const DIAGNOSTIC_CODE_INVALID_TEMPLATE string = "invalid-template"
+const DIAGNOSTIC_CODE_INVALID_COMMAND string = "invalid-command"
+const DIAGNOSTIC_CODE_UNSUPPORTED_FEATURE string = "unsupported-feature"
+const DIAGNOSTIC_CODE_UNTRANSLATED_CONDITION string = "untranslated-condition"
+const DIAGNOSTIC_CODE_UNJOINED_BRANCHES string = "unjoined-branches"
+
+// Diagnostic is a problem found while converting a BPMN element, such as
+// something that could not be converted or a guess that should be checked
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	// ElementID is the ID of the BPMN element the problem was found in
+	ElementID string `json:"element_id,omitempty"`
+	// StepID is the ID of the step generated for the element, if any
+	StepID  string `json:"step_id,omitempty"`
+	Message string `json:"message"`
+	// Fix suggests how to solve the problem in the BPMN or the options
+	Fix string `json:"fix,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	message := d.Message
+	if d.ElementID != "" {
+		message = fmt.Sprintf("%s: %s", d.ElementID, message)
+	}
+	if d.Fix != "" {
+		message = fmt.Sprintf("%s; %s", message, d.Fix)
+	}
+	return fmt.Sprintf("%s: %s (%s)", d.Severity, message, d.Code)
+}
+
+// Report holds the diagnostics of a conversion, in the order they were
+// found, and the inventory of the BPMN elements converted
+type Report struct {
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Inventory   []InventoryEntry `json:"inventory,omitempty"`
+	// Coverage is the percentage of the elements in the inventory that
+	// were converted
+	Coverage float64 `json:"coverage"`
+	// CrossReferences maps each step of the playbook to the BPMN element it
+	// was created for, see CrossReferenceFile
+	CrossReferences []CrossReference `json:"-"`
+	// synthesized lists the steps created without a BPMN counterpart
+	synthesized []CrossReference
+}
+
+// setInventory sets the inventory and the coverage it gives
+func (r *Report) setInventory(entries []InventoryEntry) {
+	r.Inventory = entries
+	converted, total := r.Converted()
+	r.Coverage = CoveragePercent(converted, total)
+}
+
+// Converted returns the number of elements in the inventory that were
+// converted, and the number of elements
+func (r *Report) Converted() (converted, total int) {
+	for _, entry := range r.Inventory {
+		if entry.Status == INVENTORY_STATUS_CONVERTED {
+			converted++
+		}
+	}
+	return converted, len(r.Inventory)
+}
+
+// CoveragePercent returns the percentage of elements converted, rounded to
+// one decimal place, eg. to combine the coverage of several conversions.
+// Nothing to convert is full coverage.
+func CoveragePercent(converted, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(converted)*1000/float64(total)) / 10
+}
+
+// add records a diagnostic. A nil report logs it instead, for conversions
+// run by callers that do not collect a report.
+func (r *Report) add(diagnostic Diagnostic) {
+	if r == nil {
+		logDiagnostic(diagnostic)
+		return
+	}
+	r.Diagnostics = append(r.Diagnostics, diagnostic)
+}
+
+// warnf records a warning about an element, fix may be empty
+func (r *Report) warnf(code, elementId, stepId, fix, format string, args ...interface{}) {
+	r.add(Diagnostic{
+		Severity:  DIAGNOSTIC_SEVERITY_WARNING,
+		Code:      code,
+		ElementID: elementId,
+		StepID:    stepId,
+		Message:   fmt.Sprintf(format, args...),
+		Fix:       fix,
+	})
+}
+
+// errorf records an error about an element, fix may be empty
+func (r *Report) errorf(code, elementId, stepId, fix, format string, args ...interface{}) {
+	r.add(Diagnostic{
+		Severity:  DIAGNOSTIC_SEVERITY_ERROR,
+		Code:      code,
+		ElementID: elementId,
+		StepID:    stepId,
+		Message:   fmt.Sprintf(format, args...),
+		Fix:       fix,
+	})
+}
+
+// Warnings returns the diagnostics with warning severity
+func (r *Report) Warnings() []Diagnostic {
+	return r.withSeverity(DIAGNOSTIC_SEVERITY_WARNING)
+}
+
+// Errors returns the diagnostics with error severity
+func (r *Report) Errors() []Diagnostic {
+	return r.withSeverity(DIAGNOSTIC_SEVERITY_ERROR)
+}
+
+func (r *Report) withSeverity(severity string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, diagnostic := range r.Diagnostics {
+		if diagnostic.Severity == severity {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics
+}
+
+// Log writes the diagnostics to the log
+func (r *Report) Log() {
+	for _, diagnostic := range r.Diagnostics {
+		logDiagnostic(diagnostic)
+	}
+}
+
+func logDiagnostic(diagnostic Diagnostic) {
+	if diagnostic.Severity == DIAGNOSTIC_SEVERITY_ERROR {
+		glog.Error(diagnostic)
+	} else {
+		glog.Warning(diagnostic)
+	}
+}
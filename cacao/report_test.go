@@ -0,0 +1,100 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// groovyScriptProcess returns a gateway process whose first task is a script
+// task in a language that cannot be converted
+func groovyScriptProcess() string {
+	process := gatewayProcess([]string{"Yes", "No"}, -1)
+	process = strings.Replace(process, `<bpmn:userTask id="Activity_0" name="Handle Yes">`, `<bpmn:scriptTask id="Activity_0" name="Handle Yes" scriptFormat="groovy">`, 1)
+	return strings.Replace(process, `</bpmn:userTask>`, `<bpmn:script>println "yes"</bpmn:script></bpmn:scriptTask>`, 1)
+}
+
+func TestConvertReport(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bpmn     string
+		severity string
+		code     string
+		element  string
+	}{
+		{"guessed branch", gatewayProcess([]string{"Yes", "Perhaps"}, -1), cacao.DIAGNOSTIC_SEVERITY_WARNING, cacao.DIAGNOSTIC_CODE_GUESSED_BRANCH, "Gateway_1"},
+		{"guessed from words", gatewayProcess([]string{"Yes", "Clean up host"}, -1), cacao.DIAGNOSTIC_SEVERITY_WARNING, cacao.DIAGNOSTIC_CODE_GUESSED_BRANCH, "Gateway_1"},
+		{"guessed from words of one flow", gatewayProcess([]string{"Found nothing", ""}, -1), cacao.DIAGNOSTIC_SEVERITY_WARNING, cacao.DIAGNOSTIC_CODE_GUESSED_BRANCH, "Gateway_1"},
+		{"duplicate case", gatewayProcess([]string{"URL", "url", "IP"}, -1), cacao.DIAGNOSTIC_SEVERITY_WARNING, cacao.DIAGNOSTIC_CODE_DUPLICATE_CASE, "Gateway_1"},
+		{"unsupported script", groovyScriptProcess(), cacao.DIAGNOSTIC_SEVERITY_ERROR, cacao.DIAGNOSTIC_CODE_COMMAND_FALLBACK, "Activity_0"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			bpmnDefinitions, err := bpmn.ReadBpmn([]byte(testCase.bpmn))
+			if err != nil {
+				t.Fatalf("could not read input: %s", err)
+			}
+			cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			if !assert.Len(t, report.Diagnostics, 1) {
+				return
+			}
+			diagnostic := report.Diagnostics[0]
+			assert.Equal(t, testCase.severity, diagnostic.Severity)
+			assert.Equal(t, testCase.code, diagnostic.Code)
+			assert.Equal(t, testCase.element, diagnostic.ElementID)
+			assert.Contains(t, cacaoPlaybook.Workflow, diagnostic.StepID)
+			assert.NotEmpty(t, diagnostic.Message)
+			assert.NotEmpty(t, diagnostic.Fix)
+		})
+	}
+}
+
+func TestReportJson(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(gatewayProcess([]string{"Yes", "No"}, 1)))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	_, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	assert.Empty(t, report.Warnings())
+	assert.Empty(t, report.Errors())
+	assert.Equal(t, 100.0, report.Coverage)
+
+	report = &cacao.Report{}
+	report.Diagnostics = append(report.Diagnostics, cacao.Diagnostic{
+		Severity:  cacao.DIAGNOSTIC_SEVERITY_WARNING,
+		Code:      cacao.DIAGNOSTIC_CODE_GUESSED_BRANCH,
+		ElementID: "Gateway_1",
+		Message:   "guessed",
+	})
+	assert.Len(t, report.Warnings(), 1)
+	assert.Equal(t, "warning: Gateway_1: guessed (guessed-branch)", report.Diagnostics[0].String())
+	data, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"diagnostics": [{"severity": "warning", "code": "guessed-branch", "element_id": "Gateway_1", "message": "guessed"}], "coverage": 0}`, string(data))
+}
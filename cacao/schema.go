@@ -0,0 +1,509 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the CACAO JSON schemas, condensed by hand from the OASIS schemas into one
+// self-contained file per spec version, see schemas/README.md. The whole
+// directory is embedded so that schemas split into several files can be
+// added.
+//
+//go:embed schemas
+var schemaFiles embed.FS
+
+var schemaFileNames = map[string]string{
+	CACAO_SPEC_VERSION_11: "schemas/cacao-1.1.json",
+	CACAO_SPEC_VERSION_20: "schemas/cacao-2.0.json",
+}
+
+// SchemaViolation is a location in a document that does not conform to the schema
+type SchemaViolation struct {
+	// Path is a JSON pointer (RFC 6901) to the offending value
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v SchemaViolation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, v.Message)
+}
+
+// Schema returns the embedded JSON schema for a CACAO spec version
+func Schema(specVersion string) ([]byte, error) {
+	fileName, found := schemaFileNames[specVersion]
+	if !found {
+		return nil, fmt.Errorf("unsupported CACAO spec version: %s", specVersion)
+	}
+	return schemaFiles.ReadFile(fileName)
+}
+
+// ValidateSchema validates a JSON document against the CACAO schema for the
+// spec version. If specVersion is empty, the spec_version of the document is
+// used. A nil slice is returned if the document conforms to the schema.
+func ValidateSchema(data []byte, specVersion string) ([]SchemaViolation, error) {
+	document, err := decodeJson(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse JSON: %s", err)
+	}
+	if specVersion == "" {
+		if object, ok := document.(map[string]interface{}); ok {
+			if documentVersion, ok := object["spec_version"].(string); ok {
+				specVersion = NormalizeSpecVersion(documentVersion)
+			}
+		}
+	}
+	fileName, found := schemaFileNames[specVersion]
+	if !found {
+		return nil, fmt.Errorf("unsupported CACAO spec version: %s", specVersion)
+	}
+	return validateSchemaFiles(document, schemaFiles, fileName)
+}
+
+// ValidateSchemaFiles validates a JSON document against the JSON schema in
+// the file fileName of schemas, such as a checkout of the OASIS CACAO JSON
+// schemas. References to other files are resolved relative to the file
+// they are in, or by the $id of the file if they are absolute. A nil slice
+// is returned if the document conforms to the schema.
+func ValidateSchemaFiles(data []byte, schemas fs.FS, fileName string) ([]SchemaViolation, error) {
+	document, err := decodeJson(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse JSON: %s", err)
+	}
+	return validateSchemaFiles(document, schemas, fileName)
+}
+
+// validateSchemaFiles validates a decoded JSON document against the schema
+// in a file of schemas
+func validateSchemaFiles(document interface{}, schemas fs.FS, fileName string) ([]SchemaViolation, error) {
+	validator := &schemaValidator{
+		files:     schemas,
+		base:      fileName,
+		documents: make(map[string]interface{}),
+		patterns:  make(map[string]*regexp.Regexp),
+	}
+	schema, err := validator.document(fileName)
+	if err != nil {
+		return nil, err
+	}
+	validator.validate(schema, document, "")
+	return validator.violations, nil
+}
+
+// decodeJson decodes JSON, keeping numbers as json.Number
+func decodeJson(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// schemaValidator implements the subset of JSON Schema 2020-12 used by the
+// embedded schemas. References are resolved to JSON pointers ("#/...") into
+// the schema files, anchors are not supported.
+type schemaValidator struct {
+	files fs.FS
+	// base is the file of the schema being validated against, which
+	// relative references are resolved against
+	base string
+	// documents holds the schema files read so far by their file names
+	documents map[string]interface{}
+	// ids maps the $id of each schema file to its file name, see fileById
+	ids        map[string]string
+	patterns   map[string]*regexp.Regexp
+	violations []SchemaViolation
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether value conforms to schema without recording violations
+func (v *schemaValidator) matches(schema, value interface{}, path string) bool {
+	violations := v.violations
+	v.violations = nil
+	v.validate(schema, value, path)
+	matched := len(v.violations) == 0
+	v.violations = violations
+	return matched
+}
+
+func (v *schemaValidator) pattern(expr string) *regexp.Regexp {
+	re, found := v.patterns[expr]
+	if !found {
+		re = regexp.MustCompile(expr)
+		v.patterns[expr] = re
+	}
+	return re
+}
+
+// document returns a schema file, decoded
+func (v *schemaValidator) document(fileName string) (interface{}, error) {
+	if document, found := v.documents[fileName]; found {
+		return document, nil
+	}
+	data, err := fs.ReadFile(v.files, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read schema %s: %s", fileName, err)
+	}
+	document, err := decodeJson(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse schema %s: %s", fileName, err)
+	}
+	v.documents[fileName] = document
+	return document, nil
+}
+
+// fileById returns the name of the schema file with the given $id, or an
+// empty string if there is none
+func (v *schemaValidator) fileById(id string) string {
+	if v.ids == nil {
+		v.ids = make(map[string]string)
+		fs.WalkDir(v.files, ".", func(fileName string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || path.Ext(fileName) != ".json" {
+				return nil
+			}
+			if document, err := v.document(fileName); err == nil {
+				if object, ok := document.(map[string]interface{}); ok {
+					if fileId, ok := object["$id"].(string); ok {
+						v.ids[fileId] = fileName
+					}
+				}
+			}
+			return nil
+		})
+	}
+	return v.ids[id]
+}
+
+// resolve looks up a reference such as "#/$defs/identifier",
+// "../common/identifier.json" or "https://example.com/step.json#/$defs/id"
+// and returns the schema and the file it is in
+func (v *schemaValidator) resolve(ref string) (interface{}, string, error) {
+	fileRef, pointer := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		fileRef, pointer = ref[:i], ref[i+1:]
+	}
+	fileName := v.base
+	switch {
+	case fileRef == "":
+	case strings.Contains(fileRef, "://"):
+		if fileName = v.fileById(fileRef); fileName == "" {
+			return nil, "", fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+	default:
+		fileName = path.Join(path.Dir(v.base), fileRef)
+	}
+	if pointer != "" && !strings.HasPrefix(pointer, "/") {
+		return nil, "", fmt.Errorf("unsupported schema reference %s", ref)
+	}
+	node, err := v.document(fileName)
+	if err != nil {
+		return nil, "", fmt.Errorf("unresolvable schema reference %s: %s", ref, err)
+	}
+	if pointer == "" {
+		return node, fileName, nil
+	}
+	for _, token := range strings.Split(pointer, "/")[1:] {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		if node, ok = object[token]; !ok {
+			return nil, "", fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+	}
+	return node, fileName, nil
+}
+
+func (v *schemaValidator) validate(schemaNode, value interface{}, path string) {
+	schema, ok := schemaNode.(map[string]interface{})
+	if !ok {
+		if allowed, ok := schemaNode.(bool); ok && !allowed {
+			v.fail(path, "value is not allowed")
+		}
+		return
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		target, fileName, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%s", err)
+		} else {
+			// references in the target are relative to its file
+			base := v.base
+			v.base = fileName
+			v.validate(target, value, path)
+			v.base = base
+		}
+	}
+	if expected, ok := schema["type"]; ok && !hasType(value, expected) {
+		v.fail(path, "expected %s, found %s", describeType(expected), jsonType(value))
+		return
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		v.fail(path, "expected %s", jsonString(constant))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "%s is not one of %s", jsonString(value), jsonString(enum))
+		}
+	}
+	switch typed := value.(type) {
+	case string:
+		v.validateString(schema, typed, path)
+	case json.Number:
+		v.validateNumber(schema, typed, path)
+	case []interface{}:
+		v.validateArray(schema, typed, path)
+	case map[string]interface{}:
+		v.validateObject(schema, typed, path)
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if v.matches(sub, value, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "value does not match any of the permitted forms%s", requiredHint(anyOf))
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if v.matches(sub, value, path) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.fail(path, "value matches %d of the permitted forms, expected exactly 1", matched)
+		}
+	}
+	if not, ok := schema["not"]; ok && v.matches(not, value, path) {
+		v.fail(path, "value matches a disallowed form%s", requiredHint([]interface{}{not}))
+	}
+	if condition, ok := schema["if"]; ok {
+		if v.matches(condition, value, path) {
+			if then, ok := schema["then"]; ok {
+				v.validate(then, value, path)
+			}
+		} else if otherwise, ok := schema["else"]; ok {
+			v.validate(otherwise, value, path)
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, value, path string) {
+	if minLength, ok := schema["minLength"].(json.Number); ok {
+		if limit, _ := minLength.Int64(); int64(len([]rune(value))) < limit {
+			v.fail(path, "string is shorter than %d characters", limit)
+		}
+	}
+	if expr, ok := schema["pattern"].(string); ok && !v.pattern(expr).MatchString(value) {
+		v.fail(path, "%q does not match pattern %s", value, expr)
+	}
+	if format, ok := schema["format"].(string); ok && format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			v.fail(path, "%q is not an RFC 3339 timestamp", value)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, value json.Number, path string) {
+	number, err := value.Float64()
+	if err != nil {
+		v.fail(path, "%s is not a number", value)
+		return
+	}
+	if minimum, ok := schema["minimum"].(json.Number); ok {
+		if limit, _ := minimum.Float64(); number < limit {
+			v.fail(path, "%s is less than the minimum of %s", value, minimum)
+		}
+	}
+	if maximum, ok := schema["maximum"].(json.Number); ok {
+		if limit, _ := maximum.Float64(); number > limit {
+			v.fail(path, "%s is greater than the maximum of %s", value, maximum)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	if minItems, ok := schema["minItems"].(json.Number); ok {
+		if limit, _ := minItems.Int64(); int64(len(value)) < limit {
+			v.fail(path, "array has fewer than %d items", limit)
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s/%d", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, found := value[name.(string)]; !found {
+				v.fail(path, "missing required property %q", name)
+			}
+		}
+	}
+	if minProperties, ok := schema["minProperties"].(json.Number); ok {
+		if limit, _ := minProperties.Int64(); int64(len(value)) < limit {
+			v.fail(path, "object has fewer than %d properties", limit)
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, hasAdditional := schema["additionalProperties"]
+	propertyNames, hasPropertyNames := schema["propertyNames"]
+	// visit properties in a stable order so that violations are reproducible
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := path + "/" + escapePointer(name)
+		if hasPropertyNames && !v.matches(propertyNames, name, childPath) {
+			v.fail(childPath, "property name %q is not valid", name)
+		}
+		if propertySchema, found := properties[name]; found {
+			v.validate(propertySchema, value[name], childPath)
+		} else if hasAdditional {
+			v.validate(additional, value[name], childPath)
+		}
+	}
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// requiredHint describes the required properties of alternative schemas
+func requiredHint(alternatives []interface{}) string {
+	var hints []string
+	for _, alternative := range alternatives {
+		if object, ok := alternative.(map[string]interface{}); ok {
+			if required, ok := object["required"].([]interface{}); ok {
+				hints = append(hints, jsonString(required))
+			}
+		}
+	}
+	if len(hints) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (properties %s)", strings.Join(hints, " or "))
+}
+
+func jsonType(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := typed.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func hasType(value interface{}, expected interface{}) bool {
+	types, ok := expected.([]interface{})
+	if !ok {
+		types = []interface{}{expected}
+	}
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func describeType(expected interface{}) string {
+	if types, ok := expected.([]interface{}); ok {
+		names := make([]string, 0, len(types))
+		for _, t := range types {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(expected)
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func jsonEqual(a, b interface{}) bool {
+	if numberA, ok := a.(json.Number); ok {
+		if numberB, ok := b.(json.Number); ok {
+			floatA, _ := numberA.Float64()
+			floatB, _ := numberB.Float64()
+			return floatA == floatB
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSchemaConvertedPlaybooks(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion)
+		if err != nil {
+			t.Fatalf("could not convert BPMN to Cacao: %s", err)
+		}
+		data, err := json.Marshal(cacaoPlaybook)
+		if err != nil {
+			t.Fatalf("could not marshal Cacao playbook: %s", err)
+		}
+		violations, err := cacao.ValidateSchema(data, "")
+		assert.NoError(t, err)
+		assert.Empty(t, violations, "CACAO %s", specVersion)
+	}
+}
+
+func TestValidateSchemaViolations(t *testing.T) {
+	document := `{
+		"type": "playbook",
+		"spec_version": "cacao-2.0",
+		"id": "playbook--not-a-uuid",
+		"name": "Test",
+		"created": "yesterday",
+		"modified": "2023-01-01T00:00:00Z",
+		"priority": 101,
+		"workflow_start": "start--aa7caf3a-d55a-4e9a-b34e-056215fba56a",
+		"workflow": {
+			"start--aa7caf3a-d55a-4e9a-b34e-056215fba56a": {"type": "start", "on_completion": "action--aa7caf3a-d55a-4e9a-b34e-056215fba56b"},
+			"action--aa7caf3a-d55a-4e9a-b34e-056215fba56b": {"type": "action", "commands": [{"type": "powershell", "command": "Get-Process"}]}
+		}
+	}`
+	violations, err := cacao.ValidateSchema([]byte(document), "")
+	assert.NoError(t, err)
+	paths := make(map[string]bool)
+	for _, violation := range violations {
+		paths[violation.Path] = true
+	}
+	assert.True(t, paths[""], "missing created_by")
+	assert.True(t, paths["/id"])
+	assert.True(t, paths["/created"])
+	assert.True(t, paths["/priority"])
+	assert.True(t, paths["/workflow/action--aa7caf3a-d55a-4e9a-b34e-056215fba56b/commands/0"])
+	assert.False(t, paths["/modified"])
+
+	_, err = cacao.ValidateSchema([]byte(document), "3.0")
+	assert.Error(t, err)
+	_, err = cacao.ValidateSchema([]byte("{"), "")
+	assert.Error(t, err)
+}
+
+func TestValidateSchemaFiles(t *testing.T) {
+	// schemas split into files that refer to each other relatively and by $id
+	schemas := fstest.MapFS{
+		"schemas/playbook.json": {Data: []byte(`{
+			"$id": "https://example.org/cacao/playbook.json",
+			"type": "object",
+			"required": ["id"],
+			"properties": {
+				"id": {"$ref": "data-types/identifier.json"},
+				"workflow": {"type": "object", "additionalProperties": {"$ref": "https://example.org/cacao/steps/step.json#/$defs/step"}},
+				"signatures": {"$ref": "data-types/missing.json"}
+			}
+		}`)},
+		"schemas/data-types/identifier.json": {Data: []byte(`{
+			"$id": "https://example.org/cacao/data-types/identifier.json",
+			"type": "string",
+			"allOf": [{"$ref": "#/$defs/uuid"}],
+			"$defs": {"uuid": {"pattern": "--[0-9a-f-]{36}$"}}
+		}`)},
+		"schemas/steps/step.json": {Data: []byte(`{
+			"$id": "https://example.org/cacao/steps/step.json",
+			"$defs": {"step": {"type": "object", "properties": {"on_completion": {"$ref": "../data-types/identifier.json"}}}}
+		}`)},
+	}
+	violations, err := cacao.ValidateSchemaFiles([]byte(`{
+		"id": "playbook--aa7caf3a-d55a-4e9a-b34e-056215fba56a",
+		"workflow": {"start--aa7caf3a-d55a-4e9a-b34e-056215fba56b": {"on_completion": "end--aa7caf3a-d55a-4e9a-b34e-056215fba56c"}}
+	}`), schemas, "schemas/playbook.json")
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = cacao.ValidateSchemaFiles([]byte(`{
+		"id": "playbook--1",
+		"workflow": {"start--aa7caf3a-d55a-4e9a-b34e-056215fba56b": {"on_completion": 1}},
+		"signatures": []
+	}`), schemas, "schemas/playbook.json")
+	assert.NoError(t, err)
+	paths := make(map[string]string)
+	for _, violation := range violations {
+		paths[violation.Path] = violation.Message
+	}
+	assert.Contains(t, paths["/id"], "does not match pattern")
+	assert.Contains(t, paths["/workflow/start--aa7caf3a-d55a-4e9a-b34e-056215fba56b/on_completion"], "expected string")
+	assert.Contains(t, paths["/signatures"], "unresolvable schema reference data-types/missing.json")
+
+	_, err = cacao.ValidateSchemaFiles([]byte(`{}`), schemas, "schemas/missing.json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// scriptFormats maps the script formats of BPMN script tasks, in lower
+// case, to the command type that runs them
+var scriptFormats = map[string]string{
+	"bash":                     CACAO_COMMAND_TYPE_BASH,
+	"sh":                       CACAO_COMMAND_TYPE_BASH,
+	"shell":                    CACAO_COMMAND_TYPE_BASH,
+	"text/x-sh":                CACAO_COMMAND_TYPE_BASH,
+	"application/x-sh":         CACAO_COMMAND_TYPE_BASH,
+	"powershell":               CACAO_COMMAND_TYPE_POWERSHELL,
+	"pwsh":                     CACAO_COMMAND_TYPE_POWERSHELL,
+	"ps1":                      CACAO_COMMAND_TYPE_POWERSHELL,
+	"jupyter":                  CACAO_COMMAND_TYPE_JUPYTER,
+	"ipynb":                    CACAO_COMMAND_TYPE_JUPYTER,
+	"application/x-ipynb+json": CACAO_COMMAND_TYPE_JUPYTER,
+	"kestrel":                  CACAO_COMMAND_TYPE_KESTREL,
+}
+
+// hasScript tells whether a task carries a script, inline or as a resource
+func hasScript(task bpmn.BpmnTask) bool {
+	return strings.TrimSpace(task.Script) != "" || task.CamundaResource != ""
+}
+
+// newScriptCommand creates the command running the script of a script task
+// in the command type selected by its scriptFormat. Scripts in other
+// formats, without a format or kept in external resources cannot be
+// converted and are reported as errors.
+func newScriptCommand(specVersion string, task bpmn.BpmnTask) (Command, error) {
+	if task.CamundaResource != "" {
+		return Command{}, fmt.Errorf("the script is in the external resource %s", task.CamundaResource)
+	}
+	format := strings.ToLower(strings.TrimSpace(task.ScriptFormat))
+	if format == "" {
+		return Command{}, fmt.Errorf("the script has no scriptFormat")
+	}
+	commandType, found := scriptFormats[format]
+	if !found {
+		return Command{}, fmt.Errorf("unsupported script format %q", task.ScriptFormat)
+	}
+	script := strings.TrimSpace(task.Script)
+	switch commandType {
+	case CACAO_COMMAND_TYPE_BASH:
+		return NewBashCommand(specVersion, script, task.Documentation)
+	case CACAO_COMMAND_TYPE_POWERSHELL:
+		return NewPowershellCommand(specVersion, script, task.Documentation)
+	case CACAO_COMMAND_TYPE_JUPYTER:
+		return NewJupyterCommand(specVersion, []byte(script), task.Documentation)
+	default:
+		return NewKestrelCommand(specVersion, script, task.Documentation)
+	}
+}
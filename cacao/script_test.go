@@ -0,0 +1,68 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptTaskCommand(t *testing.T) {
+	script := "echo one\necho two"
+	testCases := []struct {
+		specVersion  string
+		scriptFormat string
+		commandType  string
+		encoded      bool
+	}{
+		{cacao.CACAO_SPEC_VERSION_11, "bash", cacao.CACAO_COMMAND_TYPE_BASH, false},
+		{cacao.CACAO_SPEC_VERSION_20, "Shell", cacao.CACAO_COMMAND_TYPE_BASH, true},
+		{cacao.CACAO_SPEC_VERSION_20, "pwsh", cacao.CACAO_COMMAND_TYPE_POWERSHELL, true},
+		{cacao.CACAO_SPEC_VERSION_20, "jupyter", cacao.CACAO_COMMAND_TYPE_JUPYTER, true},
+		{cacao.CACAO_SPEC_VERSION_11, "kestrel", cacao.CACAO_COMMAND_TYPE_KESTREL, true},
+		{cacao.CACAO_SPEC_VERSION_20, "kestrel", cacao.CACAO_COMMAND_TYPE_KESTREL, true},
+	}
+	for _, testCase := range testCases {
+		task := bpmn.BpmnTask{Id: "Activity_1", Name: "Run script", ScriptFormat: testCase.scriptFormat, Script: "\n  " + script + "\n  "}
+		// the script replaces the task name
+		command, err := cacao.NewTaskCommand(testCase.specVersion, task)
+		if !assert.NoError(t, err, testCase.scriptFormat) {
+			continue
+		}
+		assert.Equal(t, testCase.commandType, command.Type)
+		if testCase.encoded {
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(script)), command.CommandB64)
+			assert.Empty(t, command.Command)
+		} else {
+			assert.Equal(t, script, command.Command)
+		}
+	}
+
+	for name, task := range map[string]bpmn.BpmnTask{
+		"unsupported format":       {ScriptFormat: "groovy", Script: "println 'hi'"},
+		"no format":                {Script: script},
+		"external resource":        {ScriptFormat: "bash", CamundaResource: "deployment://block.sh"},
+		"powershell is not in 1.1": {ScriptFormat: "powershell", Script: "Get-Process"},
+	} {
+		_, err := cacao.NewTaskCommand(cacao.CACAO_SPEC_VERSION_11, task)
+		assert.Error(t, err, name)
+	}
+}
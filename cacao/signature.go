@@ -0,0 +1,662 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// CACAO signature types
+const CACAO_SIGNATURE_TYPE_JSS string = "jss"
+
+// JWS algorithms used to sign playbooks
+const SIGNATURE_ALGORITHM_EDDSA string = "EdDSA"
+const SIGNATURE_ALGORITHM_ES256 string = "ES256"
+const SIGNATURE_ALGORITHM_ES384 string = "ES384"
+const SIGNATURE_ALGORITHM_ES512 string = "ES512"
+const SIGNATURE_ALGORITHM_RS256 string = "RS256"
+
+// Signature represents a signature of a CACAO 2.0 playbook. The value is a
+// JWS compact serialisation with a detached payload, the payload being the
+// RFC 8785 canonical form of the playbook without its signatures property.
+type Signature struct {
+	Type            string     `json:"type"`
+	ID              string     `json:"id"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	Created         *time.Time `json:"created"`
+	Modified        *time.Time `json:"modified"`
+	Revoked         bool       `json:"revoked,omitempty"`
+	Signee          string     `json:"signee,omitempty"`
+	ValidFrom       *time.Time `json:"valid_from,omitempty"`
+	ValidUntil      *time.Time `json:"valid_until,omitempty"`
+	RelatedTo       string     `json:"related_to"`
+	RelatedVersion  *time.Time `json:"related_version"`
+	HashAlgorithm   string     `json:"hash_algorithm,omitempty"`
+	Algorithm       string     `json:"algorithm"`
+	PublicKey       string     `json:"public_key,omitempty"`
+	PublicCertChain []string   `json:"public_cert_chain,omitempty"`
+	CertURL         string     `json:"cert_url,omitempty"`
+	Thumbprint      string     `json:"thumbprint,omitempty"`
+	Value           string     `json:"value"`
+	// Extra holds properties that are not defined above, so that they
+	// survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (s *Signature) MarshalJSON() ([]byte, error) {
+	type plain Signature
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *Signature) UnmarshalJSON(data []byte) (err error) {
+	type plain Signature
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+// the protected header of the JWS held in a signature value. Besides the
+// key, it holds the properties of the signature object that identify the
+// signee and the signed playbook, so that they cannot be changed unnoticed.
+type jwsHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	// X509Chain is the certificate chain of the key, as in public_cert_chain
+	X509Chain      []string   `json:"x5c,omitempty"`
+	Signee         string     `json:"signee,omitempty"`
+	Created        *time.Time `json:"created,omitempty"`
+	RelatedTo      string     `json:"related_to,omitempty"`
+	RelatedVersion *time.Time `json:"related_version,omitempty"`
+}
+
+// protects checks that the unprotected properties of a signature are those
+// of the protected header, returning the name of the first that is not
+func (h jwsHeader) protects(signature Signature) (string, bool) {
+	timesEqual := func(a, b *time.Time) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+	}
+	switch {
+	case h.Signee != signature.Signee:
+		return "signee", false
+	case !timesEqual(h.Created, signature.Created):
+		return "created", false
+	case h.RelatedTo != signature.RelatedTo:
+		return "related_to", false
+	case !timesEqual(h.RelatedVersion, signature.RelatedVersion):
+		return "related_version", false
+	case strings.Join(h.X509Chain, ",") != strings.Join(signature.PublicCertChain, ","):
+		return "public_cert_chain", false
+	}
+	return "", true
+}
+
+// Signer signs playbooks with a private key, see NewSigner
+type Signer struct {
+	// Signee names who is signing, it defaults to the certificate subject
+	Signee        string
+	key           crypto.Signer
+	algorithm     string
+	hash          crypto.Hash
+	hashAlgorithm string
+	certificates  []*x509.Certificate
+}
+
+// NewSigner creates a Signer from a PEM encoded Ed25519, ECDSA or RSA
+// private key, in PKCS #8, PKCS #1 or SEC 1 form. The certificate PEM is
+// optional, if given it holds the certificate of the key followed by any
+// intermediate certificates, and is embedded in each signature.
+func NewSigner(keyPem []byte, certificatePem []byte) (*Signer, error) {
+	key, err := parsePrivateKey(keyPem)
+	if err != nil {
+		return nil, err
+	}
+	signer := &Signer{key: key}
+	switch publicKey := key.Public().(type) {
+	case ed25519.PublicKey:
+		signer.algorithm, signer.hashAlgorithm = SIGNATURE_ALGORITHM_EDDSA, "sha512"
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P256():
+			signer.algorithm, signer.hash, signer.hashAlgorithm = SIGNATURE_ALGORITHM_ES256, crypto.SHA256, "sha256"
+		case elliptic.P384():
+			signer.algorithm, signer.hash, signer.hashAlgorithm = SIGNATURE_ALGORITHM_ES384, crypto.SHA384, "sha384"
+		case elliptic.P521():
+			signer.algorithm, signer.hash, signer.hashAlgorithm = SIGNATURE_ALGORITHM_ES512, crypto.SHA512, "sha512"
+		default:
+			return nil, fmt.Errorf("unsupported elliptic curve %s", publicKey.Curve.Params().Name)
+		}
+	case *rsa.PublicKey:
+		signer.algorithm, signer.hash, signer.hashAlgorithm = SIGNATURE_ALGORITHM_RS256, crypto.SHA256, "sha256"
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+	if len(certificatePem) > 0 {
+		signer.certificates, err = parseCertificates(certificatePem)
+		if err != nil {
+			return nil, err
+		}
+		if len(signer.certificates) == 0 {
+			return nil, errors.New("no certificates found")
+		}
+		leaf := signer.certificates[0]
+		if !publicKeysEqual(leaf.PublicKey, key.Public()) {
+			return nil, errors.New("the first certificate does not match the private key")
+		}
+		signer.Signee = leaf.Subject.CommonName
+		if signer.Signee == "" {
+			signer.Signee = leaf.Subject.String()
+		}
+	}
+	return signer, nil
+}
+
+// parsePrivateKey parses the first PEM block holding a private key
+func parsePrivateKey(keyPem []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// parseCertificates parses the certificates in PEM data, in order
+func parseCertificates(certificatePem []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, certificatePem = pem.Decode(certificatePem)
+		if block == nil {
+			return certificates, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+}
+
+// publicKeysEqual compares public keys of any supported type
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	comparable, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && comparable.Equal(b)
+}
+
+// keyThumbprint returns the unpadded base64url SHA-256 digest of a DER
+// encoded public key
+func keyThumbprint(publicKeyDer []byte) string {
+	digest := sha256.Sum256(publicKeyDer)
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// signingPayload returns the canonical form of a playbook without its
+// signatures, which is what each signature signs
+func signingPayload(data []byte) ([]byte, error) {
+	value, err := decodeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	playbook, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("a playbook must be a JSON object")
+	}
+	delete(playbook, "signatures")
+	var buffer bytes.Buffer
+	if err := writeCanonical(&buffer, playbook); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Sign signs a CACAO 2.0 playbook and adds the signature to its signatures,
+// replacing an earlier signature of the same version by the same key. The
+// signature is created at the current time, or the time given using
+// WithTimestamp. Any change to the playbook after signing, other than to
+// its signatures, invalidates the signature.
+func Sign(playbook *CacaoPlaybook, signer *Signer, options ...ConvertOption) (*Signature, error) {
+	if NormalizeSpecVersion(playbook.SpecVersion) != CACAO_SPEC_VERSION_20 {
+		return nil, fmt.Errorf("only CACAO %s playbooks can be signed, found spec_version %q", CACAO_SPEC_VERSION_20, playbook.SpecVersion)
+	}
+	if playbook.Modified == nil {
+		return nil, errors.New("the playbook has no modified timestamp")
+	}
+	settings := newConvertOptions(options)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if settings.timestamp != nil {
+		now = *settings.timestamp
+	}
+	data, err := json.Marshal(playbook)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := signingPayload(data)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyDer, err := x509.MarshalPKIXPublicKey(signer.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	thumbprint := keyThumbprint(publicKeyDer)
+	relatedVersion := *playbook.Modified
+	header := jwsHeader{
+		Algorithm:      signer.algorithm,
+		KeyID:          thumbprint,
+		Signee:         signer.Signee,
+		Created:        &now,
+		RelatedTo:      playbook.ID,
+		RelatedVersion: &relatedVersion,
+	}
+	for _, certificate := range signer.certificates {
+		header.X509Chain = append(header.X509Chain, base64.StdEncoding.EncodeToString(certificate.Raw))
+	}
+	value, err := signer.signJws(header, payload)
+	if err != nil {
+		return nil, err
+	}
+	signature := Signature{
+		Type:            CACAO_SIGNATURE_TYPE_JSS,
+		ID:              fmt.Sprintf("signature--%s", deterministicUuid(fmt.Sprintf("%s:%s:%s", playbook.ID, relatedVersion.Format(time.RFC3339Nano), thumbprint))),
+		Created:         &now,
+		Modified:        &now,
+		Signee:          signer.Signee,
+		RelatedTo:       playbook.ID,
+		RelatedVersion:  &relatedVersion,
+		HashAlgorithm:   signer.hashAlgorithm,
+		Algorithm:       signer.algorithm,
+		PublicKey:       base64.StdEncoding.EncodeToString(publicKeyDer),
+		PublicCertChain: header.X509Chain,
+		Thumbprint:      thumbprint,
+		Value:           value,
+	}
+	for i, existing := range playbook.Signatures {
+		if existing.ID == signature.ID {
+			playbook.Signatures[i] = signature
+			return &signature, nil
+		}
+	}
+	playbook.Signatures = append(playbook.Signatures, signature)
+	return &signature, nil
+}
+
+// signJws returns the JWS compact serialisation of a payload, with the
+// payload detached: <header>..<signature>
+func (s *Signer) signJws(header jwsHeader, payload []byte) (string, error) {
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(headerJson)
+	signingInput := []byte(encodedHeader + "." + base64.RawURLEncoding.EncodeToString(payload))
+	var signature []byte
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, signingInput)
+	case *ecdsa.PrivateKey:
+		digest := s.hash.New()
+		digest.Write(signingInput)
+		r, sValue, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		if err != nil {
+			return "", err
+		}
+		// JWS uses the fixed size concatenation of r and s
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		sValue.FillBytes(signature[size:])
+	case *rsa.PrivateKey:
+		digest := s.hash.New()
+		digest.Write(signingInput)
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, s.hash, digest.Sum(nil))
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", s.key)
+	}
+	return encodedHeader + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// errContentChanged is returned when a signature does not match the playbook
+var errContentChanged = errors.New("the playbook has changed since it was signed")
+
+// verifyJws checks a JWS with a detached payload, returning its protected header
+func verifyJws(value string, algorithm string, publicKey crypto.PublicKey, payload []byte) (jwsHeader, error) {
+	var header jwsHeader
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return header, errors.New("the signature value is not a JWS with a detached payload")
+	}
+	headerJson, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return header, fmt.Errorf("could not decode the JWS header: %s", err)
+	}
+	if err := json.Unmarshal(headerJson, &header); err != nil {
+		return header, fmt.Errorf("could not parse the JWS header: %s", err)
+	}
+	if header.Algorithm != algorithm {
+		return header, fmt.Errorf("the JWS algorithm %q does not match the signature algorithm %q", header.Algorithm, algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return header, fmt.Errorf("could not decode the JWS signature: %s", err)
+	}
+	return header, verifyJwsSignature(algorithm, publicKey, []byte(parts[0]+"."+base64.RawURLEncoding.EncodeToString(payload)), signature)
+}
+
+// verifyJwsSignature checks the signature of a JWS signing input
+func verifyJwsSignature(algorithm string, publicKey crypto.PublicKey, signingInput []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch algorithm {
+	case SIGNATURE_ALGORITHM_EDDSA:
+		key, ok := publicKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an Ed25519 key", algorithm)
+		}
+		if !ed25519.Verify(key, signingInput, signature) {
+			return errContentChanged
+		}
+		return nil
+	case SIGNATURE_ALGORITHM_ES256, SIGNATURE_ALGORITHM_ES384, SIGNATURE_ALGORITHM_ES512:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an ECDSA key", algorithm)
+		}
+		hash = map[string]crypto.Hash{
+			SIGNATURE_ALGORITHM_ES256: crypto.SHA256,
+			SIGNATURE_ALGORITHM_ES384: crypto.SHA384,
+			SIGNATURE_ALGORITHM_ES512: crypto.SHA512,
+		}[algorithm]
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errContentChanged
+		}
+		digest := hash.New()
+		digest.Write(signingInput)
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest.Sum(nil), r, s) {
+			return errContentChanged
+		}
+		return nil
+	case SIGNATURE_ALGORITHM_RS256:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an RSA key", algorithm)
+		}
+		digest := sha256.Sum256(signingInput)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return errContentChanged
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported signature algorithm %q", algorithm)
+}
+
+// TrustStore holds the keys and certificates that signatures are trusted from
+type TrustStore struct {
+	thumbprints map[string]bool
+	roots       *x509.CertPool
+}
+
+// NewTrustStore creates a TrustStore from PEM data holding public keys
+// ("PUBLIC KEY") and certificates. A signature is trusted if it was made
+// with one of the keys, the key of one of the certificates, or a key
+// whose embedded certificate chain leads to one of the certificates.
+func NewTrustStore(pemData ...[]byte) (*TrustStore, error) {
+	trust := &TrustStore{thumbprints: make(map[string]bool), roots: x509.NewCertPool()}
+	for _, data := range pemData {
+		for {
+			var block *pem.Block
+			block, data = pem.Decode(data)
+			if block == nil {
+				break
+			}
+			var publicKeyDer []byte
+			switch block.Type {
+			case "PUBLIC KEY":
+				if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+					return nil, err
+				}
+				publicKeyDer = block.Bytes
+			case "CERTIFICATE":
+				certificate, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				trust.roots.AddCert(certificate)
+				publicKeyDer = certificate.RawSubjectPublicKeyInfo
+			default:
+				return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+			}
+			trust.thumbprints[keyThumbprint(publicKeyDer)] = true
+		}
+	}
+	return trust, nil
+}
+
+// trusts checks whether a key, identified by its thumbprint, or its
+// certificate chain is trusted at the given time
+func (t *TrustStore) trusts(thumbprint string, chain []*x509.Certificate, at time.Time) bool {
+	if t.thumbprints[thumbprint] {
+		return true
+	}
+	if len(chain) == 0 {
+		return false
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range chain[1:] {
+		intermediates.AddCert(certificate)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         t.roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+// SignatureResult is the outcome of verifying one signature of a playbook
+type SignatureResult struct {
+	SignatureID string `json:"signature_id"`
+	Signee      string `json:"signee,omitempty"`
+	// Thumbprint identifies the key that signed, see Signature.Thumbprint
+	Thumbprint string `json:"thumbprint,omitempty"`
+	// Subject is the subject of the signing certificate, if one was embedded
+	Subject string `json:"subject,omitempty"`
+	// Intact is true if the playbook has not changed since it was signed
+	Intact bool `json:"intact"`
+	// Trusted is true if the key is trusted by the TrustStore
+	Trusted bool `json:"trusted"`
+	// Problem describes why the signature is not valid
+	Problem string `json:"problem,omitempty"`
+}
+
+// Valid returns true if no problem was found with the signature
+func (r SignatureResult) Valid() bool {
+	return r.Problem == ""
+}
+
+func (r SignatureResult) String() string {
+	signee := r.Signee
+	if signee == "" {
+		signee = r.Subject
+	}
+	if signee == "" {
+		signee = "unknown signee"
+	}
+	status := "valid"
+	if !r.Valid() {
+		status = r.Problem
+	} else if !r.Trusted {
+		status = "valid, key not checked against a trust store"
+	}
+	return fmt.Sprintf("%s signed by %s with key %s: %s", r.SignatureID, signee, r.Thumbprint, status)
+}
+
+// VerifySignatures verifies each signature of a JSON playbook offline,
+// using the public key or certificate chain embedded in the signature.
+// If trust is not nil, signatures made by keys it does not trust are
+// reported as a problem. The playbook is verified as read, so that a
+// change to any property, including unknown ones, is detected.
+func VerifySignatures(data []byte, trust *TrustStore) ([]SignatureResult, error) {
+	var document struct {
+		ID         string      `json:"id"`
+		Modified   *time.Time  `json:"modified"`
+		Signatures []Signature `json:"signatures"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	payload, err := signingPayload(data)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SignatureResult, 0, len(document.Signatures))
+	for _, signature := range document.Signatures {
+		result := verifySignature(signature, payload, trust)
+		if result.Valid() && signature.RelatedTo != document.ID {
+			result.Problem = fmt.Sprintf("the signature relates to %s", signature.RelatedTo)
+		}
+		if result.Valid() && (signature.RelatedVersion == nil || document.Modified == nil || !signature.RelatedVersion.Equal(*document.Modified)) {
+			result.Problem = "the signature relates to a different version of the playbook"
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// verifySignature checks a signature against the signing payload
+func verifySignature(signature Signature, payload []byte, trust *TrustStore) SignatureResult {
+	result := SignatureResult{
+		SignatureID: signature.ID,
+		Thumbprint:  signature.Thumbprint,
+	}
+	var chain []*x509.Certificate
+	for _, encoded := range signature.PublicCertChain {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			result.Problem = fmt.Sprintf("could not decode public_cert_chain: %s", err)
+			return result
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			result.Problem = fmt.Sprintf("could not parse public_cert_chain: %s", err)
+			return result
+		}
+		chain = append(chain, certificate)
+	}
+	var publicKeyDer []byte
+	if signature.PublicKey != "" {
+		var err error
+		publicKeyDer, err = base64.StdEncoding.DecodeString(signature.PublicKey)
+		if err != nil {
+			result.Problem = fmt.Sprintf("could not decode public_key: %s", err)
+			return result
+		}
+	} else if len(chain) > 0 {
+		publicKeyDer = chain[0].RawSubjectPublicKeyInfo
+	} else {
+		result.Problem = "the signature has no public_key or public_cert_chain"
+		return result
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyDer)
+	if err != nil {
+		result.Problem = fmt.Sprintf("could not parse public_key: %s", err)
+		return result
+	}
+	result.Thumbprint = keyThumbprint(publicKeyDer)
+	if signature.Thumbprint != "" && signature.Thumbprint != result.Thumbprint {
+		result.Problem = "the thumbprint does not match the public key"
+		return result
+	}
+	if len(chain) > 0 {
+		result.Subject = chain[0].Subject.String()
+		if !publicKeysEqual(chain[0].PublicKey, publicKey) {
+			result.Problem = "the certificate does not match the public key"
+			return result
+		}
+	}
+	header, err := verifyJws(signature.Value, signature.Algorithm, publicKey, payload)
+	if err != nil {
+		result.Problem = err.Error()
+		return result
+	}
+	result.Intact = true
+	// only what the protected header holds is reported, so that properties
+	// of the signature object cannot be changed after signing
+	result.Signee = header.Signee
+	if property, ok := header.protects(signature); !ok {
+		result.Problem = fmt.Sprintf("the %s of the signature does not match the signed value", property)
+		return result
+	}
+	// revoking a signature can only invalidate it, so revoked need not be signed
+	if signature.Revoked {
+		result.Problem = "the signature has been revoked"
+		return result
+	}
+	if len(chain) > 0 && header.Created != nil && (header.Created.Before(chain[0].NotBefore) || header.Created.After(chain[0].NotAfter)) {
+		result.Problem = "the signature was created outside the validity period of its certificate"
+		return result
+	}
+	if trust != nil {
+		result.Trusted = trust.trusts(result.Thumbprint, chain, time.Now())
+		if !result.Trusted {
+			result.Problem = "the signing key is not trusted"
+		}
+	}
+	return result
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+// signingTestPlaybook converts the test BPMN to a CACAO 2.0 playbook
+func signingTestPlaybook(t *testing.T) *cacao.CacaoPlaybook {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	return cacaoPlaybook
+}
+
+// encodePrivateKey returns a private key in PKCS #8 PEM form
+func encodePrivateKey(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("could not marshal private key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// encodePublicKey returns the public key of a private key in PEM form
+func encodePublicKey(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("could not marshal public key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestSignAndVerify(t *testing.T) {
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testCases := map[string]struct {
+		key       crypto.Signer
+		algorithm string
+	}{
+		"ed25519": {ed25519Key, cacao.SIGNATURE_ALGORITHM_EDDSA},
+		"ecdsa":   {ecdsaKey, cacao.SIGNATURE_ALGORITHM_ES384},
+		"rsa":     {rsaKey, cacao.SIGNATURE_ALGORITHM_RS256},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cacaoPlaybook := signingTestPlaybook(t)
+			signer, err := cacao.NewSigner(encodePrivateKey(t, testCase.key), nil)
+			if err != nil {
+				t.Fatalf("could not create signer: %s", err)
+			}
+			signer.Signee = "SOC team"
+			signature, err := cacao.Sign(cacaoPlaybook, signer)
+			if err != nil {
+				t.Fatalf("could not sign playbook: %s", err)
+			}
+			assert.Equal(t, testCase.algorithm, signature.Algorithm)
+			assert.Equal(t, cacaoPlaybook.ID, signature.RelatedTo)
+			assert.Len(t, cacaoPlaybook.Signatures, 1)
+
+			data, err := json.MarshalIndent(cacaoPlaybook, "", "    ")
+			if err != nil {
+				t.Fatalf("could not marshal Cacao playbook: %s", err)
+			}
+			violations, err := cacao.ValidateSchema(data, "")
+			assert.NoError(t, err)
+			assert.Empty(t, violations)
+
+			trust, err := cacao.NewTrustStore(encodePublicKey(t, testCase.key))
+			if err != nil {
+				t.Fatalf("could not create trust store: %s", err)
+			}
+			results, err := cacao.VerifySignatures(data, trust)
+			if assert.NoError(t, err) && assert.Len(t, results, 1) {
+				assert.True(t, results[0].Valid(), results[0].String())
+				assert.True(t, results[0].Intact)
+				assert.True(t, results[0].Trusted)
+				assert.Equal(t, "SOC team", results[0].Signee)
+				assert.Equal(t, signature.Thumbprint, results[0].Thumbprint)
+			}
+
+			// any change to the content invalidates the signature
+			tampered := strings.Replace(string(data), cacaoPlaybook.Name, "Tampered", 1)
+			results, err = cacao.VerifySignatures([]byte(tampered), nil)
+			if assert.NoError(t, err) && assert.Len(t, results, 1) {
+				assert.False(t, results[0].Intact)
+				assert.Contains(t, results[0].Problem, "changed since it was signed")
+			}
+		})
+	}
+}
+
+func TestVerifyUntrustedKey(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	cacaoPlaybook := signingTestPlaybook(t)
+	signer, err := cacao.NewSigner(encodePrivateKey(t, key), nil)
+	if err != nil {
+		t.Fatalf("could not create signer: %s", err)
+	}
+	if _, err := cacao.Sign(cacaoPlaybook, signer); err != nil {
+		t.Fatalf("could not sign playbook: %s", err)
+	}
+	data, _ := json.Marshal(cacaoPlaybook)
+
+	// without a trust store the embedded key is used
+	results, err := cacao.VerifySignatures(data, nil)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.True(t, results[0].Valid())
+		assert.False(t, results[0].Trusted)
+	}
+	trust, _ := cacao.NewTrustStore(encodePublicKey(t, otherKey))
+	results, err = cacao.VerifySignatures(data, trust)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.True(t, results[0].Intact)
+		assert.False(t, results[0].Valid())
+		assert.Equal(t, "the signing key is not trusted", results[0].Problem)
+	}
+}
+
+// testCertificates creates a CA certificate and a certificate issued by it
+// for a new key, both valid from 2022 until the given time
+func testCertificates(t *testing.T, notAfter time.Time) (caKey *ecdsa.PrivateKey, caPem []byte, key *ecdsa.PrivateKey, leafPem []byte) {
+	caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("could not create CA certificate: %s", err)
+	}
+	caCertificate, _ := x509.ParseCertificate(caDer)
+	key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Playbook Author"},
+		NotBefore:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCertificate, key.Public(), caKey)
+	if err != nil {
+		t.Fatalf("could not create certificate: %s", err)
+	}
+	caPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer})
+	leafPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDer})
+	return caKey, caPem, key, leafPem
+}
+
+func TestSignWithCertificate(t *testing.T) {
+	caKey, caPem, key, leafPem := testCertificates(t, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	_, err := cacao.NewSigner(encodePrivateKey(t, caKey), leafPem)
+	assert.Error(t, err, "the certificate must match the key")
+
+	signer, err := cacao.NewSigner(encodePrivateKey(t, key), leafPem)
+	if err != nil {
+		t.Fatalf("could not create signer: %s", err)
+	}
+	cacaoPlaybook := signingTestPlaybook(t)
+	signature, err := cacao.Sign(cacaoPlaybook, signer, cacao.WithTimestamp(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("could not sign playbook: %s", err)
+	}
+	assert.Equal(t, "Playbook Author", signature.Signee)
+	assert.Len(t, signature.PublicCertChain, 1)
+	// signing the same version again replaces the signature
+	_, err = cacao.Sign(cacaoPlaybook, signer, cacao.WithTimestamp(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)))
+	assert.NoError(t, err)
+	assert.Len(t, cacaoPlaybook.Signatures, 1)
+
+	data, _ := json.Marshal(cacaoPlaybook)
+	trust, err := cacao.NewTrustStore(caPem)
+	if err != nil {
+		t.Fatalf("could not create trust store: %s", err)
+	}
+	results, err := cacao.VerifySignatures(data, trust)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.True(t, results[0].Valid(), results[0].String())
+		assert.True(t, results[0].Trusted)
+		assert.Equal(t, "CN=Playbook Author", results[0].Subject)
+	}
+
+	// a signature made before the certificate was issued is not valid
+	cacaoPlaybook.Signatures = nil
+	if _, err := cacao.Sign(cacaoPlaybook, signer, cacao.WithTimestamp(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))); err != nil {
+		t.Fatalf("could not sign playbook: %s", err)
+	}
+	data, _ = json.Marshal(cacaoPlaybook)
+	results, err = cacao.VerifySignatures(data, trust)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.True(t, results[0].Intact)
+		assert.Contains(t, results[0].Problem, "outside the validity period")
+	}
+}
+
+func TestVerifyExpiredCertificate(t *testing.T) {
+	_, caPem, key, leafPem := testCertificates(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	signer, err := cacao.NewSigner(encodePrivateKey(t, key), leafPem)
+	if err != nil {
+		t.Fatalf("could not create signer: %s", err)
+	}
+	cacaoPlaybook := signingTestPlaybook(t)
+	// signed while the certificate was valid, the chain is checked at the time of verification
+	if _, err := cacao.Sign(cacaoPlaybook, signer, cacao.WithTimestamp(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC))); err != nil {
+		t.Fatalf("could not sign playbook: %s", err)
+	}
+	data, _ := json.Marshal(cacaoPlaybook)
+	trust, _ := cacao.NewTrustStore(caPem)
+	results, err := cacao.VerifySignatures(data, trust)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.True(t, results[0].Intact)
+		assert.False(t, results[0].Trusted)
+		assert.Equal(t, "the signing key is not trusted", results[0].Problem)
+	}
+}
+
+func TestVerifyChangedSignature(t *testing.T) {
+	_, caPem, key, leafPem := testCertificates(t, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, otherCaPem, _, otherLeafPem := testCertificates(t, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+	signer, err := cacao.NewSigner(encodePrivateKey(t, key), leafPem)
+	if err != nil {
+		t.Fatalf("could not create signer: %s", err)
+	}
+	cacaoPlaybook := signingTestPlaybook(t)
+	if _, err := cacao.Sign(cacaoPlaybook, signer, cacao.WithTimestamp(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC))); err != nil {
+		t.Fatalf("could not sign playbook: %s", err)
+	}
+	otherLeaf, _ := pem.Decode(otherLeafPem)
+	otherCa, _ := pem.Decode(otherCaPem)
+	backdated := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	testCases := map[string]struct {
+		change   func(signature *cacao.Signature)
+		property string
+	}{
+		"signee":  {func(signature *cacao.Signature) { signature.Signee = "Someone else" }, "signee"},
+		"created": {func(signature *cacao.Signature) { signature.Created = &backdated }, "created"},
+		"chain": {func(signature *cacao.Signature) {
+			signature.PublicCertChain = append(signature.PublicCertChain, base64.StdEncoding.EncodeToString(otherCa.Bytes))
+		}, "public_cert_chain"},
+	}
+	trust, _ := cacao.NewTrustStore(caPem)
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			changed := *cacaoPlaybook
+			changed.Signatures = []cacao.Signature{cacaoPlaybook.Signatures[0]}
+			testCase.change(&changed.Signatures[0])
+			data, _ := json.Marshal(&changed)
+			results, err := cacao.VerifySignatures(data, trust)
+			if assert.NoError(t, err) && assert.Len(t, results, 1) {
+				assert.True(t, results[0].Intact)
+				assert.False(t, results[0].Valid())
+				assert.Equal(t, fmt.Sprintf("the %s of the signature does not match the signed value", testCase.property), results[0].Problem)
+				assert.Equal(t, "Playbook Author", results[0].Signee, "the signee is taken from the signed header")
+			}
+		})
+	}
+
+	// a certificate that does not match the key is rejected
+	changed := *cacaoPlaybook
+	changed.Signatures = []cacao.Signature{cacaoPlaybook.Signatures[0]}
+	changed.Signatures[0].PublicCertChain = []string{base64.StdEncoding.EncodeToString(otherLeaf.Bytes)}
+	data, _ := json.Marshal(&changed)
+	results, err := cacao.VerifySignatures(data, trust)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.False(t, results[0].Valid())
+	}
+}
+
+func TestSignRequiresCacao20(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := cacao.NewSigner(encodePrivateKey(t, key), nil)
+	if err != nil {
+		t.Fatalf("could not create signer: %s", err)
+	}
+	cacaoPlaybook := signingTestPlaybook(t)
+	cacaoPlaybook.SpecVersion = cacao.CACAO_SPEC_VERSION_11
+	_, err = cacao.Sign(cacaoPlaybook, signer)
+	assert.Error(t, err)
+}
@@ -0,0 +1,378 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Step represents a step in the workflow. Each step type has its own struct,
+// use a type switch to access the properties specific to a step type.
+type Step interface {
+	// StepType returns the type of the step, eg. "action"
+	StepType() string
+	// Common returns the properties shared by all step types
+	Common() *StepCommon
+	isStep()
+}
+
+// StepCommon holds the properties shared by all step types
+type StepCommon struct {
+	Type               string                      `json:"type"`
+	Name               string                      `json:"name,omitempty"`
+	Description        string                      `json:"description,omitempty"`
+	ExternalReferences []ExternalReference         `json:"external_references,omitempty"`
+	Delay              int                         `json:"delay,omitempty"`
+	Timeout            int                         `json:"timeout,omitempty"`
+	StepVariables      map[string]PlaybookVariable `json:"step_variables,omitempty"`
+	Owner              string                      `json:"owner,omitempty"`
+	OnCompletion       string                      `json:"on_completion,omitempty"`
+	OnSuccess          string                      `json:"on_success,omitempty"`
+	OnFailure          string                      `json:"on_failure,omitempty"`
+	StepExtensions     map[string]json.RawMessage  `json:"step_extensions,omitempty"`
+	InArgs             []string                    `json:"in_args,omitempty"`
+	OutArgs            []string                    `json:"out_args,omitempty"`
+	// Extra holds properties that are not defined for the step type, so
+	// that they survive reading and writing a playbook
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// StepType returns the type of the step
+func (s *StepCommon) StepType() string {
+	return s.Type
+}
+
+// Common returns the properties shared by all step types
+func (s *StepCommon) Common() *StepCommon {
+	return s
+}
+
+// StartStep is the step a workflow starts at
+type StartStep struct {
+	StepCommon
+}
+
+// EndStep ends a workflow or a branch of a parallel step
+type EndStep struct {
+	StepCommon
+}
+
+// ActionStep runs commands, its type is "single" in CACAO 1.1
+type ActionStep struct {
+	StepCommon
+	Commands []Command `json:"commands,omitempty"`
+	Agent    string    `json:"agent,omitempty"`
+	Targets  []string  `json:"targets,omitempty"`
+}
+
+// PlaybookActionStep runs another playbook, its type is "playbook" in CACAO 1.1
+type PlaybookActionStep struct {
+	StepCommon
+	PlaybookID      string `json:"playbook_id"`
+	PlaybookVersion string `json:"playbook_version,omitempty"`
+}
+
+// ParallelStep runs the next steps in parallel
+type ParallelStep struct {
+	StepCommon
+	NextSteps []string `json:"next_steps"`
+}
+
+// IfConditionStep branches on a condition
+type IfConditionStep struct {
+	StepCommon
+	Condition string `json:"condition"`
+	OnTrue    string `json:"on_true"`
+	OnFalse   string `json:"on_false,omitempty"`
+}
+
+// WhileConditionStep repeats the on_true branch while a condition holds
+type WhileConditionStep struct {
+	StepCommon
+	Condition string `json:"condition"`
+	OnTrue    string `json:"on_true"`
+	OnFalse   string `json:"on_false,omitempty"`
+}
+
+// SwitchConditionStep branches on the value of a variable
+type SwitchConditionStep struct {
+	StepCommon
+	Switch string              `json:"switch"`
+	Cases  map[string][]string `json:"cases"`
+}
+
+func (*StartStep) isStep()           {}
+func (*EndStep) isStep()             {}
+func (*ActionStep) isStep()          {}
+func (*PlaybookActionStep) isStep()  {}
+func (*ParallelStep) isStep()        {}
+func (*IfConditionStep) isStep()     {}
+func (*WhileConditionStep) isStep()  {}
+func (*SwitchConditionStep) isStep() {}
+
+// NewStep returns an empty step of the given type, which may be a CACAO 1.1 or 2.0 step type
+func NewStep(stepType string) (Step, error) {
+	var step Step
+	switch stepType {
+	case CACAO_STEP_TYPE_START:
+		step = new(StartStep)
+	case CACAO_STEP_TYPE_END:
+		step = new(EndStep)
+	case CACAO_STEP_TYPE_ACTION, CACAO_STEP_TYPE_11_SINGLE:
+		step = new(ActionStep)
+	case CACAO_STEP_TYPE_PLAYBOOK_ACTION, CACAO_STEP_TYPE_11_PLAYBOOK:
+		step = new(PlaybookActionStep)
+	case CACAO_STEP_TYPE_PARALLEL:
+		step = new(ParallelStep)
+	case CACAO_STEP_TYPE_IF_COND:
+		step = new(IfConditionStep)
+	case CACAO_STEP_TYPE_WHILE_COND:
+		step = new(WhileConditionStep)
+	case CACAO_STEP_TYPE_SWITCH_COND:
+		step = new(SwitchConditionStep)
+	default:
+		return nil, fmt.Errorf("unknown step type %q", stepType)
+	}
+	step.Common().Type = stepType
+	return step, nil
+}
+
+// Workflow maps step IDs to steps
+type Workflow map[string]Step
+
+// UnmarshalJSON decodes each step into the struct for its type
+func (w *Workflow) UnmarshalJSON(data []byte) error {
+	var rawSteps map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rawSteps); err != nil {
+		return err
+	}
+	if rawSteps == nil {
+		*w = nil
+		return nil
+	}
+	workflow := make(Workflow)
+	for stepId, rawStep := range rawSteps {
+		var header struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(rawStep, &header); err != nil {
+			return fmt.Errorf("step %s: %s", stepId, err)
+		}
+		step, err := NewStep(header.Type)
+		if err != nil {
+			return fmt.Errorf("step %s: %s", stepId, err)
+		}
+		if err := json.Unmarshal(rawStep, step); err != nil {
+			return fmt.Errorf("step %s: %s", stepId, err)
+		}
+		workflow[stepId] = step
+	}
+	*w = workflow
+	return nil
+}
+
+func (s *StartStep) MarshalJSON() ([]byte, error) {
+	type plain StartStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *StartStep) UnmarshalJSON(data []byte) (err error) {
+	type plain StartStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *EndStep) MarshalJSON() ([]byte, error) {
+	type plain EndStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *EndStep) UnmarshalJSON(data []byte) (err error) {
+	type plain EndStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *ActionStep) MarshalJSON() ([]byte, error) {
+	type plain ActionStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *ActionStep) UnmarshalJSON(data []byte) (err error) {
+	type plain ActionStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *PlaybookActionStep) MarshalJSON() ([]byte, error) {
+	type plain PlaybookActionStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *PlaybookActionStep) UnmarshalJSON(data []byte) (err error) {
+	type plain PlaybookActionStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *ParallelStep) MarshalJSON() ([]byte, error) {
+	type plain ParallelStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *ParallelStep) UnmarshalJSON(data []byte) (err error) {
+	type plain ParallelStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *IfConditionStep) MarshalJSON() ([]byte, error) {
+	type plain IfConditionStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *IfConditionStep) UnmarshalJSON(data []byte) (err error) {
+	type plain IfConditionStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *WhileConditionStep) MarshalJSON() ([]byte, error) {
+	type plain WhileConditionStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *WhileConditionStep) UnmarshalJSON(data []byte) (err error) {
+	type plain WhileConditionStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+func (s *SwitchConditionStep) MarshalJSON() ([]byte, error) {
+	type plain SwitchConditionStep
+	return marshalWithExtra((*plain)(s), s.Extra)
+}
+
+func (s *SwitchConditionStep) UnmarshalJSON(data []byte) (err error) {
+	type plain SwitchConditionStep
+	s.Extra, err = unmarshalWithExtra(data, (*plain)(s))
+	return err
+}
+
+// CopyStep returns a deep copy of a step
+func CopyStep(step Step) (Step, error) {
+	data, err := json.Marshal(step)
+	if err != nil {
+		return nil, err
+	}
+	copied, err := NewStep(step.StepType())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// stepReference is a reference from a step property to another step
+type stepReference struct {
+	Property string
+	StepID   string
+}
+
+// stepReferences lists the steps a step can transition to, in a stable order
+func stepReferences(step Step) []stepReference {
+	var references []stepReference
+	add := func(property, stepId string) {
+		if stepId != "" {
+			references = append(references, stepReference{Property: property, StepID: stepId})
+		}
+	}
+	common := step.Common()
+	add("on_completion", common.OnCompletion)
+	add("on_success", common.OnSuccess)
+	add("on_failure", common.OnFailure)
+	switch typed := step.(type) {
+	case *IfConditionStep:
+		add("on_true", typed.OnTrue)
+		add("on_false", typed.OnFalse)
+	case *WhileConditionStep:
+		add("on_true", typed.OnTrue)
+		add("on_false", typed.OnFalse)
+	case *ParallelStep:
+		for i, nextStep := range typed.NextSteps {
+			add(fmt.Sprintf("next_steps[%d]", i), nextStep)
+		}
+	case *SwitchConditionStep:
+		cases := make([]string, 0, len(typed.Cases))
+		for name := range typed.Cases {
+			cases = append(cases, name)
+		}
+		sort.Strings(cases)
+		for _, name := range cases {
+			for i, caseStep := range typed.Cases[name] {
+				add(fmt.Sprintf("cases[%s][%d]", name, i), caseStep)
+			}
+		}
+	}
+	return references
+}
+
+// renameStepReferences replaces each reference to another step with the
+// result of rename, which is called with non-empty step IDs only
+func renameStepReferences(step Step, rename func(stepId string) string) {
+	renameOne := func(stepId *string) {
+		if *stepId != "" {
+			*stepId = rename(*stepId)
+		}
+	}
+	renameAll := func(stepIds []string) []string {
+		if stepIds == nil {
+			return nil
+		}
+		renamed := make([]string, len(stepIds))
+		for i, stepId := range stepIds {
+			renamed[i] = rename(stepId)
+		}
+		return renamed
+	}
+	common := step.Common()
+	renameOne(&common.OnCompletion)
+	renameOne(&common.OnSuccess)
+	renameOne(&common.OnFailure)
+	switch typed := step.(type) {
+	case *IfConditionStep:
+		renameOne(&typed.OnTrue)
+		renameOne(&typed.OnFalse)
+	case *WhileConditionStep:
+		renameOne(&typed.OnTrue)
+		renameOne(&typed.OnFalse)
+	case *ParallelStep:
+		typed.NextSteps = renameAll(typed.NextSteps)
+	case *SwitchConditionStep:
+		if typed.Cases != nil {
+			cases := make(map[string][]string)
+			for name, caseSteps := range typed.Cases {
+				cases[name] = renameAll(caseSteps)
+			}
+			typed.Cases = cases
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// UpdatePlaybook combines a newly generated playbook with the previous
+// version of the same playbook. The previous created and created_by values,
+// revocation state and any metadata that was added by hand are kept. If the
+// workflow is unchanged the previous ID and modified timestamp are kept,
+// otherwise the playbook becomes a new version, see setVersion.
+func UpdatePlaybook(previous, generated *CacaoPlaybook) *CacaoPlaybook {
+	updated := *generated
+	if previous.Created != nil {
+		updated.Created = previous.Created
+	}
+	if previous.CreatedBy != "" {
+		updated.CreatedBy = previous.CreatedBy
+	}
+	if previous.Description != "" {
+		updated.Description = previous.Description
+	}
+	if len(previous.PlaybookTypes) > 0 {
+		updated.PlaybookTypes = previous.PlaybookTypes
+	}
+	if previous.ValidFrom != nil {
+		updated.ValidFrom = previous.ValidFrom
+	}
+	if previous.ValidUntil != nil {
+		updated.ValidUntil = previous.ValidUntil
+	}
+	if previous.Priority != 0 {
+		updated.Priority = previous.Priority
+	}
+	if previous.Severity != 0 {
+		updated.Severity = previous.Severity
+	}
+	if previous.Impact != 0 {
+		updated.Impact = previous.Impact
+	}
+	updated.Revoked = previous.Revoked
+	if previous.Extra != nil {
+		updated.Extra = make(map[string]json.RawMessage)
+		for name, value := range previous.Extra {
+			updated.Extra[name] = value
+		}
+		for name, value := range generated.Extra {
+			updated.Extra[name] = value
+		}
+	}
+	updated.Labels = mergeStrings(previous.Labels, generated.Labels)
+	updated.Markings = mergeStrings(previous.Markings, generated.Markings)
+	if len(previous.DataMarkingDefinitions) > 0 {
+		updated.DataMarkingDefinitions = make(map[string]DataMarking)
+		for markingId, marking := range previous.DataMarkingDefinitions {
+			updated.DataMarkingDefinitions[markingId] = marking
+		}
+		// the ID of a marking is derived from its content, so a definition
+		// that already exists is kept, along with its created timestamp
+		for markingId, marking := range generated.DataMarkingDefinitions {
+			if _, found := updated.DataMarkingDefinitions[markingId]; !found {
+				updated.DataMarkingDefinitions[markingId] = marking
+			}
+		}
+	}
+	if len(previous.PlaybookExtensions) > 0 {
+		updated.PlaybookExtensions = make(map[string]json.RawMessage)
+		for extensionId, extension := range previous.PlaybookExtensions {
+			updated.PlaybookExtensions[extensionId] = extension
+		}
+		for extensionId, extension := range generated.PlaybookExtensions {
+			updated.PlaybookExtensions[extensionId] = extension
+		}
+	}
+	if len(previous.ExtensionDefinitions) > 0 {
+		updated.ExtensionDefinitions = make(map[string]ExtensionDefinition)
+		for extensionId, definition := range previous.ExtensionDefinitions {
+			updated.ExtensionDefinitions[extensionId] = definition
+		}
+		for extensionId, definition := range generated.ExtensionDefinitions {
+			updated.ExtensionDefinitions[extensionId] = definition
+		}
+	}
+	updated.DerivedFrom = mergeStrings(previous.DerivedFrom, generated.DerivedFrom)
+	updated.ExternalReferences = append([]ExternalReference{}, previous.ExternalReferences...)
+	for _, reference := range generated.ExternalReferences {
+		if !containsReference(updated.ExternalReferences, reference) {
+			updated.ExternalReferences = append(updated.ExternalReferences, reference)
+		}
+	}
+	if len(updated.ExternalReferences) == 0 {
+		updated.ExternalReferences = nil
+	}
+	setVersion(&updated, previous)
+	return &updated
+}
+
+// setVersion makes a playbook the same version as previous if their
+// workflows are equal, keeping the previous ID and modified timestamp.
+// Otherwise it is a new version: its ID is derived from the previous ID
+// and its own modified timestamp, and the previous ID is added to
+// derived_from.
+func setVersion(playbook, previous *CacaoPlaybook) {
+	if previous.ID == "" {
+		return
+	}
+	if WorkflowEqual(previous, playbook) {
+		playbook.ID = previous.ID
+		playbook.Modified = previous.Modified
+		return
+	}
+	modified := ""
+	if playbook.Modified != nil {
+		modified = playbook.Modified.Format(time.RFC3339Nano)
+	}
+	playbook.ID = fmt.Sprintf("playbook--%s", deterministicUuid(fmt.Sprintf("%s:%s", previous.ID, modified)))
+	playbook.DerivedFrom = mergeStrings(playbook.DerivedFrom, []string{previous.ID})
+}
+
+// WorkflowEqual reports whether two playbooks have the same workflow and variables
+func WorkflowEqual(a, b *CacaoPlaybook) bool {
+	return a.WorkflowStart == b.WorkflowStart &&
+		a.WorkflowException == b.WorkflowException &&
+		jsonEquivalent(a.Workflow, b.Workflow) &&
+		jsonEquivalent(a.PlaybookVariables, b.PlaybookVariables)
+}
+
+// jsonEquivalent reports whether two values have the same JSON representation,
+// ignoring differences such as nil and empty maps that do not survive a round trip
+func jsonEquivalent(a, b interface{}) bool {
+	var decodedA, decodedB interface{}
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if json.Unmarshal(dataA, &decodedA) != nil || json.Unmarshal(dataB, &decodedB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(decodedA, decodedB)
+}
+
+// mergeStrings returns the values of a followed by those of b not in a
+func mergeStrings(a, b []string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, values := range [][]string{a, b} {
+		for _, value := range values {
+			if !seen[value] {
+				seen[value] = true
+				merged = append(merged, value)
+			}
+		}
+	}
+	return merged
+}
+
+func containsReference(references []ExternalReference, reference ExternalReference) bool {
+	for _, candidate := range references {
+		if jsonEquivalent(candidate, reference) {
+			return true
+		}
+	}
+	return false
+}
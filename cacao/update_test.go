@@ -0,0 +1,87 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePlaybook(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	firstRun := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	secondRun := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	original, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(firstRun))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	// simulate the previous output being edited by hand and read back from disk
+	original.CreatedBy = "identity--aa7caf3a-d55a-4e9a-b34e-056215fba56a"
+	original.Description = "Edited by hand"
+	original.Labels = []string{"soc"}
+	original.Revoked = true
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	previous := new(cacao.CacaoPlaybook)
+	if err := json.Unmarshal(data, previous); err != nil {
+		t.Fatalf("could not unmarshal Cacao playbook: %s", err)
+	}
+
+	generated, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithTimestamp(secondRun))
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	updated := cacao.UpdatePlaybook(previous, generated)
+	assert.Equal(t, firstRun, *updated.Created)
+	assert.Equal(t, firstRun, *updated.Modified, "modified must not change when the workflow is unchanged")
+	assert.Equal(t, "identity--aa7caf3a-d55a-4e9a-b34e-056215fba56a", updated.CreatedBy)
+	assert.Equal(t, "Edited by hand", updated.Description)
+	assert.Equal(t, []string{"soc"}, updated.Labels)
+	assert.True(t, updated.Revoked)
+	assert.Equal(t, previous.ID, updated.ID)
+	assert.Empty(t, updated.DerivedFrom)
+
+	generated.Name = "Renamed"
+	for _, step := range generated.Workflow {
+		step.Common().Name = step.Common().Name + " (revised)"
+	}
+	updated = cacao.UpdatePlaybook(previous, generated)
+	assert.Equal(t, firstRun, *updated.Created)
+	assert.Equal(t, secondRun, *updated.Modified)
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.NotEqual(t, previous.ID, updated.ID, "a changed playbook is a new version")
+	assert.Regexp(t, "^playbook--", updated.ID)
+	assert.Equal(t, []string{previous.ID}, updated.DerivedFrom)
+
+	// the version ID is reproducible, and updating the new version without
+	// changes keeps it
+	again := cacao.UpdatePlaybook(previous, generated)
+	assert.Equal(t, updated.ID, again.ID)
+	unchanged := cacao.UpdatePlaybook(updated, generated)
+	assert.Equal(t, updated.ID, unchanged.ID)
+	assert.Equal(t, []string{previous.ID}, unchanged.DerivedFrom)
+}
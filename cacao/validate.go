@@ -0,0 +1,153 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Finding severities
+const FINDING_SEVERITY_ERROR string = "error"
+const FINDING_SEVERITY_WARNING string = "warning"
+
+// Finding codes
+const FINDING_CODE_MISSING_START string = "missing-start"
+const FINDING_CODE_UNKNOWN_STEP string = "unknown-step"
+const FINDING_CODE_UNREACHABLE_STEP string = "unreachable-step"
+const FINDING_CODE_NO_END string = "no-end"
+const FINDING_CODE_UNDECLARED_VARIABLE string = "undeclared-variable"
+
+// Finding is a problem found in the workflow of a playbook
+type Finding struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	StepID   string `json:"step_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.StepID == "" {
+		return fmt.Sprintf("%s: %s (%s)", f.Severity, f.Message, f.Code)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", f.Severity, f.StepID, f.Message, f.Code)
+}
+
+// sortedStepIds returns the IDs of the workflow steps in a stable order
+func sortedStepIds(workflow Workflow) []string {
+	stepIds := make([]string, 0, len(workflow))
+	for stepId := range workflow {
+		stepIds = append(stepIds, stepId)
+	}
+	sort.Strings(stepIds)
+	return stepIds
+}
+
+// Validate checks the workflow of a playbook for references to steps that
+// do not exist, steps that cannot be reached from the start step, steps that
+// never reach an end step and arguments that refer to undeclared variables.
+// Findings are returned in a stable order, a nil slice means none were found.
+func Validate(cacaoPlaybook *CacaoPlaybook) []Finding {
+	var findings []Finding
+	report := func(severity, code, stepId, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Severity: severity,
+			Code:     code,
+			StepID:   stepId,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	stepIds := sortedStepIds(cacaoPlaybook.Workflow)
+	if cacaoPlaybook.WorkflowStart == "" {
+		report(FINDING_SEVERITY_ERROR, FINDING_CODE_MISSING_START, "", "workflow_start is not set")
+	} else if _, found := cacaoPlaybook.Workflow[cacaoPlaybook.WorkflowStart]; !found {
+		report(FINDING_SEVERITY_ERROR, FINDING_CODE_MISSING_START, "", "workflow_start refers to unknown step %s", cacaoPlaybook.WorkflowStart)
+	}
+	if cacaoPlaybook.WorkflowException != "" {
+		if _, found := cacaoPlaybook.Workflow[cacaoPlaybook.WorkflowException]; !found {
+			report(FINDING_SEVERITY_ERROR, FINDING_CODE_UNKNOWN_STEP, "", "workflow_exception refers to unknown step %s", cacaoPlaybook.WorkflowException)
+		}
+	}
+	// check references and build the graph of transitions in both directions
+	successors := make(map[string][]string)
+	predecessors := make(map[string][]string)
+	for _, stepId := range stepIds {
+		for _, reference := range stepReferences(cacaoPlaybook.Workflow[stepId]) {
+			if _, found := cacaoPlaybook.Workflow[reference.StepID]; !found {
+				report(FINDING_SEVERITY_ERROR, FINDING_CODE_UNKNOWN_STEP, stepId, "%s refers to unknown step %s", reference.Property, reference.StepID)
+				continue
+			}
+			successors[stepId] = append(successors[stepId], reference.StepID)
+			predecessors[reference.StepID] = append(predecessors[reference.StepID], stepId)
+		}
+	}
+	// find steps that cannot be reached from the start step
+	if _, found := cacaoPlaybook.Workflow[cacaoPlaybook.WorkflowStart]; found {
+		reachable := reachableSteps([]string{cacaoPlaybook.WorkflowStart}, successors)
+		for _, stepId := range stepIds {
+			if !reachable[stepId] && stepId != cacaoPlaybook.WorkflowException {
+				report(FINDING_SEVERITY_WARNING, FINDING_CODE_UNREACHABLE_STEP, stepId, "step %q cannot be reached from workflow_start", cacaoPlaybook.Workflow[stepId].Common().Name)
+			}
+		}
+	}
+	// find steps from which no end step can be reached
+	var endStepIds []string
+	for _, stepId := range stepIds {
+		if _, ok := cacaoPlaybook.Workflow[stepId].(*EndStep); ok {
+			endStepIds = append(endStepIds, stepId)
+		}
+	}
+	reachesEnd := reachableSteps(endStepIds, predecessors)
+	for _, stepId := range stepIds {
+		if !reachesEnd[stepId] {
+			report(FINDING_SEVERITY_ERROR, FINDING_CODE_NO_END, stepId, "no path from step %q reaches an end step", cacaoPlaybook.Workflow[stepId].Common().Name)
+		}
+	}
+	// check that arguments refer to declared variables
+	for _, stepId := range stepIds {
+		step := cacaoPlaybook.Workflow[stepId].Common()
+		variables := step.InArgs
+		if switchStep, ok := cacaoPlaybook.Workflow[stepId].(*SwitchConditionStep); ok && switchStep.Switch != "" {
+			variables = append(append([]string{}, variables...), switchStep.Switch)
+		}
+		for _, variable := range variables {
+			if _, found := step.StepVariables[variable]; found {
+				continue
+			}
+			if _, found := cacaoPlaybook.PlaybookVariables[variable]; !found {
+				report(FINDING_SEVERITY_ERROR, FINDING_CODE_UNDECLARED_VARIABLE, stepId, "variable %s is not declared in playbook_variables or step_variables", variable)
+			}
+		}
+	}
+	return findings
+}
+
+// reachableSteps returns the set of steps reachable from the given steps
+func reachableSteps(from []string, edges map[string][]string) map[string]bool {
+	visited := make(map[string]bool)
+	queue := append([]string{}, from...)
+	for len(queue) > 0 {
+		stepId := queue[0]
+		queue = queue[1:]
+		if visited[stepId] {
+			continue
+		}
+		visited[stepId] = true
+		queue = append(queue, edges[stepId]...)
+	}
+	return visited
+}
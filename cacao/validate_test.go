@@ -0,0 +1,73 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConvertedPlaybook(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(inputDataTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	assert.Empty(t, cacao.Validate(cacaoPlaybook))
+}
+
+func TestValidateFindings(t *testing.T) {
+	cacaoPlaybook := &cacao.CacaoPlaybook{
+		WorkflowStart: "start--1",
+		PlaybookVariables: map[string]cacao.PlaybookVariable{
+			"__declared__": {Type: "string"},
+		},
+		Workflow: cacao.Workflow{
+			"start--1": &cacao.StartStep{StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_START, OnCompletion: "if-condition--2"}},
+			"if-condition--2": &cacao.IfConditionStep{
+				StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_IF_COND, InArgs: []string{"__declared__"}},
+				Condition:  "__declared__ == 'x'",
+				OnTrue:     "end--3",
+				OnFalse:    "action--4",
+			},
+			"end--3":    &cacao.EndStep{StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_END}},
+			"action--4": &cacao.ActionStep{StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_ACTION, Name: "Loop", OnCompletion: "action--5", InArgs: []string{"__undeclared__"}}},
+			"action--5": &cacao.ActionStep{StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_ACTION, OnCompletion: "action--4"}},
+			"action--6": &cacao.ActionStep{StepCommon: cacao.StepCommon{Type: cacao.CACAO_STEP_TYPE_ACTION, Name: "Orphan", OnCompletion: "end--7"}},
+		},
+	}
+	findings := cacao.Validate(cacaoPlaybook)
+	codes := make(map[string][]string)
+	for _, finding := range findings {
+		codes[finding.Code] = append(codes[finding.Code], finding.StepID)
+	}
+	assert.Equal(t, []string{"action--6"}, codes[cacao.FINDING_CODE_UNKNOWN_STEP])
+	assert.Equal(t, []string{"action--6"}, codes[cacao.FINDING_CODE_UNREACHABLE_STEP])
+	assert.Equal(t, []string{"action--4", "action--5", "action--6"}, codes[cacao.FINDING_CODE_NO_END])
+	assert.Equal(t, []string{"action--4"}, codes[cacao.FINDING_CODE_UNDECLARED_VARIABLE])
+	assert.Empty(t, codes[cacao.FINDING_CODE_MISSING_START])
+
+	cacaoPlaybook.WorkflowStart = "start--missing"
+	findings = cacao.Validate(cacaoPlaybook)
+	assert.Equal(t, cacao.FINDING_CODE_MISSING_START, findings[0].Code)
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// the version of the cross-reference step extension
+const CROSS_REFERENCE_EXTENSION_VERSION string = "1.0.0"
+
+// the JSON schema of the cross-reference step extension
+const CROSS_REFERENCE_EXTENSION_SCHEMA string = `{"type":"object","required":["element_id","element_type"],"properties":{"element_id":{"type":"string"},"element_type":{"type":"string"},"name":{"type":"string"},"synthesized":{"type":"boolean"},"reason":{"type":"string"}}}`
+
+// the ID of the extension definition of the cross-reference step extension
+var crossReferenceExtensionId = fmt.Sprintf("extension-definition--%s", deterministicUuid("bpmn-cross-reference"))
+
+// CrossReference maps a step of a playbook to the BPMN element it was
+// created for. Synthesized steps have no BPMN counterpart, such as the end
+// steps created for flows leading nowhere; their element is the one that
+// required them and the reason says why they were created.
+type CrossReference struct {
+	StepID      string `json:"step_id,omitempty"`
+	ElementID   string `json:"element_id"`
+	ElementType string `json:"element_type"`
+	Name        string `json:"name,omitempty"`
+	Synthesized bool   `json:"synthesized,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// CrossReferenceFile is the content of the sidecar file listing the
+// cross-references of a playbook
+type CrossReferenceFile struct {
+	PlaybookID      string           `json:"playbook_id"`
+	CrossReferences []CrossReference `json:"cross_references"`
+}
+
+// synthesize records a step created without a BPMN counterpart for the
+// element sourceId, and why
+func (r *Report) synthesize(stepId, sourceId, reason string) {
+	if r == nil {
+		return
+	}
+	r.synthesized = append(r.synthesized, CrossReference{StepID: stepId, ElementID: sourceId, Synthesized: true, Reason: reason})
+}
+
+// dropSynthesized forgets a synthesized step that was removed again
+func (r *Report) dropSynthesized(stepId string) {
+	if r == nil {
+		return
+	}
+	kept := r.synthesized[:0]
+	for _, reference := range r.synthesized {
+		if reference.StepID != stepId {
+			kept = append(kept, reference)
+		}
+	}
+	r.synthesized = kept
+}
+
+// missingTargetReason is the reason an end step is synthesized for a flow
+func missingTargetReason(flow bpmn.BpmnSequenceFlow) string {
+	if flow.TargetRef == "" {
+		return fmt.Sprintf("flow %s has no target", flow.Id)
+	}
+	return fmt.Sprintf("flow %s leads to %s, which has no step", flow.Id, flow.TargetRef)
+}
+
+// crossReferences lists the steps created for the flow nodes in the
+// inventory, followed by the synthesized steps in the order they were
+// created. Steps that were dropped later in the conversion are left out.
+func crossReferences(graph *bpmn.FlowGraph, report *Report, cacaoPlaybook *CacaoPlaybook) []CrossReference {
+	var references []CrossReference
+	seen := make(map[string]bool)
+	add := func(reference CrossReference) {
+		if _, found := cacaoPlaybook.Workflow[reference.StepID]; !found || seen[reference.StepID] {
+			return
+		}
+		seen[reference.StepID] = true
+		references = append(references, reference)
+	}
+	for _, entry := range report.Inventory {
+		// the step of a sequence flow is the step of its source
+		if entry.StepID != "" && entry.ElementType != bpmn.BPMN_ELEMENT_SEQUENCE_FLOW {
+			add(CrossReference{StepID: entry.StepID, ElementID: entry.ElementID, ElementType: entry.ElementType, Name: entry.Name})
+		}
+	}
+	for _, reference := range report.synthesized {
+		reference.ElementType = graph.Type(reference.ElementID)
+		reference.Name = graph.Name(reference.ElementID)
+		add(reference)
+	}
+	return references
+}
+
+// embedCrossReferences adds the cross-reference of each step to the step as
+// a step extension, and the definition of the extension to the playbook
+func embedCrossReferences(references []CrossReference, cacaoPlaybook *CacaoPlaybook) {
+	for _, reference := range references {
+		step := cacaoPlaybook.Workflow[reference.StepID]
+		// the step ID is the key of the step in the workflow
+		reference.StepID = ""
+		setExtension(&step.Common().StepExtensions, crossReferenceExtensionId, reference)
+	}
+	addExtensionDefinition(cacaoPlaybook, crossReferenceExtensionId, ExtensionDefinition{
+		Type:        CACAO_TYPE_EXTENSION_DEFINITION,
+		Name:        "BPMN cross-reference",
+		Description: "The BPMN element a step was created for, and why steps without a BPMN counterpart were synthesized",
+		CreatedBy:   defaultCreatedBy,
+		Schema:      CROSS_REFERENCE_EXTENSION_SCHEMA,
+		Version:     CROSS_REFERENCE_EXTENSION_VERSION,
+	})
+}
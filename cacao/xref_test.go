@@ -0,0 +1,126 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrossReferences(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(implicitSplitTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_11)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	// every step is listed once
+	assert.Len(t, report.CrossReferences, len(cacaoPlaybook.Workflow))
+	references := make(map[string]cacao.CrossReference)
+	for _, reference := range report.CrossReferences {
+		assert.Contains(t, cacaoPlaybook.Workflow, reference.StepID)
+		references[reference.StepID] = reference
+	}
+
+	var synthesized []cacao.CrossReference
+	for _, reference := range report.CrossReferences {
+		if reference.Synthesized {
+			synthesized = append(synthesized, reference)
+		} else {
+			assert.Empty(t, reference.Reason)
+		}
+	}
+	assert.Equal(t, []cacao.CrossReference{
+		{StepID: cacaoPlaybook.Workflow[cacaoPlaybook.WorkflowStart].Common().OnCompletion, ElementID: "StartEvent_1", ElementType: bpmn.BPMN_ELEMENT_START_EVENT, Name: "Alert", Synthesized: true, Reason: "the element has 2 outgoing flows, which are taken in parallel"},
+		{StepID: synthesized[1].StepID, ElementID: "Activity_notify", ElementType: bpmn.BPMN_ELEMENT_USER_TASK, Name: "Notify", Synthesized: true, Reason: "the element has no outgoing flow leading to a step"},
+		{StepID: synthesized[2].StepID, ElementID: "Activity_triage", ElementType: bpmn.BPMN_ELEMENT_USER_TASK, Name: "Triage", Synthesized: true, Reason: "the element has 2 outgoing flows, which are taken in parallel"},
+		{StepID: synthesized[3].StepID, ElementID: "Activity_triage", ElementType: bpmn.BPMN_ELEMENT_USER_TASK, Name: "Triage", Synthesized: true, Reason: synthesized[3].Reason},
+	}, synthesized)
+	assert.Contains(t, synthesized[3].Reason, "the parallel branches end here before joining at ")
+	_, isEnd := cacaoPlaybook.Workflow[synthesized[3].StepID].(*cacao.EndStep)
+	assert.True(t, isEnd)
+
+	// the steps of flow nodes come first
+	assert.Equal(t, cacao.CrossReference{StepID: cacaoPlaybook.WorkflowStart, ElementID: "StartEvent_1", ElementType: bpmn.BPMN_ELEMENT_START_EVENT, Name: "Alert"}, report.CrossReferences[0])
+
+	// the cross-references are only embedded when asked for
+	for _, step := range cacaoPlaybook.Workflow {
+		assert.Empty(t, step.Common().StepExtensions)
+	}
+	assert.Empty(t, cacaoPlaybook.ExtensionDefinitions)
+}
+
+func TestCrossReferenceExtension(t *testing.T) {
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(implicitSplitTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20, cacao.WithCrossReferenceExtension())
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	if !assert.Len(t, cacaoPlaybook.ExtensionDefinitions, 1) {
+		return
+	}
+	var extensionId string
+	for id, definition := range cacaoPlaybook.ExtensionDefinitions {
+		extensionId = id
+		assert.Regexp(t, "^extension-definition--", id)
+		assert.Equal(t, cacao.CACAO_TYPE_EXTENSION_DEFINITION, definition.Type)
+		assert.Equal(t, cacao.CROSS_REFERENCE_EXTENSION_SCHEMA, definition.Schema)
+		assert.NotEmpty(t, definition.CreatedBy)
+	}
+	for _, reference := range report.CrossReferences {
+		var embedded cacao.CrossReference
+		extensions := cacaoPlaybook.Workflow[reference.StepID].Common().StepExtensions
+		if assert.Contains(t, extensions, extensionId) {
+			assert.NoError(t, json.Unmarshal(extensions[extensionId], &embedded))
+			embedded.StepID = reference.StepID
+			assert.Equal(t, reference, embedded)
+		}
+	}
+
+	data, err := json.Marshal(cacaoPlaybook)
+	if err != nil {
+		t.Fatalf("could not marshal Cacao playbook: %s", err)
+	}
+	violations, err := cacao.ValidateSchema(data, cacao.CACAO_SPEC_VERSION_20)
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+
+	// the extension definition survives reading the playbook back
+	readPlaybook, err := cacao.ReadCacao(data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, cacaoPlaybook.ExtensionDefinitions, readPlaybook.ExtensionDefinitions)
+	}
+
+	// CACAO 1.1 has no step extensions
+	cacaoPlaybook, report, err = cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_11, cacao.WithCrossReferenceExtension())
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	assert.Empty(t, cacaoPlaybook.ExtensionDefinitions)
+	if assert.Len(t, report.Warnings(), 1) {
+		assert.Equal(t, cacao.DIAGNOSTIC_CODE_UNSUPPORTED_FEATURE, report.Warnings()[0].Code)
+	}
+}
@@ -0,0 +1,447 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+)
+
+// feelValue returns the value of a FEEL string or number literal such as
+// ="high" or =5, and the expression itself otherwise
+func feelValue(expression string) string {
+	body := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(expression), "="))
+	if unquoted, err := strconv.Unquote(body); err == nil && strings.HasPrefix(body, `"`) {
+		return unquoted
+	}
+	if _, err := strconv.ParseFloat(body, 64); err == nil {
+		return body
+	}
+	return expression
+}
+
+// applyZeebeExtensions adds the Zeebe input mappings of a task to its step
+// as step variables and in_args, and declares its output mappings as
+// playbook variables set through out_args
+func applyZeebeExtensions(task bpmn.BpmnTask, step *ActionStep, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	if task.ExtensionElements == nil || task.ExtensionElements.IoMapping == nil {
+		return
+	}
+	for _, mapping := range task.ExtensionElements.IoMapping.Inputs {
+		addStepInput(task, step, namer, report, mapping.Target, PlaybookVariable{
+			Type:        "string",
+			Description: fmt.Sprintf("Input mapping %s", mapping.Target),
+			Value:       feelValue(mapping.Source),
+		})
+	}
+	for _, mapping := range task.ExtensionElements.IoMapping.Outputs {
+		addStepOutput(task, step, namer, report, cacaoPlaybook, mapping.Target, PlaybookVariable{
+			Type:        "string",
+			Description: fmt.Sprintf("Output mapping %s of %s, set from %s", mapping.Target, task.Name, mapping.Source),
+		})
+	}
+}
+
+// containsString tells whether a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// isFeel tells whether an expression is written in FEEL, as Zeebe conditions are
+func isFeel(expression *bpmn.BpmnExpression) bool {
+	if expression == nil {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimSpace(expression.Body), "=") || strings.Contains(strings.ToLower(expression.Language), "feel")
+}
+
+// feelOperators maps FEEL comparison operators to CACAO condition operators
+var feelOperators = map[string]string{
+	"=":  "==",
+	"!=": "!=",
+	"<":  "<",
+	"<=": "<=",
+	">":  ">",
+	">=": ">=",
+}
+
+// feelParser translates a FEEL expression by recursive descent
+type feelParser struct {
+	tokens    []string
+	position  int
+	variables []string
+	// rename returns the CACAO name of a FEEL variable
+	rename func(string) string
+}
+
+// tokenizeFeel splits a FEEL expression into identifiers, literals, operators and parentheses
+func tokenizeFeel(expression string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for ; j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.'); j++ {
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for ; j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_'); j++ {
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case r == '(' || r == ')':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '<' || r == '>' || r == '!' || r == '=':
+			j := i + 1
+			if j < len(runes) && runes[j] == '=' && r != '=' {
+				j++
+			}
+			if _, found := feelOperators[string(runes[i:j])]; !found {
+				return nil, fmt.Errorf("unsupported operator %q", string(runes[i:j]))
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("unsupported character %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *feelParser) peek() string {
+	if p.position < len(p.tokens) {
+		return p.tokens[p.position]
+	}
+	return ""
+}
+
+func (p *feelParser) next() string {
+	token := p.peek()
+	p.position++
+	return token
+}
+
+func (p *feelParser) expect(token string) error {
+	if found := p.next(); found != token {
+		return fmt.Errorf("expected %q, found %q", token, found)
+	}
+	return nil
+}
+
+// or := and ("or" and)*
+func (p *feelParser) or() (string, error) {
+	left, err := p.and()
+	for err == nil && p.peek() == "or" {
+		p.next()
+		var right string
+		right, err = p.and()
+		left = fmt.Sprintf("%s || %s", left, right)
+	}
+	return left, err
+}
+
+// and := comparison ("and" comparison)*
+func (p *feelParser) and() (string, error) {
+	left, err := p.comparison()
+	for err == nil && p.peek() == "and" {
+		p.next()
+		var right string
+		right, err = p.comparison()
+		left = fmt.Sprintf("%s && %s", left, right)
+	}
+	return left, err
+}
+
+// comparison := operand (operator operand)?
+func (p *feelParser) comparison() (string, error) {
+	left, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+	operator, found := feelOperators[p.peek()]
+	if !found {
+		return left, nil
+	}
+	p.next()
+	right, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", left, operator, right), nil
+}
+
+// operand := "not" "(" or ")" | "(" or ")" | literal | variable
+func (p *feelParser) operand() (string, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return "", fmt.Errorf("unexpected end of expression")
+	case token == "not" || token == "(":
+		if token == "not" {
+			if err := p.expect("("); err != nil {
+				return "", err
+			}
+		}
+		inner, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect(")"); err != nil {
+			return "", err
+		}
+		if token == "not" {
+			return fmt.Sprintf("!(%s)", inner), nil
+		}
+		return fmt.Sprintf("(%s)", inner), nil
+	case strings.HasPrefix(token, `"`), token == "true", token == "false", token == "null":
+		return token, nil
+	case unicode.IsDigit([]rune(token)[0]) || token[0] == '-':
+		return token, nil
+	case unicode.IsLetter([]rune(token)[0]) || token[0] == '_':
+		if token == "and" || token == "or" {
+			return "", fmt.Errorf("unexpected %q", token)
+		}
+		if p.peek() == "(" {
+			return "", fmt.Errorf("unsupported function %s", token)
+		}
+		name := p.rename(token)
+		if !containsString(p.variables, name) {
+			p.variables = append(p.variables, name)
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("unexpected %q", token)
+}
+
+// TranslateFeelCondition translates a FEEL boolean expression, with or
+// without the leading "=" of Zeebe expressions, to a CACAO condition and
+// returns the variables it refers to, eg. `= score >= 5 and not(muted)`
+// becomes "score >= 5 && !(muted)". Only comparisons, and, or, not(),
+// parentheses, literals and plain variable names can be translated.
+func TranslateFeelCondition(expression string) (string, []string, error) {
+	return translateFeelCondition(expression, variableName)
+}
+
+// translateFeelCondition translates a FEEL expression, naming its variables with rename
+func translateFeelCondition(expression string, rename func(string) string) (string, []string, error) {
+	tokens, err := tokenizeFeel(strings.TrimPrefix(strings.TrimSpace(expression), "="))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tokens) == 0 {
+		return "", nil, fmt.Errorf("empty expression")
+	}
+	parser := &feelParser{tokens: tokens, rename: rename}
+	condition, err := parser.or()
+	if err != nil {
+		return "", nil, err
+	}
+	if token := parser.peek(); token != "" {
+		return "", nil, fmt.Errorf("unexpected %q", token)
+	}
+	return condition, parser.variables, nil
+}
+
+// applyFlowConditions replaces the condition of the if condition step of an
+// exclusive gateway with the FEEL condition of one of its outgoing flows,
+// and the cases of its switch condition step with the values its flows
+// compare a variable with, so that the step branches on the process data
+// rather than on a variable named after the gateway. Conditions that cannot
+// be translated are reported and the step is left as it is.
+func applyFlowConditions(bpmnProcess bpmn.BpmnProcess, specVersion string, stepMap map[string]string, graph *bpmn.FlowGraph, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	endStepType := CACAO_STEP_TYPE_END
+	if specVersion == CACAO_SPEC_VERSION_11 {
+		endStepType = CACAO_STEP_TYPE_11_STEP
+	}
+	for _, gateway := range bpmnProcess.ExclusiveGateway {
+		switch step := cacaoPlaybook.Workflow[stepMap[gateway.Id]].(type) {
+		case *IfConditionStep:
+			applyIfCondition(gateway, step, endStepType, stepMap, graph, namer, report, cacaoPlaybook)
+		case *SwitchConditionStep:
+			applySwitchConditions(gateway, step, endStepType, stepMap, graph, namer, report, cacaoPlaybook)
+		}
+	}
+}
+
+// applyIfCondition replaces the condition of the if condition step of a
+// two-way gateway with the FEEL condition of the first flow that has one
+func applyIfCondition(gateway bpmn.BpmnGateway, step *IfConditionStep, endStepType string, stepMap map[string]string, graph *bpmn.FlowGraph, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	outgoing := graph.Outgoing(gateway.Id)
+	if len(outgoing) != 2 {
+		return
+	}
+	for i, sequenceFlow := range outgoing {
+		if !isFeel(sequenceFlow.ConditionExpression) {
+			continue
+		}
+		condition, variables, err := translateFeelCondition(sequenceFlow.ConditionExpression.Body, namer.processVariable)
+		if err != nil {
+			report.warnf(DIAGNOSTIC_CODE_UNTRANSLATED_CONDITION, sequenceFlow.Id, stepMap[gateway.Id], "simplify the condition or edit the condition of the step", "cannot translate condition %q: %s", strings.TrimSpace(sequenceFlow.ConditionExpression.Body), err)
+			return
+		}
+		// the branches may be swapped, so the end steps synthesized for
+		// flows leading to elements without a step are synthesized again
+		// for their new branch
+		for _, role := range []string{"on_true", "on_false"} {
+			stepId := synthesizedStepId(endStepType, gateway.Id, role)
+			delete(cacaoPlaybook.Workflow, stepId)
+			report.dropSynthesized(stepId)
+		}
+		setConditionVariables(&step.StepCommon, variables, cacaoPlaybook)
+		step.Condition = condition
+		step.OnTrue = flowTarget(gateway.Id, sequenceFlow, "on_true", endStepType, stepMap, report, cacaoPlaybook)
+		step.OnFalse = flowTarget(gateway.Id, outgoing[1-i], "on_false", endStepType, stepMap, report, cacaoPlaybook)
+		return
+	}
+}
+
+// applySwitchConditions replaces the cases of the switch condition step of
+// a gateway with three or more flows by the values the FEEL conditions of
+// its flows compare a variable with, eg. `= severity = "high"` becomes the
+// case "high" of the switch on severity. This is only possible if every
+// flow but the default compares the same variable with a different value,
+// otherwise each FEEL condition is reported.
+func applySwitchConditions(gateway bpmn.BpmnGateway, step *SwitchConditionStep, endStepType string, stepMap map[string]string, graph *bpmn.FlowGraph, namer *variableNamer, report *Report, cacaoPlaybook *CacaoPlaybook) {
+	outgoing := graph.Outgoing(gateway.Id)
+	var conditional []bpmn.BpmnSequenceFlow
+	for _, sequenceFlow := range outgoing {
+		if isFeel(sequenceFlow.ConditionExpression) {
+			conditional = append(conditional, sequenceFlow)
+		}
+	}
+	if len(conditional) == 0 {
+		return
+	}
+	switchVariable := ""
+	cases := make(map[string][]string)
+	var err error
+	for _, sequenceFlow := range outgoing {
+		if sequenceFlow.Id == gateway.Default {
+			cases[SWITCH_CASE_DEFAULT] = []string{flowTarget(gateway.Id, sequenceFlow, sequenceFlow.Id, endStepType, stepMap, report, cacaoPlaybook)}
+			continue
+		}
+		if !isFeel(sequenceFlow.ConditionExpression) {
+			err = fmt.Errorf("flow %s has no condition", sequenceFlow.Id)
+			break
+		}
+		var variable, value string
+		variable, value, err = feelSwitchCase(sequenceFlow.ConditionExpression.Body, namer.processVariable)
+		if err != nil {
+			break
+		}
+		if switchVariable != "" && variable != switchVariable {
+			err = fmt.Errorf("the conditions compare different variables, %s and %s", switchVariable, variable)
+			break
+		}
+		if _, found := cases[value]; found || value == SWITCH_CASE_DEFAULT {
+			err = fmt.Errorf("more than one flow is taken for %q", value)
+			break
+		}
+		switchVariable = variable
+		cases[value] = []string{flowTarget(gateway.Id, sequenceFlow, sequenceFlow.Id, endStepType, stepMap, report, cacaoPlaybook)}
+	}
+	if err != nil {
+		for _, sequenceFlow := range conditional {
+			report.warnf(DIAGNOSTIC_CODE_UNTRANSLATED_CONDITION, sequenceFlow.Id, stepMap[gateway.Id], "make each condition compare the same variable with a different value, or edit the cases of the step", "cannot translate condition %q to a case of the switch step: %s", strings.TrimSpace(sequenceFlow.ConditionExpression.Body), err)
+		}
+		return
+	}
+	setConditionVariables(&step.StepCommon, []string{switchVariable}, cacaoPlaybook)
+	step.Switch = switchVariable
+	step.Cases = cases
+}
+
+// feelSwitchCase returns the variable and the value a FEEL condition
+// compares it with, if it is a single equality such as `= severity = "high"`
+func feelSwitchCase(expression string, rename func(string) string) (string, string, error) {
+	tokens, err := tokenizeFeel(strings.TrimPrefix(strings.TrimSpace(expression), "="))
+	if err != nil {
+		return "", "", err
+	}
+	isLiteral := func(token string) bool {
+		return strings.HasPrefix(token, `"`) || token == "true" || token == "false" || unicode.IsDigit([]rune(token)[0]) || token[0] == '-'
+	}
+	if len(tokens) != 3 || tokens[1] != "=" {
+		return "", "", fmt.Errorf("only the comparison of a variable with a value can be a case")
+	}
+	name, literal := tokens[0], tokens[2]
+	if isLiteral(name) {
+		name, literal = literal, name
+	}
+	if isLiteral(name) || !isLiteral(literal) || !(unicode.IsLetter([]rune(name)[0]) || name[0] == '_') || name == "null" {
+		return "", "", fmt.Errorf("only the comparison of a variable with a value can be a case")
+	}
+	value := literal
+	if strings.HasPrefix(literal, `"`) {
+		if value, err = strconv.Unquote(literal); err != nil {
+			return "", "", fmt.Errorf("cannot read string %s: %s", literal, err)
+		}
+	}
+	return rename(name), value, nil
+}
+
+// setConditionVariables makes the given variables the in_args of a
+// condition step, declaring them as playbook variables and dropping those
+// of its previous condition, such as the variable named after the gateway
+func setConditionVariables(step *StepCommon, variables []string, cacaoPlaybook *CacaoPlaybook) {
+	for _, variable := range step.InArgs {
+		if !containsString(variables, variable) {
+			delete(cacaoPlaybook.PlaybookVariables, variable)
+		}
+	}
+	for _, variable := range variables {
+		if _, found := cacaoPlaybook.PlaybookVariables[variable]; found {
+			continue
+		}
+		if cacaoPlaybook.PlaybookVariables == nil {
+			cacaoPlaybook.PlaybookVariables = make(map[string]PlaybookVariable)
+		}
+		cacaoPlaybook.PlaybookVariables[variable] = PlaybookVariable{
+			Type:        "string",
+			Description: fmt.Sprintf("Referenced by the condition of %s", step.Name),
+		}
+	}
+	step.InArgs = variables
+}
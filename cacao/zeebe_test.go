@@ -0,0 +1,270 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cacao_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cydarm/bpmn-to-cacao/bpmn"
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateFeelCondition(t *testing.T) {
+	testCases := []struct {
+		expression string
+		condition  string
+		variables  []string
+	}{
+		{`= score >= 5`, "score >= 5", []string{"score"}},
+		{`=severity = "high" or severity = "critical"`, `severity == "high" || severity == "critical"`, []string{"severity"}},
+		{`= not(muted) and (count != 0)`, "!(muted) && (count != 0)", []string{"muted", "count"}},
+		{`isMalicious = true`, "ismalicious == true", []string{"ismalicious"}},
+	}
+	for _, testCase := range testCases {
+		condition, variables, err := cacao.TranslateFeelCondition(testCase.expression)
+		if assert.NoError(t, err, testCase.expression) {
+			assert.Equal(t, testCase.condition, condition)
+			assert.Equal(t, testCase.variables, variables)
+		}
+	}
+	for _, expression := range []string{`= count(hits) > 0`, `= alert.score > 5`, `= x in [1, 2]`, `= a >`, `= "open`, `=`} {
+		_, _, err := cacao.TranslateFeelCondition(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+const zeebeProcessTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:zeebe="http://camunda.org/schema/zeebe/1.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Zeebe" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:serviceTask id="Activity_1" name="Score the alert">
+      <bpmn:extensionElements>
+        <zeebe:taskDefinition type="alert-scoring" retries="3" />
+        <zeebe:ioMapping>
+          <zeebe:input source="=alert.ip" target="address" />
+          <zeebe:input source="=&#34;edr&#34;" target="source" />
+          <zeebe:output source="=result.score" target="score" />
+        </zeebe:ioMapping>
+        <zeebe:taskHeaders>
+          <zeebe:header key="model" value="v2" />
+        </zeebe:taskHeaders>
+      </bpmn:extensionElements>
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+    </bpmn:serviceTask>
+    <bpmn:exclusiveGateway id="Gateway_1" name="Is the alert serious?">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+      <bpmn:outgoing>Flow_4</bpmn:outgoing>
+    </bpmn:exclusiveGateway>
+    <bpmn:userTask id="Activity_2" name="Escalate">
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+      <bpmn:outgoing>Flow_5</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:endEvent id="Event_1" name="Done">
+      <bpmn:incoming>Flow_4</bpmn:incoming>
+      <bpmn:incoming>Flow_5</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Activity_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Activity_1" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Gateway_1" targetRef="Activity_2">
+      <bpmn:conditionExpression xsi:type="bpmn:tFormalExpression">= score &gt;= 5</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Gateway_1" targetRef="Event_1">
+      <bpmn:conditionExpression xsi:type="bpmn:tFormalExpression">= score &lt; 5</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="Flow_5" sourceRef="Activity_2" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestConvertZeebeExtensions(t *testing.T) {
+	rules, err := cacao.LoadMappingRules([]byte(`
+rules:
+  - match:
+      job_type: ^alert-
+    command:
+      type: http-api
+      template: "POST /{{.JobType}}?model={{.Headers.model}}"
+`))
+	if err != nil {
+		t.Fatalf("could not load mapping rules: %s", err)
+	}
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(zeebeProcessTestString))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	for _, specVersion := range []string{cacao.CACAO_SPEC_VERSION_11, cacao.CACAO_SPEC_VERSION_20} {
+		t.Run(specVersion, func(t *testing.T) {
+			cacaoPlaybook, err := cacao.ConvertToCacao(bpmnDefinitions, specVersion, cacao.WithMappingRules(rules))
+			if err != nil {
+				t.Fatalf("could not convert BPMN to Cacao: %s", err)
+			}
+			score := stepByName(t, cacaoPlaybook, "Score the alert")
+			assert.Equal(t, cacao.CACAO_COMMAND_TYPE_HTTP, score.Commands[0].Type)
+			assert.Equal(t, "POST /alert-scoring?model=v2", score.Commands[0].Command)
+			assert.Equal(t, []string{variableFor(specVersion, "address"), variableFor(specVersion, "source")}, score.InArgs)
+			assert.Equal(t, "=alert.ip", score.StepVariables[variableFor(specVersion, "address")].Value)
+			assert.Equal(t, "edr", score.StepVariables[variableFor(specVersion, "source")].Value)
+			assert.Equal(t, []string{variableFor(specVersion, "score")}, score.OutArgs)
+
+			// the gateway branches on the translated condition of its first flow
+			var ifStep *cacao.IfConditionStep
+			for _, step := range cacaoPlaybook.Workflow {
+				if typed, ok := step.(*cacao.IfConditionStep); ok {
+					ifStep = typed
+				}
+			}
+			if assert.NotNil(t, ifStep) {
+				assert.Equal(t, variableFor(specVersion, "score")+" >= 5", ifStep.Condition)
+				assert.Equal(t, []string{variableFor(specVersion, "score")}, ifStep.InArgs)
+				assert.Equal(t, "Escalate", cacaoPlaybook.Workflow[ifStep.OnTrue].Common().Name)
+				assert.IsType(t, &cacao.EndStep{}, cacaoPlaybook.Workflow[ifStep.OnFalse])
+			}
+			assert.NotContains(t, cacaoPlaybook.PlaybookVariables, variableFor(specVersion, "is_the_alert_serious"))
+			assert.Empty(t, cacao.Validate(cacaoPlaybook))
+
+			data, err := json.Marshal(cacaoPlaybook)
+			if err != nil {
+				t.Fatalf("could not marshal Cacao playbook: %s", err)
+			}
+			violations, err := cacao.ValidateSchema(data, specVersion)
+			assert.NoError(t, err)
+			assert.Empty(t, violations)
+		})
+	}
+}
+
+const zeebeSwitchTestString = `<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" id="Definitions_1">
+  <bpmn:process id="Process_1" name="Zeebe switch" isExecutable="true">
+    <bpmn:startEvent id="StartEvent_1" name="Start">
+      <bpmn:outgoing>Flow_1</bpmn:outgoing>
+    </bpmn:startEvent>
+    <bpmn:exclusiveGateway id="Gateway_1" name="How serious?" default="Flow_4">
+      <bpmn:incoming>Flow_1</bpmn:incoming>
+      <bpmn:outgoing>Flow_2</bpmn:outgoing>
+      <bpmn:outgoing>Flow_3</bpmn:outgoing>
+      <bpmn:outgoing>Flow_4</bpmn:outgoing>
+    </bpmn:exclusiveGateway>
+    <bpmn:userTask id="Activity_1" name="Escalate">
+      <bpmn:incoming>Flow_2</bpmn:incoming>
+      <bpmn:outgoing>Flow_5</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:userTask id="Activity_2" name="Triage">
+      <bpmn:incoming>Flow_4</bpmn:incoming>
+      <bpmn:outgoing>Flow_6</bpmn:outgoing>
+    </bpmn:userTask>
+    <bpmn:endEvent id="Event_1" name="Done">
+      <bpmn:incoming>Flow_3</bpmn:incoming>
+      <bpmn:incoming>Flow_5</bpmn:incoming>
+      <bpmn:incoming>Flow_6</bpmn:incoming>
+    </bpmn:endEvent>
+    <bpmn:sequenceFlow id="Flow_1" sourceRef="StartEvent_1" targetRef="Gateway_1" />
+    <bpmn:sequenceFlow id="Flow_2" sourceRef="Gateway_1" targetRef="Activity_1">
+      <bpmn:conditionExpression xsi:type="bpmn:tFormalExpression">= severity = "high"</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="Flow_3" sourceRef="Gateway_1" targetRef="Event_1">
+      <bpmn:conditionExpression xsi:type="bpmn:tFormalExpression">= "low" = severity</bpmn:conditionExpression>
+    </bpmn:sequenceFlow>
+    <bpmn:sequenceFlow id="Flow_4" sourceRef="Gateway_1" targetRef="Activity_2" />
+    <bpmn:sequenceFlow id="Flow_5" sourceRef="Activity_1" targetRef="Event_1" />
+    <bpmn:sequenceFlow id="Flow_6" sourceRef="Activity_2" targetRef="Event_1" />
+  </bpmn:process>
+</bpmn:definitions>`
+
+func TestConvertZeebeSwitchConditions(t *testing.T) {
+	convert := func(bpmnString string) (*cacao.CacaoPlaybook, *cacao.Report, *cacao.SwitchConditionStep) {
+		bpmnDefinitions, err := bpmn.ReadBpmn([]byte(bpmnString))
+		if err != nil {
+			t.Fatalf("could not read input: %s", err)
+		}
+		cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+		if err != nil {
+			t.Fatalf("could not convert BPMN to Cacao: %s", err)
+		}
+		for _, step := range cacaoPlaybook.Workflow {
+			if typed, ok := step.(*cacao.SwitchConditionStep); ok {
+				return cacaoPlaybook, report, typed
+			}
+		}
+		t.Fatalf("no switch condition step")
+		return nil, nil, nil
+	}
+
+	// each flow compares severity with a value, which becomes its case
+	cacaoPlaybook, report, switchStep := convert(zeebeSwitchTestString)
+	assert.Equal(t, "__severity__", switchStep.Switch)
+	assert.Equal(t, []string{"__severity__"}, switchStep.InArgs)
+	if assert.Len(t, switchStep.Cases, 3) {
+		assert.Equal(t, []string{stepIdNamed(t, cacaoPlaybook, "Escalate")}, switchStep.Cases["high"])
+		assert.IsType(t, &cacao.EndStep{}, cacaoPlaybook.Workflow[switchStep.Cases["low"][0]])
+		assert.Equal(t, []string{stepIdNamed(t, cacaoPlaybook, "Triage")}, switchStep.Cases[cacao.SWITCH_CASE_DEFAULT])
+	}
+	assert.Contains(t, cacaoPlaybook.PlaybookVariables, "__severity__")
+	assert.NotContains(t, cacaoPlaybook.PlaybookVariables, "__how_serious__")
+	assert.Empty(t, report.Warnings())
+	assert.Empty(t, cacao.Validate(cacaoPlaybook))
+
+	// conditions that are not cases are reported rather than dropped
+	for _, condition := range []string{`= priority = "low"`, `= severity = "high"`, `= severity != "low"`} {
+		_, report, switchStep = convert(strings.Replace(zeebeSwitchTestString, `= "low" = severity`, condition, 1))
+		assert.Equal(t, "__how_serious__", switchStep.Switch, condition)
+		var flows []string
+		for _, warning := range report.Warnings() {
+			if warning.Code == cacao.DIAGNOSTIC_CODE_UNTRANSLATED_CONDITION {
+				flows = append(flows, warning.ElementID)
+			}
+		}
+		assert.Equal(t, []string{"Flow_2", "Flow_3"}, flows, condition)
+	}
+}
+
+func TestConvertZeebeSwappedBranches(t *testing.T) {
+	// the condition is on the flow that leads to an element without a step
+	revised := strings.Replace(strings.Replace(zeebeProcessTestString,
+		`<bpmn:conditionExpression xsi:type="bpmn:tFormalExpression">= score &gt;= 5</bpmn:conditionExpression>`, "", 1),
+		`<bpmn:sequenceFlow id="Flow_4" sourceRef="Gateway_1" targetRef="Event_1">`, `<bpmn:sequenceFlow id="Flow_4" sourceRef="Gateway_1" targetRef="Event_missing">`, 1)
+	bpmnDefinitions, err := bpmn.ReadBpmn([]byte(revised))
+	if err != nil {
+		t.Fatalf("could not read input: %s", err)
+	}
+	cacaoPlaybook, report, err := cacao.Convert(bpmnDefinitions, cacao.CACAO_SPEC_VERSION_20)
+	if err != nil {
+		t.Fatalf("could not convert BPMN to Cacao: %s", err)
+	}
+	ifStep := cacaoPlaybook.Workflow[stepIdNamed(t, cacaoPlaybook, "Is the alert serious?")].(*cacao.IfConditionStep)
+	assert.Equal(t, "__score__ < 5", ifStep.Condition)
+	assert.Equal(t, stepIdNamed(t, cacaoPlaybook, "Escalate"), ifStep.OnFalse)
+	assert.IsType(t, &cacao.EndStep{}, cacaoPlaybook.Workflow[ifStep.OnTrue])
+	// only the end step synthesized for the new branch of Flow_4 is listed
+	var synthesized []cacao.CrossReference
+	for _, reference := range report.CrossReferences {
+		if reference.Synthesized {
+			synthesized = append(synthesized, reference)
+		}
+	}
+	if assert.Len(t, synthesized, 1) {
+		assert.Equal(t, ifStep.OnTrue, synthesized[0].StepID)
+		assert.Contains(t, synthesized[0].Reason, "Flow_4")
+	}
+	assert.Empty(t, cacao.Validate(cacaoPlaybook))
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/golang/glog"
+)
+
+// the output formats of the diff subcommand
+const DIFF_FORMAT_TEXT string = "text"
+const DIFF_FORMAT_JSON string = "json"
+const DIFF_FORMAT_MARKDOWN string = "markdown"
+
+// runDiff prints the differences between two CACAO JSON playbooks. As with
+// diff(1), the exit code is 1 if they differ and 2 on errors.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := flags.String("format", DIFF_FORMAT_TEXT, "Output format (text, json or markdown)")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		glog.Errorf("Expected the old and new playbooks")
+		return 2
+	}
+	if *format != DIFF_FORMAT_TEXT && *format != DIFF_FORMAT_JSON && *format != DIFF_FORMAT_MARKDOWN {
+		glog.Errorf("Unknown format %s", *format)
+		return 2
+	}
+	var playbooks []*cacao.CacaoPlaybook
+	for _, inputFile := range flags.Args() {
+		cacaoPlaybook, err := readPlaybook(inputFile)
+		if err != nil {
+			glog.Errorf("could not read %s: %s", inputFile, err)
+			return 2
+		}
+		playbooks = append(playbooks, cacaoPlaybook)
+	}
+	diff, err := cacao.Diff(playbooks[0], playbooks[1])
+	if err != nil {
+		glog.Errorf("could not compare %s and %s: %s", flags.Arg(0), flags.Arg(1), err)
+		return 2
+	}
+	switch *format {
+	case DIFF_FORMAT_JSON:
+		outBytes, err := json.MarshalIndent(diff, "", "    ")
+		if err != nil {
+			glog.Errorf("marshaling JSON failed: %s", err)
+			return 2
+		}
+		fmt.Println(string(outBytes))
+	case DIFF_FORMAT_MARKDOWN:
+		fmt.Print(diff.Markdown())
+	default:
+		fmt.Print(diff.Text())
+	}
+	if diff.Empty() {
+		return 0
+	}
+	return 1
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/cydarm/bpmn-to-cacao/bpmn"
 	"github.com/cydarm/bpmn-to-cacao/cacao"
@@ -30,15 +32,57 @@ import (
 
 var outDir string
 var cacaoSpecVersion string
+var validateOutput bool
+var reproducible bool
+var timestampFlag string
+var updateExisting bool
+var tlpFlag string
+var statementFlag string
+var iepFlag string
+var mappingFile string
+var writeReport bool
+var writeXref bool
+var provenance bool
+var mergeExisting bool
+var strict bool
+
+// subcommands maps each subcommand name to a function that runs it with the
+// remaining arguments and returns the exit code
+var subcommands = map[string]func(args []string) int{
+	"diff":     runDiff,
+	"merge":    runMerge,
+	"migrate":  runMigrate,
+	"sign":     runSign,
+	"validate": runValidate,
+	"verify":   runVerify,
+}
 
 func init() {
 	flag.StringVar(&outDir, "output-dir", ".", "Specify a directory for output")
 	flag.StringVar(&cacaoSpecVersion, "cacao-spec", "1.1", "Specify a CACAO spec version (1.1 or 2.0)")
+	flag.BoolVar(&reproducible, "reproducible", false, "Produce reproducible output, with timestamps from --timestamp, SOURCE_DATE_EPOCH or the input file modification time")
+	flag.StringVar(&timestampFlag, "timestamp", "", "Use this timestamp (RFC 3339 or seconds since the epoch) for created and modified, implies --reproducible")
+	flag.BoolVar(&updateExisting, "update", false, "Update existing output files, keeping their created timestamp and hand-added metadata")
+	flag.BoolVar(&validateOutput, "validate", false, "Validate the schema and workflow of each playbook after conversion")
+	flag.StringVar(&tlpFlag, "tlp", "", "Mark playbooks with a TLP 2.0 level (clear, green, amber, amber+strict or red)")
+	flag.StringVar(&statementFlag, "statement", "", "Mark playbooks with a statement, eg. a copyright notice")
+	flag.StringVar(&mappingFile, "mapping", "", "YAML or JSON file of rules that select the command, agent and targets of tasks")
+	flag.StringVar(&iepFlag, "iep", "", "Mark playbooks with a FIRST IEP policy given as key=value pairs, eg. tlp=amber,encrypt_in_transit=must")
+	flag.BoolVar(&writeReport, "report", false, "Write the problems found in each input file to a JSON report next to its playbook")
+	flag.BoolVar(&writeXref, "xref", false, "Write a file mapping each step to its BPMN element next to each playbook, and embed the mapping in CACAO 2.0 steps")
+	flag.BoolVar(&provenance, "provenance", false, "Record the BPMN source of the playbook and each step in a CACAO 2.0 extension")
+	flag.BoolVar(&mergeExisting, "merge", false, "Merge into existing output files that were edited, updating only the steps whose BPMN source changed, implies --provenance")
+	flag.BoolVar(&strict, "strict", false, "Exit with a non-zero code if a conversion reports warnings, as it does for failures and errors")
 }
 
 func main() {
 	flag.Set("stderrthreshold", "INFO")
 	flag.Parse()
+	if subcommand, found := subcommands[flag.Arg(0)]; found {
+		exitCode := subcommand(flag.Args()[1:])
+		glog.Flush()
+		os.Exit(exitCode)
+	}
 	inputFiles := flag.Args()
 	// validate output directory
 	dirInfo, err := os.Stat(outDir)
@@ -51,37 +95,239 @@ func main() {
 	if len(inputFiles) == 0 {
 		glog.Fatalf("No input files were specified")
 	}
+	if mergeExisting && updateExisting {
+		glog.Fatalf("--merge and --update cannot be combined")
+	}
+	if (provenance || mergeExisting) && cacaoSpecVersion != cacao.CACAO_SPEC_VERSION_20 {
+		glog.Fatalf("--provenance and --merge require --cacao-spec %s", cacao.CACAO_SPEC_VERSION_20)
+	}
+	markings, err := markingsFromFlags()
+	if err != nil {
+		glog.Fatalf("Error parsing markings: %s", err)
+	}
+	var mappingRules *cacao.MappingRules
+	if mappingFile != "" {
+		data, err := os.ReadFile(mappingFile)
+		if err != nil {
+			glog.Fatalf("Error reading mapping rules: %s", err)
+		}
+		mappingRules, err = cacao.LoadMappingRules(data)
+		if err != nil {
+			glog.Fatalf("Error parsing mapping rules %s: %s", mappingFile, err)
+		}
+	}
+	exitCode := 0
+	// the number of BPMN elements converted and found in all input files
+	batchConverted, batchTotal := 0, 0
+	// fail marks the run as failed
+	fail := func() {
+		exitCode = 1
+	}
 	for _, inputFile := range inputFiles {
 		glog.Infof("Processing %s", inputFile)
 		lstat, err := os.Lstat(inputFile)
 		if err != nil {
 			glog.Errorf("could not lstat %s", inputFile)
+			fail()
+			continue
 		}
 		inputFileBaseName := lstat.Name()
 		inputData, err := ioutil.ReadFile(inputFile)
 		if err != nil {
 			glog.Errorf("could not read %s", inputFile)
+			fail()
+			continue
 		}
 		bpmnDefinition, err := bpmn.ReadBpmn(inputData)
 		if err != nil {
 			glog.Errorf("processing input file failed: %s", err)
+			fail()
 			continue
 		}
-		cacaoOutput, err := cacao.ConvertToCacao(bpmnDefinition, cacaoSpecVersion)
+		convertOptions := []cacao.ConvertOption{cacao.WithMarkings(markings...), cacao.WithMappingRules(mappingRules)}
+		if reproducible || timestampFlag != "" {
+			timestamp, err := conversionTimestamp(inputFile)
+			if err != nil {
+				glog.Errorf("could not determine timestamp for %s: %s", inputFile, err)
+				fail()
+				continue
+			}
+			convertOptions = append(convertOptions, cacao.WithTimestamp(timestamp))
+		}
+		if provenance || mergeExisting {
+			convertOptions = append(convertOptions, cacao.WithProvenance(inputData))
+		}
+		if writeXref && cacaoSpecVersion == cacao.CACAO_SPEC_VERSION_20 {
+			convertOptions = append(convertOptions, cacao.WithCrossReferenceExtension())
+		}
+		cacaoOutput, report, err := cacao.Convert(bpmnDefinition, cacaoSpecVersion, convertOptions...)
 		if err != nil {
 			glog.Errorf("cacao convertion failed: %s", err)
+			fail()
 			continue
 		}
+		for _, diagnostic := range report.Diagnostics {
+			if diagnostic.Severity == cacao.DIAGNOSTIC_SEVERITY_ERROR {
+				glog.Errorf("%s: %s", inputFile, diagnostic)
+				fail()
+			} else {
+				glog.Warningf("%s: %s", inputFile, diagnostic)
+				if strict {
+					fail()
+				}
+			}
+		}
+		converted, total := report.Converted()
+		batchConverted += converted
+		batchTotal += total
+		glog.Infof("%s: converted %d of %d BPMN elements (%.1f%%)", inputFile, converted, total, report.Coverage)
+		if writeReport {
+			reportFileName := fmt.Sprintf("%s/%s.report.json", outDir, inputFileBaseName)
+			if err := writeJson(reportFileName, report); err != nil {
+				glog.Errorf("writing file %s failed: %s", reportFileName, err)
+				fail()
+			}
+		}
+		if writeXref {
+			xrefFileName := fmt.Sprintf("%s/%s.xref.json", outDir, inputFileBaseName)
+			xref := cacao.CrossReferenceFile{PlaybookID: cacaoOutput.ID, CrossReferences: report.CrossReferences}
+			if err := writeJson(xrefFileName, xref); err != nil {
+				glog.Errorf("writing file %s failed: %s", xrefFileName, err)
+				fail()
+			}
+		}
+		outputFileName := fmt.Sprintf("%s/%s.cacao.json", outDir, inputFileBaseName)
+		if updateExisting {
+			previous, err := readPlaybook(outputFileName)
+			if err == nil {
+				cacaoOutput = cacao.UpdatePlaybook(previous, cacaoOutput)
+			} else if !os.IsNotExist(err) {
+				glog.Errorf("could not read previous output %s: %s", outputFileName, err)
+				fail()
+				continue
+			}
+		}
+		if mergeExisting {
+			edited, err := readPlaybook(outputFileName)
+			if err == nil {
+				var merge *cacao.MergeReport
+				cacaoOutput, merge, err = cacao.MergeEdited(edited, cacaoOutput)
+				if err != nil {
+					glog.Errorf("could not merge %s: %s", outputFileName, err)
+					fail()
+					continue
+				}
+				for _, change := range merge.Steps {
+					if change.Edited {
+						glog.Warningf("%s: %s step %s (%s), discarding its edits", outputFileName, change.Action, change.StepID, change.Name)
+					} else {
+						glog.Infof("%s: %s step %s (%s)", outputFileName, change.Action, change.StepID, change.Name)
+					}
+				}
+			} else if !os.IsNotExist(err) {
+				glog.Errorf("could not read previous output %s: %s", outputFileName, err)
+				fail()
+				continue
+			}
+		}
 		outBytes, err := json.MarshalIndent(cacaoOutput, "", "    ")
 		if err != nil {
 			glog.Errorf("marshaling JSON failed: %s", err)
+			fail()
 			continue
 		}
-		outputFileName := fmt.Sprintf("%s/%s.cacao.json", outDir, inputFileBaseName)
+		if validateOutput && !validatePlaybook(inputFile, outBytes, cacaoSpecVersion) {
+			fail()
+		}
 		if err := os.WriteFile(outputFileName, outBytes, 0644); err != nil {
 			glog.Errorf("writing file %s failed: %s", outputFileName, err)
+			fail()
 			continue
 		}
 		glog.Infof("Wrote output to %s", outputFileName)
 	}
+	if len(inputFiles) > 1 {
+		glog.Infof("Converted %d of %d BPMN elements in %d files (%.1f%%)", batchConverted, batchTotal, len(inputFiles), cacao.CoveragePercent(batchConverted, batchTotal))
+	}
+	glog.Flush()
+	os.Exit(exitCode)
+}
+
+// writeJson writes a value to a file as indented JSON
+func writeJson(fileName string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// markingsFromFlags returns the data markings given by --tlp, --statement and --iep
+func markingsFromFlags() ([]cacao.DataMarking, error) {
+	var markings []cacao.DataMarking
+	if tlpFlag != "" {
+		marking, err := cacao.NewTlpMarking(tlpFlag)
+		if err != nil {
+			return nil, err
+		}
+		markings = append(markings, marking)
+	}
+	if statementFlag != "" {
+		markings = append(markings, cacao.NewStatementMarking(statementFlag))
+	}
+	if iepFlag != "" {
+		marking, err := cacao.ParseIepMarking(iepFlag)
+		if err != nil {
+			return nil, err
+		}
+		markings = append(markings, marking)
+	}
+	return markings, nil
+}
+
+// readPlaybook reads a CACAO JSON playbook
+func readPlaybook(fileName string) (*cacao.CacaoPlaybook, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return cacao.ReadCacao(data)
+}
+
+// sameFile tells whether two paths refer to the same existing file
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+// conversionTimestamp returns the timestamp used for reproducible output,
+// taken from --timestamp, SOURCE_DATE_EPOCH or the input file modification
+// time, in that order of preference
+func conversionTimestamp(inputFile string) (time.Time, error) {
+	if timestampFlag != "" {
+		return parseTimestamp(timestampFlag)
+	}
+	if sourceDateEpoch := os.Getenv("SOURCE_DATE_EPOCH"); sourceDateEpoch != "" {
+		return parseTimestamp(sourceDateEpoch)
+	}
+	fileInfo, err := os.Stat(inputFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fileInfo.ModTime(), nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp or a number of seconds since the epoch
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
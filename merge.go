@@ -0,0 +1,98 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/golang/glog"
+)
+
+// runMerge merges a hand-edited playbook with a newly generated version of
+// it, given the generated playbook the edits started from. The merged
+// playbook is written to the output directory under the name of the edited
+// file, along with a report of the changes and conflicts, and never over one
+// of the input files. The exit code is non-zero if there are conflicts.
+func runMerge(args []string) int {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 3 {
+		glog.Errorf("Expected the base, edited and generated playbooks")
+		return 2
+	}
+	var playbooks []*cacao.CacaoPlaybook
+	for _, inputFile := range flags.Args() {
+		cacaoPlaybook, err := readPlaybook(inputFile)
+		if err != nil {
+			glog.Errorf("could not read %s: %s", inputFile, err)
+			return 2
+		}
+		playbooks = append(playbooks, cacaoPlaybook)
+	}
+	editedFile := flags.Arg(1)
+	outputFileName := fmt.Sprintf("%s/%s", outDir, filepath.Base(editedFile))
+	reportFileName := fmt.Sprintf("%s/%s.merge.json", outDir, strings.TrimSuffix(filepath.Base(editedFile), ".json"))
+	for _, inputFile := range flags.Args() {
+		if sameFile(inputFile, outputFileName) || sameFile(inputFile, reportFileName) {
+			glog.Errorf("merging would overwrite %s, use --output-dir to write elsewhere", inputFile)
+			return 2
+		}
+	}
+	merged, report := cacao.Merge(playbooks[0], playbooks[1], playbooks[2])
+	for _, change := range report.Steps {
+		glog.Infof("%s step %s (%s)", change.Action, change.StepID, change.Name)
+	}
+	for _, conflict := range report.Conflicts {
+		switch {
+		case conflict.Kind == cacao.MERGE_CONFLICT_DANGLING_REFERENCE && conflict.StepID != "":
+			glog.Warningf("conflict: %s of step %s (%s) refers to missing step %s", conflict.Property, conflict.StepID, conflict.Name, conflict.Reference)
+		case conflict.Kind == cacao.MERGE_CONFLICT_DANGLING_REFERENCE:
+			glog.Warningf("conflict: %s refers to missing step %s", conflict.Property, conflict.Reference)
+		case conflict.StepID != "":
+			glog.Warningf("conflict: step %s (%s) %s, keeping the edited version", conflict.StepID, conflict.Name, conflict.Kind)
+		case conflict.Variable != "":
+			glog.Warningf("conflict: variable %s %s, keeping the edited version", conflict.Variable, conflict.Kind)
+		default:
+			glog.Warningf("conflict: %s %s, keeping the edited version", conflict.Property, conflict.Kind)
+		}
+	}
+	outBytes, err := json.MarshalIndent(merged, "", "    ")
+	if err != nil {
+		glog.Errorf("marshaling JSON failed: %s", err)
+		return 1
+	}
+	if err := os.WriteFile(outputFileName, outBytes, 0644); err != nil {
+		glog.Errorf("writing file %s failed: %s", outputFileName, err)
+		return 1
+	}
+	glog.Infof("Wrote output to %s", outputFileName)
+	if err := writeJson(reportFileName, report); err != nil {
+		glog.Errorf("writing file %s failed: %s", reportFileName, err)
+		return 1
+	}
+	if len(report.Conflicts) > 0 {
+		glog.Warningf("%d conflicts, see %s", len(report.Conflicts), reportFileName)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/golang/glog"
+)
+
+// runMigrate upgrades CACAO 1.1 JSON playbooks to CACAO 2.0, writing each to
+// the output directory under its original file name
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	inPlace := flags.Bool("in-place", false, "Overwrite input files that are in the output directory")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		glog.Errorf("No input files were specified")
+		return 2
+	}
+	exitCode := 0
+	for _, inputFile := range flags.Args() {
+		glog.Infof("Migrating %s", inputFile)
+		cacaoPlaybook, err := readPlaybook(inputFile)
+		if err != nil {
+			glog.Errorf("could not read %s: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		var migrateOptions []cacao.ConvertOption
+		if reproducible || timestampFlag != "" {
+			timestamp, err := conversionTimestamp(inputFile)
+			if err != nil {
+				glog.Errorf("could not determine timestamp for %s: %s", inputFile, err)
+				exitCode = 1
+				continue
+			}
+			migrateOptions = append(migrateOptions, cacao.WithTimestamp(timestamp))
+		}
+		migrated, err := cacao.Migrate(cacaoPlaybook, migrateOptions...)
+		if err != nil {
+			glog.Errorf("migration of %s failed: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		outBytes, err := json.MarshalIndent(migrated, "", "    ")
+		if err != nil {
+			glog.Errorf("marshaling JSON failed: %s", err)
+			exitCode = 1
+			continue
+		}
+		outputFileName := fmt.Sprintf("%s/%s", outDir, filepath.Base(inputFile))
+		if sameFile(inputFile, outputFileName) && !*inPlace {
+			glog.Errorf("migrating %s would overwrite it, use --output-dir or -in-place", inputFile)
+			exitCode = 1
+			continue
+		}
+		if err := os.WriteFile(outputFileName, outBytes, 0644); err != nil {
+			glog.Errorf("writing file %s failed: %s", outputFileName, err)
+			exitCode = 1
+			continue
+		}
+		glog.Infof("Wrote output to %s", outputFileName)
+	}
+	return exitCode
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/golang/glog"
+)
+
+// runSign signs CACAO 2.0 JSON playbooks, writing each to the output
+// directory under its original file name
+func runSign(args []string) int {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyFile := flags.String("key", "", "PEM file holding the private key to sign with (required)")
+	certificateFile := flags.String("cert", "", "PEM file holding the certificate of the key, followed by any intermediate certificates")
+	signee := flags.String("signee", "", "Name of the signee, defaults to the certificate subject")
+	inPlace := flags.Bool("in-place", false, "Overwrite input files that are in the output directory")
+	flags.Parse(args)
+	if *keyFile == "" {
+		glog.Errorf("No key was specified, use -key")
+		return 2
+	}
+	if flags.NArg() == 0 {
+		glog.Errorf("No input files were specified")
+		return 2
+	}
+	keyPem, err := os.ReadFile(*keyFile)
+	if err != nil {
+		glog.Errorf("could not read key: %s", err)
+		return 2
+	}
+	var certificatePem []byte
+	if *certificateFile != "" {
+		certificatePem, err = os.ReadFile(*certificateFile)
+		if err != nil {
+			glog.Errorf("could not read certificate: %s", err)
+			return 2
+		}
+	}
+	signer, err := cacao.NewSigner(keyPem, certificatePem)
+	if err != nil {
+		glog.Errorf("could not load key: %s", err)
+		return 2
+	}
+	if *signee != "" {
+		signer.Signee = *signee
+	}
+	exitCode := 0
+	for _, inputFile := range flags.Args() {
+		glog.Infof("Signing %s", inputFile)
+		cacaoPlaybook, err := readPlaybook(inputFile)
+		if err != nil {
+			glog.Errorf("could not read %s: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		var signOptions []cacao.ConvertOption
+		if reproducible || timestampFlag != "" {
+			timestamp, err := conversionTimestamp(inputFile)
+			if err != nil {
+				glog.Errorf("could not determine timestamp for %s: %s", inputFile, err)
+				exitCode = 1
+				continue
+			}
+			signOptions = append(signOptions, cacao.WithTimestamp(timestamp))
+		}
+		signature, err := cacao.Sign(cacaoPlaybook, signer, signOptions...)
+		if err != nil {
+			glog.Errorf("signing of %s failed: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		outBytes, err := json.MarshalIndent(cacaoPlaybook, "", "    ")
+		if err != nil {
+			glog.Errorf("marshaling JSON failed: %s", err)
+			exitCode = 1
+			continue
+		}
+		outputFileName := fmt.Sprintf("%s/%s", outDir, filepath.Base(inputFile))
+		if sameFile(inputFile, outputFileName) && !*inPlace {
+			glog.Errorf("signing %s would overwrite it, use --output-dir or -in-place", inputFile)
+			exitCode = 1
+			continue
+		}
+		if err := os.WriteFile(outputFileName, outBytes, 0644); err != nil {
+			glog.Errorf("writing file %s failed: %s", outputFileName, err)
+			exitCode = 1
+			continue
+		}
+		glog.Infof("Wrote %s signed with key %s to %s", signature.ID, signature.Thumbprint, outputFileName)
+	}
+	return exitCode
+}
+
+// runVerify verifies the signatures of CACAO JSON playbooks, returning a
+// non-zero exit code if any file is unsigned or has an invalid signature
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	trustFiles := flags.String("trust", "", "Comma separated PEM files holding trusted public keys or CA certificates, if not given signatures are checked with their embedded key only")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		glog.Errorf("No input files were specified")
+		return 2
+	}
+	var trust *cacao.TrustStore
+	if *trustFiles != "" {
+		var trustPem [][]byte
+		for _, trustFile := range strings.Split(*trustFiles, ",") {
+			data, err := os.ReadFile(trustFile)
+			if err != nil {
+				glog.Errorf("could not read %s: %s", trustFile, err)
+				return 2
+			}
+			trustPem = append(trustPem, data)
+		}
+		var err error
+		trust, err = cacao.NewTrustStore(trustPem...)
+		if err != nil {
+			glog.Errorf("could not load trusted keys: %s", err)
+			return 2
+		}
+	}
+	exitCode := 0
+	for _, inputFile := range flags.Args() {
+		data, err := os.ReadFile(inputFile)
+		if err != nil {
+			glog.Errorf("could not read %s: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		results, err := cacao.VerifySignatures(data, trust)
+		if err != nil {
+			glog.Errorf("could not verify %s: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		if len(results) == 0 {
+			glog.Errorf("%s: the playbook is not signed", inputFile)
+			exitCode = 1
+			continue
+		}
+		for _, result := range results {
+			if result.Valid() {
+				glog.Infof("%s: %s", inputFile, result)
+			} else {
+				glog.Errorf("%s: %s", inputFile, result)
+				exitCode = 1
+			}
+		}
+	}
+	return exitCode
+}
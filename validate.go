@@ -0,0 +1,80 @@
+/*
+ * Copyright 2023 Cydarm Technologies Pty Ltd, https://cydarm.com/
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * 		http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/cydarm/bpmn-to-cacao/cacao"
+	"github.com/golang/glog"
+)
+
+// runValidate validates CACAO JSON playbooks against the embedded schemas
+// and checks their workflows
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	specVersion := flags.String("cacao-spec", "", "Validate against a CACAO spec version (1.1 or 2.0) instead of the spec_version of each file")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		glog.Errorf("No input files were specified")
+		return 2
+	}
+	exitCode := 0
+	for _, inputFile := range flags.Args() {
+		inputData, err := os.ReadFile(inputFile)
+		if err != nil {
+			glog.Errorf("could not read %s: %s", inputFile, err)
+			exitCode = 1
+			continue
+		}
+		if !validatePlaybook(inputFile, inputData, *specVersion) {
+			exitCode = 1
+			continue
+		}
+		glog.Infof("%s is valid", inputFile)
+	}
+	return exitCode
+}
+
+// validatePlaybook logs the schema violations and workflow findings of a
+// playbook, returning false if any errors were found
+func validatePlaybook(name string, data []byte, specVersion string) bool {
+	violations, err := cacao.ValidateSchema(data, specVersion)
+	if err != nil {
+		glog.Errorf("could not validate %s: %s", name, err)
+		return false
+	}
+	for _, violation := range violations {
+		glog.Errorf("%s: schema violation at %s", name, violation)
+	}
+	cacaoPlaybook, err := cacao.ReadCacao(data)
+	if err != nil {
+		glog.Errorf("could not parse %s: %s", name, err)
+		return false
+	}
+	valid := len(violations) == 0
+	for _, finding := range cacao.Validate(cacaoPlaybook) {
+		if finding.Severity == cacao.FINDING_SEVERITY_ERROR {
+			glog.Errorf("%s: %s", name, finding)
+			valid = false
+		} else {
+			glog.Warningf("%s: %s", name, finding)
+		}
+	}
+	return valid
+}